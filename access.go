@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// largeTargetPadding is the extra padding in pixels given to each side
+// of the main window's controls when larger targets are chosen.
+const largeTargetPadding = 6
+
+// targetStyles are the ttk styles given more padding for larger
+// targets, and largeStylePadding is the padding they are given.
+var (
+	targetStyles      = []string{"TButton", "TCheckbutton", "TCombobox", "TEntry", "Toolbutton"}
+	largeStylePadding = "10 8"
+)
+
+// applyTargets sets the padding of controls according to the large
+// targets setting. The padding of the ttk styles is recorded the first
+// time it is changed so that it can be restored.
+func (m *miko) applyTargets() {
+	pad := 0
+	if m.config.LargeTargets {
+		pad = largeTargetPadding
+	}
+	for _, w := range m.controls {
+		EvalErr(fmt.Sprintf("grid configure %s -ipadx %d -ipady %d", w, pad, pad))
+	}
+	if m.stylePadding == nil {
+		if !m.config.LargeTargets {
+			return
+		}
+		m.stylePadding = make(map[string]string)
+		for _, style := range targetStyles {
+			m.stylePadding[style] = EvalErr(fmt.Sprintf("ttk::style configure %s -padding", style))
+		}
+	}
+	for _, style := range targetStyles {
+		padding := m.stylePadding[style]
+		if m.config.LargeTargets {
+			padding = largeStylePadding
+		}
+		EvalErr(fmt.Sprintf("ttk::style configure %s -padding {%s}", style, padding))
+	}
+}
+
+// announce shows msg in the announcement region of the status bar. Tk
+// has no accessibility interface, so this region is where run status
+// is reported for screen readers that follow changes to the text of
+// labels.
+func (m *miko) announce(msg string) {
+	m.status.announce.Configure(Txt(msg))
+}
+
+// describe announces name when w gains the keyboard focus, so that
+// controls labelled only by a short word or symbol are identified.
+func (m *miko) describe(w Widget, name string) {
+	Bind(w, "<FocusIn>", Command(func() { m.announce(name) }))
+}
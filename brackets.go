@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "modernc.org/tk9.0"
+)
+
+// rainbowRate is the minimum time between recolourings of a widget.
+const rainbowRate = 250 * time.Millisecond
+
+// bracket is a bracket found in a document by scanBrackets.
+type bracket struct {
+	line, col int  // Text index of the bracket; col counts characters.
+	depth     int  // Nesting depth of the pair, or -1 if unmatched.
+	open      bool // Whether it opens a pair.
+}
+
+// scanBrackets returns the brackets in a CEL or JSON document, ignoring
+// those in strings and comments.
+func scanBrackets(src string) []bracket {
+	var (
+		found []bracket
+		stack []int // Indexes in found of the unclosed brackets.
+		line  = 1
+		col   = 0
+	)
+	// advance moves past the n bytes at i, keeping line and col current.
+	advance := func(i, n int) {
+		for _, c := range src[i:min(i+n, len(src))] {
+			if c == '\n' {
+				line++
+				col = 0
+			} else {
+				col++
+			}
+		}
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		n := 1
+		switch c {
+		case '/':
+			if strings.HasPrefix(src[i:], "//") {
+				n = strings.IndexByte(src[i:], '\n')
+				if n < 0 {
+					return found
+				}
+			}
+		case '"', '\'':
+			raw := i > 0 && (src[i-1] == 'r' || src[i-1] == 'R')
+			quote := string(c)
+			if strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			j := i + len(quote)
+			for j < len(src) && !strings.HasPrefix(src[j:], quote) {
+				if src[j] == '\\' && !raw {
+					j++
+				}
+				j++
+			}
+			n = min(j+len(quote), len(src)) - i
+		case '{', '[', '(':
+			stack = append(stack, len(found))
+			found = append(found, bracket{line: line, col: col, depth: -1, open: true})
+		case '}', ']', ')':
+			b := bracket{line: line, col: col, depth: -1}
+			if len(stack) != 0 {
+				b.depth = len(stack) - 1
+				found[stack[len(stack)-1]].depth = b.depth
+				stack = stack[:len(stack)-1]
+			}
+			found = append(found, b)
+		default:
+			_, n = utf8.DecodeRuneInString(src[i:])
+		}
+		advance(i, n)
+		i += n
+	}
+	return found
+}
+
+// rainbowTags are the tags colouring brackets by their nesting depth.
+var rainbowTags = [6]string{"bracket0", "bracket1", "bracket2", "bracket3", "bracket4", "bracket5"}
+
+// rainbow colours matching brackets in a set of text widgets by their
+// nesting depth, so that the levels of deeply nested programs and
+// payloads are easier to tell apart. Only the foreground is set so that
+// the colours combine with background highlights such as find matches.
+type rainbow struct {
+	shown  bool
+	colors [6]string
+	scope  map[*TextWidget]string // Tag limiting colouring, or "" for all.
+	dirty  map[*TextWidget]bool
+	drawn  time.Time
+}
+
+func newRainbow() *rainbow {
+	return &rainbow{
+		scope: make(map[*TextWidget]string),
+		dirty: make(map[*TextWidget]bool),
+	}
+}
+
+// add starts colouring brackets in w within the ranges of the scope tag,
+// or in the whole text if scope is empty.
+func (r *rainbow) add(w *TextWidget, scope string) {
+	for i, tag := range rainbowTags {
+		w.TagConfigure(tag, Foreground(r.colors[i]))
+	}
+	r.scope[w] = scope
+	r.dirty[w] = true
+}
+
+// setColors changes the colours of the nesting levels.
+func (r *rainbow) setColors(colors [6]string) {
+	r.colors = colors
+	for w := range r.dirty {
+		for i, tag := range rainbowTags {
+			w.TagConfigure(tag, Foreground(r.colors[i]))
+		}
+	}
+}
+
+// toggle applies or removes the colouring on all tracked widgets.
+func (r *rainbow) toggle() {
+	for w := range r.dirty {
+		if r.shown {
+			r.dirty[w] = true
+		} else {
+			for _, tag := range rainbowTags {
+				w.TagRemove(tag, "1.0", "end")
+			}
+		}
+	}
+}
+
+// update recolours widgets whose content has changed. It is intended to
+// be called from the UI ticker.
+func (r *rainbow) update() {
+	if !r.shown || time.Since(r.drawn) < rainbowRate {
+		return
+	}
+	for w, dirty := range r.dirty {
+		if dirty {
+			r.draw(w)
+			r.dirty[w] = false
+			r.drawn = time.Now()
+		}
+	}
+}
+
+func (r *rainbow) draw(w *TextWidget) {
+	for _, tag := range rainbowTags {
+		w.TagRemove(tag, "1.0", "end")
+	}
+	regions := []string{"1.0", "end"}
+	if tag := r.scope[w]; tag != "" {
+		regions = w.TagRanges(tag)
+	}
+	var levels [len(rainbowTags)][]any
+	for i := 0; i+1 < len(regions); i += 2 {
+		var line, col int
+		fmt.Sscanf(w.Index(regions[i]), "%d.%d", &line, &col)
+		for _, b := range scanBrackets(w.Get(regions[i], regions[i+1])[0]) {
+			if b.depth < 0 {
+				continue
+			}
+			if b.line == 1 {
+				b.col += col
+			}
+			b.line += line - 1
+			level := b.depth % len(rainbowTags)
+			levels[level] = append(levels[level],
+				fmt.Sprintf("%d.%d", b.line, b.col),
+				fmt.Sprintf("%d.%d", b.line, b.col+1),
+			)
+		}
+	}
+	for i, ranges := range levels {
+		if len(ranges) != 0 {
+			w.TagAdd(rainbowTags[i], ranges...)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// maxCached is the number of input files kept in the cache.
+const maxCached = 16
+
+// inputCache holds input files written for runs so that an input that
+// has not changed since an earlier run is not written again. Files are
+// named by the hash of their content, so an edited input is always
+// written to a new file.
+type inputCache struct {
+	mu    sync.Mutex
+	dir   string
+	paths []string // Cached files, least recently used first.
+}
+
+// path returns the path of a file holding data, writing it if it is
+// not already cached. The name is used as the file's suffix so that
+// mito can recognise its type.
+func (c *inputCache) path(name string, data []byte) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dir == "" {
+		dir, err := os.MkdirTemp("", "miko-cache-*")
+		if err != nil {
+			return "", err
+		}
+		c.dir = dir
+	}
+	sum := sha256.Sum256(data)
+	path := filepath.Join(c.dir, hex.EncodeToString(sum[:16])+"-"+name)
+	if i := slices.Index(c.paths, path); i >= 0 {
+		c.paths = slices.Delete(c.paths, i, i+1)
+		// The file may have been removed by something other than
+		// miko, so check before reusing it.
+		if _, err := os.Stat(path); err == nil {
+			c.paths = append(c.paths, path)
+			return path, nil
+		}
+	}
+	err := os.WriteFile(path, data, 0o600)
+	if err != nil {
+		return "", err
+	}
+	c.paths = append(c.paths, path)
+	if len(c.paths) > maxCached {
+		// A run still reading an evicted file keeps its open
+		// handle, and its next run writes the file again.
+		os.Remove(c.paths[0])
+		c.paths = c.paths[1:]
+	}
+	return path, nil
+}
+
+// remove removes the cache directory.
+func (c *inputCache) remove() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(c.dir)
+	c.dir = ""
+	c.paths = nil
+	return err
+}
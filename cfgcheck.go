@@ -0,0 +1,246 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	. "modernc.org/tk9.0"
+)
+
+// defaultCfgSchema is the schema of mito's cfg file shipped with miko.
+//
+//go:embed cfgschema.yaml
+var defaultCfgSchema []byte
+
+// cfgTemplate is a commented example cfg file.
+//
+//go:embed cfgtemplate.yaml
+var cfgTemplate string
+
+// insertCfgTemplate replaces the content of the cfg pane with the
+// example cfg, showing the pane if it is hidden. The user is asked
+// before existing content is replaced.
+func (m *miko) insertCfgTemplate() {
+	if strings.TrimSpace(m.cfg.Text()) != "" {
+		ok := MessageBox(
+			Parent(App),
+			Icon("question"),
+			Type("okcancel"),
+			Title("Insert cfg Template"),
+			Msg("Replace the content of the cfg pane with the template?"),
+		)
+		if ok != "ok" {
+			return
+		}
+	}
+	for _, p := range m.panes {
+		if p.text == m.cfg && !p.shown {
+			p.shown = true
+			m.layoutPanes()
+			m.sync()
+		}
+	}
+	setText(m.cfg, cfgTemplate)
+	m.cfg.MarkSet("insert", "1.0")
+	m.cfg.See("insert")
+	Focus(m.cfg)
+}
+
+// cfgSchema describes the allowed values of a cfg file or part of one.
+type cfgSchema struct {
+	Type      string                `yaml:"type"`
+	Values    string                `yaml:"values"` // The type of list elements and map values.
+	Keys      map[string]*cfgSchema `yaml:"keys"`
+	Requires  []string              `yaml:"requires"`
+	Exclusive []string              `yaml:"exclusive"`
+}
+
+// loadCfgSchema returns the cfg schema from cfgschema.yaml in the
+// directory dir if there is one, and the embedded schema otherwise.
+func loadCfgSchema(dir string) (*cfgSchema, error) {
+	b := defaultCfgSchema
+	if dir != "" {
+		custom, err := os.ReadFile(filepath.Join(dir, "cfgschema.yaml"))
+		switch {
+		case err == nil:
+			b = custom
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("cfg schema: %w", err)
+		}
+	}
+	var s cfgSchema
+	err := yaml.Unmarshal(b, &s)
+	if err != nil {
+		return nil, fmt.Errorf("cfg schema: %w", err)
+	}
+	return &s, nil
+}
+
+// checkCfg returns warnings about the parts of the cfg document src that
+// do not follow schema. A document that is not valid YAML gives a
+// single warning.
+func checkCfg(src string, schema *cfgSchema) []lintWarning {
+	if strings.TrimSpace(src) == "" {
+		return nil
+	}
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte(src), &doc)
+	if err != nil {
+		return []lintWarning{{pane: "cfg", line: 1, msg: err.Error()}}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	var warnings []lintWarning
+	var walk func(n *yaml.Node, s *cfgSchema, path string)
+	warn := func(n *yaml.Node, format string, args ...any) {
+		warnings = append(warnings, lintWarning{pane: "cfg", line: n.Line, col: max(n.Column-1, 0), msg: fmt.Sprintf(format, args...)})
+	}
+	walk = func(n *yaml.Node, s *cfgSchema, path string) {
+		if n.Kind == yaml.AliasNode {
+			n = n.Alias
+		}
+		if !cfgType(n, s.Type) {
+			warn(n, "%s should be %s", path, article(s.Type))
+			return
+		}
+		name := func(key string) string {
+			if path == "cfg" {
+				return key
+			}
+			return path + "." + key
+		}
+		switch s.Type {
+		case "list":
+			if s.Values != "" {
+				for _, v := range n.Content {
+					walk(v, &cfgSchema{Type: s.Values}, path+" element")
+				}
+			}
+		case "map":
+			if s.Values != "" {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					walk(n.Content[i+1], &cfgSchema{Type: s.Values}, name(n.Content[i].Value))
+				}
+			}
+		case "object":
+			var present []string
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k, v := n.Content[i], n.Content[i+1]
+				present = append(present, k.Value)
+				ks, ok := s.Keys[k.Value]
+				if !ok {
+					warn(k, "unknown key %s", name(k.Value))
+					continue
+				}
+				walk(v, ks, name(k.Value))
+			}
+			for _, r := range s.Requires {
+				if !slices.Contains(present, r) {
+					warn(n, "%s requires %s", path, r)
+				}
+			}
+			var given []string
+			for _, e := range s.Exclusive {
+				if slices.Contains(present, e) {
+					given = append(given, e)
+				}
+			}
+			if len(given) > 1 {
+				warn(n, "%s should give only one of %s", path, strings.Join(given, ", "))
+			}
+		}
+	}
+	walk(doc.Content[0], schema, "cfg")
+	return warnings
+}
+
+// cfgType reports whether n holds a value of the schema type typ.
+func cfgType(n *yaml.Node, typ string) bool {
+	switch typ {
+	case "string":
+		return n.Kind == yaml.ScalarNode && n.Tag != "!!null"
+	case "int":
+		return n.Kind == yaml.ScalarNode && n.Tag == "!!int"
+	case "bool":
+		return n.Kind == yaml.ScalarNode && n.Tag == "!!bool"
+	case "list":
+		return n.Kind == yaml.SequenceNode
+	case "map", "object":
+		return n.Kind == yaml.MappingNode
+	}
+	return true
+}
+
+// article returns the name of the schema type typ for messages.
+func article(typ string) string {
+	switch typ {
+	case "int":
+		return "an integer"
+	case "object", "map":
+		return "a mapping"
+	}
+	return "a " + typ
+}
+
+// cfgCheckDelay is how long the cfg pane must be left unedited before it
+// is checked.
+const cfgCheckDelay = 500 * time.Millisecond
+
+// cfgCheck marks the lines of the cfg pane that do not follow the
+// schema.
+type cfgCheck struct {
+	schema   *cfgSchema
+	edited   time.Time      // When the cfg pane was last edited, or zero.
+	messages map[int]string // Warnings by line.
+}
+
+// trackCfg loads the cfg schema and checks the cfg pane as it is
+// edited. Pointing at a marked line announces its warning.
+func (m *miko) trackCfg() {
+	c := &m.cfgCheck
+	dir := ""
+	if m.config.path != "" {
+		dir = filepath.Dir(m.config.path)
+	}
+	var err error
+	c.schema, err = loadCfgSchema(dir)
+	if err != nil {
+		m.printError(err)
+		return
+	}
+	c.edited = time.Now()
+	m.onChange(m.cfg, func() { c.edited = time.Now() })
+	m.cfg.TagBind("cfgWarning", "<Enter>", func(e *Event) {
+		line, _ := position(m.cfg.Index(fmt.Sprintf("@%d,%d", e.X, e.Y)))
+		if msg, ok := c.messages[line]; ok {
+			m.announce("cfg: " + msg)
+		}
+	})
+}
+
+// updateCfgCheck checks the cfg pane once editing has paused.
+func (m *miko) updateCfgCheck() {
+	c := &m.cfgCheck
+	if c.schema == nil || c.edited.IsZero() || time.Since(c.edited) < cfgCheckDelay {
+		return
+	}
+	c.edited = time.Time{}
+	m.cfg.TagRemove("cfgWarning", "1.0", "end")
+	c.messages = make(map[int]string)
+	for _, w := range checkCfg(m.cfg.Text(), c.schema) {
+		m.cfg.TagAdd("cfgWarning", fmt.Sprintf("%d.0", w.line), fmt.Sprintf("%d.0+1l", w.line))
+		if c.messages[w.line] != "" {
+			c.messages[w.line] += "; "
+		}
+		c.messages[w.line] += w.msg
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config holds miko settings and UI state that persist between sessions.
+type config struct {
+	// HiddenPanes lists the input panes that are not shown. A nil
+	// list results in the default set of hidden panes.
+	HiddenPanes []string `yaml:"hidden_panes"`
+	// Sashes holds the positions of the sashes between the input panes.
+	Sashes []int `yaml:"input_sashes,omitempty"`
+	// Mito and Celfmt are the paths to the mito and celfmt
+	// executables. If empty, they are found in $PATH.
+	Mito   string `yaml:"mito,omitempty"`
+	Celfmt string `yaml:"celfmt,omitempty"`
+	// TabWidth is the width of tab stops measured in spaces and
+	// PollRate is the output refresh poll rate.
+	TabWidth int           `yaml:"tab_width,omitempty"`
+	PollRate time.Duration `yaml:"poll_rate,omitempty"`
+	// Autosave is the interval between saves of draft inputs for
+	// crash recovery. Zero uses the default and a negative interval
+	// disables drafts.
+	Autosave time.Duration `yaml:"autosave_interval,omitempty"`
+	// EditorFont and DisplayFont are the fonts used by the input
+	// editors and the output display.
+	EditorFont  *fontConfig `yaml:"editor_font,omitempty"`
+	DisplayFont *fontConfig `yaml:"display_font,omitempty"`
+	// ExpandTabs causes the Tab key to insert IndentWidth spaces
+	// in the editors instead of a tab. If IndentWidth is zero,
+	// the tab width is used.
+	ExpandTabs  bool `yaml:"expand_tabs,omitempty"`
+	IndentWidth int  `yaml:"indent_width,omitempty"`
+	// KeepTrailingSpace prevents trailing white space being
+	// removed from the editors by Format.
+	KeepTrailingSpace bool `yaml:"keep_trailing_space,omitempty"`
+	// TrimOnSave removes trailing white space from the program, data
+	// and configuration when the inputs are saved.
+	TrimOnSave bool `yaml:"trim_on_save,omitempty"`
+	// Insecure, LogRequests and HexBinary hold the state of the
+	// toolbar toggles.
+	Insecure    bool `yaml:"insecure,omitempty"`
+	LogRequests bool `yaml:"log_requests,omitempty"`
+	HexBinary   bool `yaml:"hex_binary,omitempty"`
+	// NestedJSON causes string values in the output that hold JSON
+	// objects or arrays to be shown as the decoded values.
+	NestedJSON bool `yaml:"nested_json,omitempty"`
+	// CollapseRepeats causes consecutive identical results to be
+	// shown once with a count.
+	CollapseRepeats bool `yaml:"collapse_repeats,omitempty"`
+	// RawOutput causes each result to be shown as the bytes mito
+	// wrote rather than reformatted.
+	RawOutput bool `yaml:"raw_output,omitempty"`
+	// WrapErrors causes long lines of error output to be wrapped.
+	WrapErrors bool `yaml:"wrap_errors,omitempty"`
+	// Dump is the mito -dump mode used for runs.
+	Dump string `yaml:"dump,omitempty"`
+	// RunOutput is how the output display is prepared for a run.
+	RunOutput string `yaml:"run_output,omitempty"`
+	// MaxOutputLines is the maximum number of lines kept in the
+	// output display. If zero, a default is used.
+	MaxOutputLines int `yaml:"max_output_lines,omitempty"`
+	// Payloads are the named data documents offered above the data
+	// editor.
+	Payloads []payload `yaml:"payloads,omitempty"`
+	// LargeData is the size in bytes of the data pane above which a
+	// warning is shown before a run. Zero uses the default and a
+	// negative size disables the warning.
+	LargeData int `yaml:"large_data_warning,omitempty"`
+	// WorkDir is the working directory for runs. If empty, runs
+	// use the temporary directory holding their inputs.
+	WorkDir string `yaml:"work_dir,omitempty"`
+	// SaveDir is the directory of the file last chosen in a save
+	// dialog, where the next save dialog starts.
+	SaveDir string `yaml:"save_dir,omitempty"`
+	// Theme is the name of a built-in theme or the path of a theme
+	// file. If empty, the light theme is used.
+	Theme string `yaml:"theme,omitempty"`
+	// LargeTargets gives buttons and other controls more padding so
+	// that they are easier to see and click.
+	LargeTargets bool `yaml:"large_targets,omitempty"`
+	// Profile causes runs to write a CPU profile, which is kept with
+	// the run's temporary directory.
+	Profile bool `yaml:"profile,omitempty"`
+	// HoverDelay is how long the pointer rests on a mito function in
+	// the src pane before its description is shown. Zero uses the
+	// default and a negative delay disables hover help.
+	HoverDelay time.Duration `yaml:"hover_delay,omitempty"`
+	// FormatData causes the data pane to be indented as JSON when
+	// typing pauses and its content is valid.
+	FormatData bool `yaml:"format_data,omitempty"`
+	// Mock causes runs to be given the base URL of a local HTTP
+	// server answering from the routes in the mock pane.
+	Mock bool `yaml:"mock,omitempty"`
+	// Buttons are extra toolbar buttons that run commands with the
+	// current inputs.
+	Buttons []toolButton `yaml:"buttons,omitempty"`
+	// PostRun is a command run after each run that succeeds, given
+	// the run's output on its standard input. The placeholder
+	// {output} in its arguments is replaced by the path of a file
+	// holding the output.
+	PostRun []string `yaml:"post_run,omitempty"`
+	// NotifyAfter is how long a run must take for its end to be
+	// notified. Zero uses the default and a negative duration
+	// disables notifications. Notify is how: "desktop" for a desktop
+	// notification, falling back to the bell, or "bell".
+	NotifyAfter time.Duration `yaml:"notify_after,omitempty"`
+	Notify      string        `yaml:"notify,omitempty"`
+	// MaxExecutions, if positive, is the max_executions given to
+	// runs in place of any in the cfg pane, limiting the number of
+	// evaluations of the program.
+	MaxExecutions int `yaml:"max_executions,omitempty"`
+	// FormatBeforeSnarf causes Snarf to offer to format a program or
+	// data that is not formatted before copying it.
+	FormatBeforeSnarf bool `yaml:"format_before_snarf,omitempty"`
+
+	path string
+	// readOnly prevents a malformed configuration file from being
+	// overwritten by the defaults.
+	readOnly bool
+}
+
+// autosave returns the configured auto-save interval.
+func (c *config) autosave() time.Duration {
+	if c.Autosave == 0 {
+		return defaultAutosave
+	}
+	return c.Autosave
+}
+
+// configPath returns the path to the miko configuration file.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "miko", "config.yaml"), nil
+}
+
+// loadConfig reads the configuration file. A missing file is not an
+// error and results in a zero configuration. A malformed file results
+// in a zero configuration that is not saved.
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return &config{}, err
+	}
+	cfg := &config{path: path}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return cfg, err
+	}
+	err = yaml.Unmarshal(b, cfg)
+	if err != nil {
+		return &config{path: path, readOnly: true}, err
+	}
+	return cfg, nil
+}
+
+// save writes the configuration to its file.
+func (c *config) save() error {
+	if c.path == "" {
+		return errors.New("no config path")
+	}
+	if c.readOnly {
+		return nil
+	}
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.path), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, b, 0o600)
+}
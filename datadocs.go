@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "modernc.org/tk9.0"
+)
+
+// dataFlag collects the paths given by repeated -data flags.
+type dataFlag []string
+
+func (f *dataFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *dataFlag) Set(path string) error {
+	*f = append(*f, path)
+	return nil
+}
+
+// dataDoc is one of several data documents that the program can be run
+// against in turn.
+type dataDoc struct {
+	name string
+	text string
+	view view // Scroll position when last shown.
+}
+
+// dataDocs holds the data documents loaded when more than one -data file
+// is given. The data pane holds the text of the current document; the
+// other documents' text is held in docs.
+type dataDocs struct {
+	docs    []dataDoc
+	current int
+	choice  *TComboboxWidget
+	bar     *TFrameWidget // Selector below the data pane, nil for one document.
+	batch   []int         // Documents waiting to be run by Run All.
+}
+
+// setDataDocs loads docs into the data pane, showing a selector below
+// the pane to switch between them and run them all.
+func (m *miko) setDataDocs(docs []dataDoc) {
+	if len(docs) == 0 {
+		return
+	}
+	d := &dataDocs{docs: docs}
+	m.dataDocs = d
+	m.data.Insert("end", docs[0].text)
+	if len(docs) == 1 {
+		return
+	}
+
+	var frame *FrameWidget
+	for _, p := range m.panes {
+		if p.text == m.data {
+			frame = p.frame
+		}
+	}
+	bar := frame.TFrame()
+	names := make([]string, len(docs))
+	for i, doc := range docs {
+		names[i] = doc.name
+	}
+	d.choice = bar.TCombobox(Values(names), State("readonly"), Textvariable(names[0]))
+	Bind(d.choice, "<<ComboboxSelected>>", Command(func() {
+		for i, name := range names {
+			if name == d.choice.Textvariable() {
+				m.selectDataDoc(i)
+				return
+			}
+		}
+	}))
+	Grid(bar.TLabel(Txt("Document")), Row(0), Column(0), Sticky("w"))
+	Grid(d.choice, Row(0), Column(1), Sticky("ew"))
+	Grid(bar.TButton(Txt("Run All"), Command(m.runAll)), Row(0), Column(2))
+	GridColumnConfigure(bar.Window, 1, Weight(1))
+	Grid(bar, Row(3), Column(0), Columnspan(3), Sticky("ew"))
+	d.bar = bar
+}
+
+// uniqueNames returns names for the documents at paths, using the base
+// name where it is unambiguous and the full path otherwise.
+func uniqueNames(paths []string) []string {
+	count := make(map[string]int)
+	for _, p := range paths {
+		count[filepath.Base(p)]++
+	}
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+		if count[names[i]] > 1 {
+			names[i] = p
+		}
+	}
+	return names
+}
+
+// selectDataDoc makes document i current, keeping any edits to the
+// previously current document.
+func (m *miko) selectDataDoc(i int) {
+	d := m.dataDocs
+	if i == d.current {
+		return
+	}
+	d.docs[d.current].text = m.data.Text()
+	d.docs[d.current].view = viewOf(m.data)
+	d.current = i
+	setText(m.data, d.docs[i].text)
+	m.data.MarkSet("insert", "1.0")
+	m.data.See("insert")
+	d.docs[i].view.restore(m.data)
+	d.choice.Configure(Textvariable(d.docs[i].name))
+}
+
+// runAll runs the program against each data document in turn. The runs
+// are started from the ticker by runBatch as each one finishes so that
+// the output of each run is grouped under a header naming its document.
+func (m *miko) runAll() {
+	d := m.dataDocs
+	d.docs[d.current].text = m.data.Text()
+	m.cancel()
+	m.clearErrors()
+	m.srcOffset = 0
+	m.piped = false
+	for i := range d.docs {
+		d.batch = append(d.batch, i)
+	}
+	m.clearOutput()
+	m.runBatch()
+}
+
+// stopBatch abandons any remaining Run All documents.
+func (m *miko) stopBatch() {
+	if m.dataDocs != nil {
+		m.dataDocs.batch = nil
+	}
+}
+
+// runBatch starts the run of the next Run All document once the
+// previous run has finished and its output has been displayed.
+func (m *miko) runBatch() {
+	d := m.dataDocs
+	if d == nil || len(d.batch) == 0 || m.runs.running() || len(m.results) != 0 {
+		return
+	}
+	doc := d.docs[d.batch[0]]
+	d.batch = d.batch[1:]
+	m.dataHeader(doc.name)
+	gen, err := m.runs.begin()
+	m.printError(err)
+	m.printError(m.mito(gen, runOptions{src: m.src.Text(), data: doc.text}))
+}
+
+// dataHeader appends a header for the run of a data document, or of a
+// piped expression, to the output display.
+func (m *miko) dataHeader(name string) {
+	d := m.display
+	d.Configure(State("normal"))
+	defer m.lockDisplay()
+	header := fmt.Sprintf("── %s at %s ──\n", name, time.Now().Format(time.TimeOnly))
+	if d.Index("end-1c") != "1.0" {
+		header = "\n" + header
+	}
+	d.Insert("end", header, "runHeader")
+	m.follow()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// decodeBase64 attempts to decode s as base64 in any of the standard
+// or URL-safe alphabets, with or without padding. Surrounding white
+// space and JSON string quotes are ignored.
+func decodeBase64(s string) ([]byte, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, `"`), `"`)
+	if s == "" {
+		return nil, false
+	}
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	} {
+		b, err := enc.DecodeString(s)
+		if err == nil {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// maxDecompressed limits the size of decompressed data so that a small
+// selection cannot expand into an unbounded amount of output.
+const maxDecompressed = 1 << 20
+
+// decompress decompresses b if it starts with the magic bytes of a gzip
+// stream or the header of a zlib (HTTP deflate) stream, returning the
+// decompressed data and the name of the compression. Output beyond
+// maxDecompressed is truncated.
+func decompress(b []byte) (data []byte, kind string, ok bool) {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch {
+	case len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b:
+		kind = "gzip"
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	case len(b) >= 2 && b[0]&0x0f == 8 && (uint(b[0])<<8|uint(b[1]))%31 == 0:
+		kind = "deflate"
+		r, err = zlib.NewReader(bytes.NewReader(b))
+	default:
+		return nil, "", false
+	}
+	if err != nil {
+		return nil, "", false
+	}
+	data, err = io.ReadAll(io.LimitReader(r, maxDecompressed))
+	if err != nil {
+		return nil, "", false
+	}
+	return data, kind, true
+}
+
+// renderBytes returns b as text if it is valid UTF-8, and as a hex
+// dump otherwise.
+func renderBytes(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return hex.Dump(b)
+}
+
+// jsonString returns the string value of s if it is a quoted JSON string,
+// and s unaltered otherwise.
+func jsonString(s string) string {
+	var str string
+	if json.Unmarshal([]byte(strings.TrimSpace(s)), &str) != nil {
+		return s
+	}
+	return str
+}
+
+// isBinary returns whether s looks like binary data rather than text.
+// Invalid UTF-8 is replaced with U+FFFD during JSON decoding, so the
+// presence of the replacement character is also taken as binary, as
+// are control characters other than common white space.
+func isBinary(s string) bool {
+	if !utf8.ValidString(s) {
+		return true
+	}
+	for _, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			return true
+		case r == '\t', r == '\n', r == '\r':
+		case unicode.IsControl(r):
+			return true
+		}
+	}
+	return false
+}
+
+// hexify returns v with all binary-looking string values replaced with
+// their hex encoding.
+func hexify(v any) any {
+	switch v := v.(type) {
+	case string:
+		if isBinary(v) {
+			return fmt.Sprintf("% x", v)
+		}
+		return v
+	case []any:
+		for i, e := range v {
+			v[i] = hexify(e)
+		}
+		return v
+	case map[string]any:
+		for k, e := range v {
+			v[k] = hexify(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// maxNestedDepth limits how deeply expandJSON descends into a value, so
+// that pathologically nested output is shown unchanged rather than
+// walked.
+const maxNestedDepth = 64
+
+// expandJSON returns v with string values that hold a JSON object or
+// array replaced by the decoded value. Only one level of embedding is
+// expanded: strings within an expanded value are left as they are.
+func expandJSON(v any, depth int) any {
+	if depth > maxNestedDepth {
+		return v
+	}
+	switch v := v.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+			return v
+		}
+		var e any
+		if json.Unmarshal([]byte(s), &e) != nil {
+			return v
+		}
+		return e
+	case []any:
+		for i, e := range v {
+			v[i] = expandJSON(e, depth+1)
+		}
+		return v
+	case map[string]any:
+		for k, e := range v {
+			v[k] = expandJSON(e, depth+1)
+		}
+		return v
+	default:
+		return v
+	}
+}
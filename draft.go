@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"golang.org/x/tools/txtar"
+	"gopkg.in/yaml.v3"
+	. "modernc.org/tk9.0"
+)
+
+// defaultAutosave is the auto-save interval used when none is configured.
+const defaultAutosave = 30 * time.Second
+
+// draft periodically saves the inputs to a txtar file in the
+// configuration directory so that edits can be recovered after a crash.
+// The file is only written when the inputs have changed since the last
+// save.
+type draft struct {
+	path     string
+	interval time.Duration // Auto-save is disabled if not positive.
+	dirty    bool
+	last     time.Time
+}
+
+// draftToggles is the toggle state recorded in a draft's comment.
+type draftToggles struct {
+	Insecure    bool   `yaml:"insecure"`
+	LogRequests bool   `yaml:"log_requests"`
+	HexBinary   bool   `yaml:"hex_binary"`
+	Dump        string `yaml:"dump"`
+}
+
+func newDraft(conf *config) *draft {
+	d := &draft{interval: conf.autosave()}
+	if conf.path != "" {
+		d.path = filepath.Join(filepath.Dir(conf.path), "draft.txtar")
+	}
+	return d
+}
+
+// autosave writes a draft if the inputs have changed and the auto-save
+// interval has passed since the last draft was written.
+func (m *miko) autosave() {
+	d := m.draft
+	if !d.dirty || d.path == "" || d.interval <= 0 || time.Since(d.last) < d.interval {
+		return
+	}
+	d.dirty = false
+	d.last = time.Now()
+	ar := m.archive(false)
+	ar.Comment, _ = yaml.Marshal(draftToggles{
+		Insecure:    m.config.Insecure,
+		LogRequests: m.config.LogRequests,
+		HexBinary:   m.config.HexBinary,
+		Dump:        m.dumpMode(),
+	})
+	err := os.MkdirAll(filepath.Dir(d.path), 0o755)
+	if err == nil {
+		// Write via a temporary file so that a crash while saving
+		// does not leave a truncated draft.
+		tmp := d.path + ".tmp"
+		err = os.WriteFile(tmp, txtar.Format(ar), 0o600)
+		if err == nil {
+			err = os.Rename(tmp, d.path)
+		}
+	}
+	if err != nil {
+		m.printError(fmt.Errorf("saving draft: %w", err))
+	}
+}
+
+// restoreDraft offers to restore the draft left by a previous session
+// that did not exit cleanly. If tabs have been restored the draft is
+// opened in a new tab.
+func (m *miko) restoreDraft() {
+	if m.draft.path == "" {
+		return
+	}
+	fi, err := os.Stat(m.draft.path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			m.printError(fmt.Errorf("reading draft: %w", err))
+		}
+		return
+	}
+	restore := MessageBox(
+		Icon("question"),
+		Type("yesno"),
+		Title("Restore Draft"),
+		Msg("Restore unsaved inputs from the previous session?"),
+		Detail("Draft saved "+fi.ModTime().Format(time.DateTime)+"."),
+	)
+	if restore != "yes" {
+		return
+	}
+	b, err := os.ReadFile(m.draft.path)
+	if err != nil {
+		m.printError(fmt.Errorf("reading draft: %w", err))
+		return
+	}
+	ar := txtar.Parse(b)
+	// The draft is from a session that did not end cleanly, so it is
+	// not among the tabs restored from the last clean exit and is
+	// opened in a tab of its own rather than added to their inputs.
+	if slices.ContainsFunc(m.panes, func(p *pane) bool { return p.text.Text() != "" }) {
+		m.newTab()
+	}
+	m.load(ar)
+	var toggles draftToggles
+	if yaml.Unmarshal(ar.Comment, &toggles) == nil {
+		m.config.Insecure = toggles.Insecure
+		m.config.LogRequests = toggles.LogRequests
+		m.config.HexBinary = toggles.HexBinary
+		m.config.Dump = toggles.Dump
+		m.sync()
+		m.saveConfig()
+	}
+}
+
+// clearDraft removes the draft file.
+func (m *miko) clearDraft() {
+	if m.draft.path == "" {
+		return
+	}
+	err := os.Remove(m.draft.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("removing draft: %v", err)
+	}
+}
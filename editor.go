@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// changeProxy wraps a text widget's command so that a <<Change>> virtual
+// event is generated whenever its content is altered, whether by the
+// user, by undo/redo or programmatically.
+const changeProxy = `
+rename %[1]s %[1]s_orig
+proc %[1]s args {
+	set r [uplevel 1 [list %[1]s_orig {*}$args]]
+	switch -- [lindex $args 0] {
+		insert - delete - replace {
+			event generate %[1]s <<Change>> -when tail
+		}
+		edit {
+			if {[lindex $args 1] in {undo redo}} {
+				event generate %[1]s <<Change>> -when tail
+			}
+		}
+	}
+	return $r
+}
+`
+
+// onChange registers fn to be called after the content of w changes.
+func (m *miko) onChange(w *TextWidget, fn func()) {
+	if m.changeHooks == nil {
+		m.changeHooks = make(map[*TextWidget][]func())
+	}
+	if _, ok := m.changeHooks[w]; !ok {
+		EvalErr(fmt.Sprintf(changeProxy, w))
+		Bind(w, "<<Change>>", Command(func() {
+			for _, fn := range m.changeHooks[w] {
+				fn()
+			}
+		}))
+	}
+	m.changeHooks[w] = append(m.changeHooks[w], fn)
+}
+
+// menuVars is used to generate unique Tcl variable names for menu
+// checkbuttons.
+var menuVars int
+
+// menuCheck adds a checkbutton entry labelled lbl to menu reflecting
+// the state of *v. Invoking the entry toggles *v and then calls fn if
+// it is not nil.
+func (m *miko) menuCheck(menu *MenuWidget, lbl string, v *bool, fn func()) *MenuItem {
+	item := menu.AddCheckbutton(Lbl(lbl), Command(func() {
+		*v = !*v
+		if fn != nil {
+			fn()
+		}
+	}))
+	menuVars++
+	name := fmt.Sprintf("::mikoMenuVar%d", menuVars)
+	EvalErr(fmt.Sprintf("%s entryconfigure %s -variable %s", menu, item, name))
+	m.addSync(func() { EvalErr(fmt.Sprintf("set %s %d", name, btoi(*v))) })
+	return item
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// menuRadio adds a radiobutton entry to menu for each of labels, with
+// the label returned by current selected. Selecting an entry calls fn
+// with its label.
+func (m *miko) menuRadio(menu *MenuWidget, labels []string, current func() string, fn func(string)) {
+	menuVars++
+	name := fmt.Sprintf("::mikoMenuVar%d", menuVars)
+	for _, lbl := range labels {
+		item := menu.AddRadiobutton(Lbl(lbl), Command(func() { fn(lbl) }))
+		EvalErr(fmt.Sprintf("%s entryconfigure %s -variable %s -value %s", menu, item, name, lbl))
+	}
+	m.addSync(func() { EvalErr(fmt.Sprintf("set %s %s", name, current())) })
+}
+
+// addSync registers fn to update a control from the state it reflects,
+// and calls it to set the control's initial state.
+func (m *miko) addSync(fn func()) {
+	m.syncs = append(m.syncs, fn)
+	fn()
+}
+
+// sync updates all registered controls after state has been changed
+// elsewhere, for example by the Settings dialog.
+func (m *miko) sync() {
+	for _, fn := range m.syncs {
+		fn()
+	}
+}
+
+// column returns the visual column at the end of line given tab stops
+// every tw columns.
+func column(line string, tw int) int {
+	col := 0
+	for _, c := range line {
+		if c == '\t' {
+			col += tw - col%tw
+		} else {
+			col++
+		}
+	}
+	return col
+}
+
+// expandTabs replaces the tabs in text with spaces, preserving the
+// alignment given tab stops every tw columns.
+func expandTabs(text string, tw int) string {
+	if !strings.Contains(text, "\t") {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		var buf strings.Builder
+		col := 0
+		for _, c := range line {
+			if c == '\t' {
+				n := tw - col%tw
+				buf.WriteString(strings.Repeat(" ", n))
+				col += n
+				continue
+			}
+			buf.WriteRune(c)
+			col++
+		}
+		lines[i] = buf.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// insertTab handles the Tab key in the editors. When tabs are expanded
+// it inserts spaces up to the next indent stop and prevents the default
+// tab insertion.
+func (m *miko) insertTab(w *TextWidget, e *Event) {
+	if !m.config.ExpandTabs {
+		return
+	}
+	if len(w.TagRanges("sel")) != 0 {
+		w.Delete("sel.first", "sel.last")
+	}
+	width := m.indentWidth()
+	col := column(w.Get("insert linestart", "insert")[0], m.editorFont.tw)
+	w.Insert("insert", strings.Repeat(" ", width-col%width))
+	w.See("insert")
+	e.SetReturnCodeBreak()
+}
+
+// indentWidth returns the number of spaces inserted for a tab when tabs
+// are expanded.
+func (m *miko) indentWidth() int {
+	if m.config.IndentWidth > 0 {
+		return m.config.IndentWidth
+	}
+	return m.editorFont.tw
+}
+
+// convertTabs replaces all tabs in w with spaces.
+func (m *miko) convertTabs(w *TextWidget) {
+	text := w.Text()
+	expanded := expandTabs(text, m.editorFont.tw)
+	if expanded == text {
+		return
+	}
+	setText(w, expanded)
+}
+
+// format formats the program with celfmt, the data as indented JSON
+// and the trailing white space of the configuration, reporting the
+// first error.
+func (m *miko) format() {
+	src, err := m.celfmt()
+	if err != nil {
+		m.printError(err)
+		return
+	}
+	if src != "" {
+		m.formatted(m.src, src)
+	}
+	data, err := m.jsonfmt()
+	if err != nil {
+		m.printError(err)
+		return
+	}
+	if data != "" {
+		m.formatted(m.data, data)
+	}
+	if cfg := m.cfg.Text(); cfg != "" {
+		m.formatted(m.cfg, cfg)
+	}
+}
+
+// unformatted returns the names of the program and data panes whose
+// content format would change. Content that cannot be formatted, such
+// as a program with a syntax error, is not reported.
+func (m *miko) unformatted() []string {
+	var names []string
+	for _, f := range []struct {
+		name string
+		w    *TextWidget
+		fmt  func() (string, error)
+	}{
+		{"src", m.src, m.celfmt},
+		{"data", m.data, m.jsonfmt},
+	} {
+		text, err := f.fmt()
+		if err != nil || text == "" {
+			continue
+		}
+		if !m.config.KeepTrailingSpace {
+			text = trimTrailingSpace(text)
+		}
+		if text != f.w.Text() {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// formatted replaces the content of w with text, the result of a
+// format operation, removing trailing white space from each line
+// unless configured otherwise. The replacement is a single undoable
+// edit and is skipped if it would not change the content.
+func (m *miko) formatted(w *TextWidget, text string) {
+	if !m.config.KeepTrailingSpace {
+		text = trimTrailingSpace(text)
+	}
+	if text == w.Text() {
+		return
+	}
+	setText(w, text)
+}
+
+// trimSaved removes trailing white space from each line of the program,
+// data and configuration before they are saved. Each pane that changes
+// is changed by a single undoable edit.
+func (m *miko) trimSaved() {
+	for _, w := range []*TextWidget{m.src, m.data, m.cfg} {
+		text := w.Text()
+		if trimmed := trimTrailingSpace(text); trimmed != text {
+			setText(w, trimmed)
+		}
+	}
+}
+
+// trimTrailingSpace removes trailing white space from each line of text.
+func trimTrailingSpace(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
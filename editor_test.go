@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+var trimTrailingSpaceTests = []struct {
+	name string
+	text string
+	want string
+}{
+	{name: "empty", text: "", want: ""},
+	{name: "clean", text: "a\nb\n", want: "a\nb\n"},
+	{name: "spaces_and_tabs", text: "a \t\nb  \n", want: "a\nb\n"},
+	{name: "crlf", text: "a \r\nb\r\n", want: "a\nb\n"},
+	{name: "last_line", text: "a\nb  ", want: "a\nb"},
+	{name: "leading_kept", text: "  a  \n\tb\t\n", want: "  a\n\tb\n"},
+	{name: "blank_lines", text: "a\n   \n\t\nb", want: "a\n\n\nb"},
+}
+
+func TestTrimTrailingSpace(t *testing.T) {
+	for _, test := range trimTrailingSpaceTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := trimTrailingSpace(test.text)
+			if got != test.want {
+				t.Errorf("unexpected result: got:%q want:%q", got, test.want)
+			}
+		})
+	}
+}
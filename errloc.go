@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// srcLocation matches a location in the program in mito's error
+// messages. Locations are reported against the src.cel file miko
+// writes, or against <input>.
+var srcLocation = regexp.MustCompile(`(?:<input>|src\.cel):(\d+):(\d+)`)
+
+// errorLocation returns the line and column of the program location in
+// an error message.
+func errorLocation(msg string) (line, col int, ok bool) {
+	m := srcLocation.FindStringSubmatch(msg)
+	if m == nil {
+		return 0, 0, false
+	}
+	line, _ = strconv.Atoi(m[1])
+	col, _ = strconv.Atoi(m[2])
+	return line, col, true
+}
+
+// errorKind classifies stderr lines that refer to a location in the
+// program as compile or runtime errors. mito reports evaluation
+// failures with "failed eval", and subsequent lines belong to the same
+// error.
+type errorKind struct {
+	runtime bool
+}
+
+// tag returns the display tag for a stderr line.
+func (k *errorKind) tag(line string) string {
+	if strings.Contains(line, "failed eval") {
+		k.runtime = true
+	}
+	if _, _, ok := errorLocation(line); !ok {
+		return "error"
+	}
+	if k.runtime {
+		return "runtimeError"
+	}
+	return "compileError"
+}
+
+// configureErrors sets up the tags for errors with a location in the
+// program. Compile errors are red and runtime errors orange, both in
+// the display and on the line of the program they refer to. Clicking
+// an error moves the src cursor to its location.
+func (m *miko) configureErrors() {
+	d := m.display
+	d.TagConfigure("compileError", Underline(true))
+	d.TagConfigure("runtimeError", Underline(true))
+	for _, tag := range []string{"compileError", "runtimeError"} {
+		d.TagBind(tag, "<Button-1>", func() {
+			line, col, ok := errorLocation(d.Get("current linestart", "current lineend")[0])
+			if ok {
+				m.goToError(line, col)
+			}
+		})
+	}
+	m.wrapErrors()
+}
+
+// wrapErrors sets whether lines of error output are wrapped at word
+// boundaries. Other output is not wrapped so that it keeps its layout.
+func (m *miko) wrapErrors() {
+	wrap := ""
+	if m.config.WrapErrors {
+		wrap = "word"
+	}
+	for _, tag := range errorTags {
+		m.display.TagConfigure(tag, Wrap(wrap))
+	}
+}
+
+// errorTags are the display tags of lines of error output.
+var errorTags = []string{"error", "compileError", "runtimeError"}
+
+// nextError moves the display's insertion mark to the start of the next
+// line of error output after it, or the previous one before it if
+// forward is false, wrapping around at the end, and scrolls the line
+// into view. The line is marked until the next move.
+func (m *miko) nextError(forward bool) {
+	d := m.display
+	from, wrap, search := "insert lineend", "1.0", "nextrange"
+	if !forward {
+		from, wrap, search = "insert linestart", "end", "prevrange"
+	}
+	find := func(from string) string {
+		var best string
+		for _, tag := range errorTags {
+			r := strings.Fields(EvalErr(fmt.Sprintf("%s tag %s %s {%s}", d, search, tag, from)))
+			if len(r) != 2 {
+				continue
+			}
+			if best == "" || (EvalErr(fmt.Sprintf("%s compare %s < %s", d, r[0], best)) == "1") == forward {
+				best = r[0]
+			}
+		}
+		return best
+	}
+	start := find(from)
+	if start == "" {
+		start = find(wrap)
+	}
+	if start == "" {
+		Bell()
+		return
+	}
+	d.MarkSet("insert", start)
+	d.TagRemove("errorCurrent", "1.0", "end")
+	d.TagAdd("errorCurrent", start+" linestart", start+" lineend+1c")
+	d.See(start)
+}
+
+// markError highlights the line of the program that the error t refers
+// to.
+func (m *miko) markError(t text) {
+	line, _, ok := errorLocation(t.data)
+	// Errors in a piped expression are not in the src pane.
+	if !ok || m.piped {
+		return
+	}
+	line += m.srcOffset
+	m.src.TagAdd(t.tag+"Line", fmt.Sprintf("%d.0", line), fmt.Sprintf("%d.0", line+1))
+}
+
+// clearErrors removes the error highlighting from the program.
+func (m *miko) clearErrors() {
+	m.src.TagRemove("compileErrorLine", "1.0", "end")
+	m.src.TagRemove("runtimeErrorLine", "1.0", "end")
+}
+
+// goToError moves the src cursor to a location reported by mito.
+func (m *miko) goToError(line, col int) {
+	if m.piped {
+		Bell()
+		return
+	}
+	line += m.srcOffset
+	w := m.src
+	w.MarkSet("insert", fmt.Sprintf("%d.%d", line, max(col-1, 0)))
+	w.See("insert")
+	Focus(w)
+	m.status.dirty = true
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+	. "modernc.org/tk9.0"
+)
+
+// exampleFiles holds the example sessions offered in the Examples menu.
+// Each is a txtar archive like those read with -txtar, with a short
+// description as its comment.
+//
+//go:embed examples/*.txtar
+var exampleFiles embed.FS
+
+// example is an example session.
+type example struct {
+	title       string
+	description string
+	archive     *txtar.Archive
+}
+
+// examples returns the embedded examples in name order.
+func examples() ([]example, error) {
+	entries, err := exampleFiles.ReadDir("examples")
+	if err != nil {
+		return nil, err
+	}
+	var ex []example
+	for _, e := range entries {
+		b, err := exampleFiles.ReadFile(path.Join("examples", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ar := txtar.Parse(b)
+		name := strings.TrimSuffix(e.Name(), ".txtar")
+		ex = append(ex, example{
+			title:       strings.ToUpper(name[:1]) + strings.ReplaceAll(name[1:], "_", " "),
+			description: strings.Join(strings.Fields(string(ar.Comment)), " "),
+			archive:     ar,
+		})
+	}
+	return ex, nil
+}
+
+// examplesMenu returns a menu that loads each example into the
+// editors.
+func (m *miko) examplesMenu(parent *MenuWidget) *MenuWidget {
+	menu := parent.Menu()
+	ex, err := examples()
+	if err != nil {
+		m.printError(fmt.Errorf("examples: %w", err))
+		return menu
+	}
+	for _, e := range ex {
+		menu.AddCommand(Lbl(e.title+"..."), Command(func() { m.showExample(e) }))
+	}
+	return menu
+}
+
+// showExample describes e and offers to load it, replacing the inputs.
+func (m *miko) showExample(e example) {
+	var names []string
+	for _, f := range e.archive.Files {
+		names = append(names, f.Name)
+	}
+	detail := e.description + "\n\nLoads " + strings.Join(names, ", ") + "."
+	if modified := m.modified(); len(modified) != 0 {
+		detail += fmt.Sprintf(" Unsaved changes in %s will be replaced.", strings.Join(modified, ", "))
+	}
+	ok := MessageBox(
+		Parent(App),
+		Icon("info"),
+		Type("okcancel"),
+		Title("Examples"),
+		Msg("Load the "+strings.ToLower(e.title)+" example?"),
+		Detail(detail),
+	)
+	if ok != "ok" {
+		return
+	}
+	for _, p := range m.panes {
+		p.text.Delete("1.0", "end")
+	}
+	m.load(e.archive)
+	m.setModified(false)
+	m.src.MarkSet("insert", "1.0")
+	Focus(m.src)
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// findOptions controls how find matches text.
+type findOptions struct {
+	matchCase bool
+	regexp    bool
+	// preserveCase makes replacements follow the case pattern of
+	// the text they replace.
+	preserveCase bool
+}
+
+// match is a match in a text, as byte offsets.
+type match struct {
+	start, end int
+}
+
+// compileFind returns a regular expression matching pattern according
+// to opts.
+func compileFind(pattern string, opts findOptions) (*regexp.Regexp, error) {
+	if !opts.regexp {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !opts.matchCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// findAll returns the non-empty matches of re in text.
+func findAll(re *regexp.Regexp, text string) []match {
+	var matches []match
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] != loc[1] {
+			matches = append(matches, match{start: loc[0], end: loc[1]})
+		}
+	}
+	return matches
+}
+
+// replacement returns the replacement for the text matched by re, which
+// must match all of matched. In regexp mode, $1 style references in
+// repl are expanded.
+func replacement(re *regexp.Regexp, matched, repl string, opts findOptions) string {
+	if opts.regexp {
+		repl = re.ReplaceAllString(matched, repl)
+	}
+	if opts.preserveCase {
+		repl = applyCase(matched, repl)
+	}
+	return repl
+}
+
+// replaceAll returns text with all matches of re replaced by repl, and
+// the number of replacements made. As with findAll, empty matches are
+// left alone.
+func replaceAll(re *regexp.Regexp, text, repl string, opts findOptions) (string, int) {
+	var (
+		buf  strings.Builder
+		n    int
+		last int // End of the previous match.
+	)
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		r := repl
+		if opts.regexp {
+			r = string(re.ExpandString(nil, repl, text, loc))
+		}
+		if opts.preserveCase {
+			r = applyCase(text[loc[0]:loc[1]], r)
+		}
+		buf.WriteString(text[last:loc[0]])
+		buf.WriteString(r)
+		last = loc[1]
+		n++
+	}
+	if n == 0 {
+		return text, 0
+	}
+	buf.WriteString(text[last:])
+	return buf.String(), n
+}
+
+// applyCase returns repl in the case pattern of matched: upper case if
+// matched is all upper case, lower case if it is all lower case, and
+// with an upper case first letter if only its first letter is upper
+// case. Otherwise repl is returned unchanged.
+func applyCase(matched, repl string) string {
+	var upper, lower int
+	for _, r := range matched {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	first, _ := utf8.DecodeRuneInString(matched)
+	switch {
+	case upper != 0 && lower == 0:
+		return strings.ToUpper(repl)
+	case lower != 0 && upper == 0:
+		return strings.ToLower(repl)
+	case upper == 1 && unicode.IsUpper(first):
+		r, size := utf8.DecodeRuneInString(repl)
+		return string(unicode.ToUpper(r)) + repl[size:]
+	default:
+		return repl
+	}
+}
+
+// indexer converts byte offsets in a text into text widget indices.
+// Offsets must be given in increasing order.
+type indexer struct {
+	text string
+	off  int // Byte offset of line and col.
+	line int
+	col  int // Column in characters.
+}
+
+func newIndexer(text string) *indexer {
+	return &indexer{text: text, line: 1}
+}
+
+// index returns the text widget index of the byte offset off.
+func (ix *indexer) index(off int) string {
+	for _, r := range ix.text[ix.off:off] {
+		if r == '\n' {
+			ix.line++
+			ix.col = 0
+		} else {
+			ix.col++
+		}
+	}
+	ix.off = off
+	return fmt.Sprintf("%d.%d", ix.line, ix.col)
+}
+
+// indices returns the text widget indices in text of the starts and
+// ends of spans, by byte offset. Spans may overlap.
+func indices(text string, spans []span) map[int]string {
+	offsets := make([]int, 0, 2*len(spans))
+	for _, sp := range spans {
+		offsets = append(offsets, sp.start, sp.end)
+	}
+	slices.Sort(offsets)
+	ix := newIndexer(text)
+	at := make(map[int]string, len(offsets))
+	for _, off := range offsets {
+		if _, ok := at[off]; !ok {
+			at[off] = ix.index(off)
+		}
+	}
+	return at
+}
+
+// shiftIndex returns the text widget index lines below index.
+func shiftIndex(index string, lines int) string {
+	line, col := position(index)
+	return fmt.Sprintf("%d.%d", line+lines, col)
+}
+
+// offset returns the byte offset in w's text of index.
+func offset(w *TextWidget, index string) int {
+	return len(strings.Join(w.Get("1.0", index), ""))
+}
+
+// setText replaces the content of w with text as a single undoable
+// edit, keeping the insertion cursor and the scroll position in place.
+func setText(w *TextWidget, text string) {
+	insert := w.Index("insert")
+	v := viewOf(w)
+	w.Replace("1.0", "end-1c", text)
+	w.MarkSet("insert", insert)
+	v.restore(w)
+}
+
+// view is the scroll position of a text widget as the fractions of its
+// content to the left of and above the visible part.
+type view struct {
+	x, y string
+}
+
+// viewOf returns the scroll position of w.
+func viewOf(w *TextWidget) view {
+	first := func(axis string) string {
+		f := strings.Fields(EvalErr(fmt.Sprintf("%s %s", w, axis)))
+		if len(f) == 0 {
+			return "0"
+		}
+		return f[0]
+	}
+	return view{x: first("xview"), y: first("yview")}
+}
+
+// restore scrolls w to v. Positions beyond the end of shorter content
+// are clamped by the widget. The zero view leaves w unchanged.
+func (v view) restore(w *TextWidget) {
+	if v == (view{}) {
+		return
+	}
+	EvalErr(fmt.Sprintf("%s xview moveto %s", w, v.x))
+	EvalErr(fmt.Sprintf("%s yview moveto %s", w, v.y))
+}
+
+// selectMatch selects the text between byte offsets start and end in w
+// and moves the insertion cursor to the end of the selection.
+func selectMatch(w *TextWidget, text string, start, end int) {
+	ix := newIndexer(text)
+	first := ix.index(start)
+	last := ix.index(end)
+	w.TagRemove("sel", "1.0", "end")
+	w.TagAdd("sel", first, last)
+	w.MarkSet("insert", last)
+	w.See(first)
+}
+
+// findBar is the find and replace bar for the focused editor.
+type findBar struct {
+	frame     *TFrameWidget
+	find      *TEntryWidget
+	replace   *TEntryWidget
+	matchCase *TCheckbuttonWidget
+	regexp    *TCheckbuttonWidget
+	keepCase  *TCheckbuttonWidget
+	message   *TLabelWidget
+	remove    func() // Removes the bar from the overlay stack.
+	dirty     bool   // The match highlighting needs updating.
+}
+
+func (m *miko) newFindBar(parent *Window) *findBar {
+	frame := parent.TFrame(Padding("2"))
+	f := &findBar{
+		frame:     frame,
+		find:      frame.TEntry(Textvariable("")),
+		replace:   frame.TEntry(Textvariable("")),
+		matchCase: frame.TCheckbutton(Txt("Aa"), Variable(0)),
+		regexp:    frame.TCheckbutton(Txt(".*"), Variable(0)),
+		keepCase:  frame.TCheckbutton(Txt("Keep Case"), Variable(0)),
+		message:   frame.TLabel(),
+	}
+	Grid(frame.TLabel(Txt("Find")), Row(0), Column(0), Sticky("w"))
+	Grid(f.find, Row(0), Column(1), Sticky("ew"))
+	Grid(f.matchCase, Row(0), Column(2))
+	Grid(f.regexp, Row(0), Column(3))
+	Grid(frame.TButton(Txt("Prev"), Command(func() { m.findNext(false) })), Row(0), Column(4), Sticky("ew"))
+	Grid(frame.TButton(Txt("Next"), Command(func() { m.findNext(true) })), Row(0), Column(5), Sticky("ew"))
+	Grid(f.message, Row(0), Column(6), Sticky("w"), Padx("4"))
+	Grid(frame.TLabel(Txt("Replace")), Row(1), Column(0), Sticky("w"))
+	Grid(f.replace, Row(1), Column(1), Sticky("ew"))
+	Grid(f.keepCase, Row(1), Column(2), Columnspan(2), Sticky("w"))
+	Grid(frame.TButton(Txt("Replace"), Command(m.replaceNext)), Row(1), Column(4), Sticky("ew"))
+	Grid(frame.TButton(Txt("All"), Command(m.replaceAll)), Row(1), Column(5), Sticky("ew"))
+	GridColumnConfigure(frame.Window, 1, Weight(1))
+	m.bindKey(f.find, "<Return>", shortcut{"Navigate", "Return", "Go to the next match in the find bar"}, func() { m.findNext(true) })
+	m.bindKey(f.find, "<Shift-Return>", shortcut{"Navigate", "Shift+Return", "Go to the previous match in the find bar"}, func() { m.findNext(false) })
+	m.bindKey(f.replace, "<Return>", shortcut{"Edit", "Return", "Replace the current match in the find bar"}, m.replaceNext)
+	// Matches are highlighted as the pattern is typed.
+	Bind(f.find, "<KeyRelease>", Command(func() { f.dirty = true }))
+	for _, c := range []*TCheckbuttonWidget{f.matchCase, f.regexp} {
+		c.Configure(Command(func() { f.dirty = true }))
+	}
+	return f
+}
+
+// options returns the find options selected in the bar.
+func (f *findBar) options() findOptions {
+	return findOptions{
+		matchCase:    f.matchCase.Variable() == "1",
+		regexp:       f.regexp.Variable() == "1",
+		preserveCase: f.keepCase.Variable() == "1",
+	}
+}
+
+// showFind shows the find bar, starting with the selection in the
+// focused editor if there is one.
+func (m *miko) showFind() {
+	f := m.find
+	if f.remove == nil {
+		Grid(f.frame, Row(3), Column(0), Sticky("ew"))
+		f.remove = m.pushOverlay(overlayBar, m.hideFind)
+	}
+	if m.editor != nil {
+		if sel := selection(m.editor); sel != "" && !strings.Contains(sel, "\n") {
+			f.find.Configure(Textvariable(sel))
+		}
+	}
+	f.message.Configure(Txt(""))
+	f.dirty = true
+	Focus(f.find)
+	EvalErr(fmt.Sprintf("%s selection range 0 end", f.find))
+}
+
+// hideFind hides the find bar and returns focus to the editor.
+func (m *miko) hideFind() {
+	f := m.find
+	if f.remove == nil {
+		return
+	}
+	f.remove()
+	f.remove = nil
+	GridForget(f.frame.Window)
+	m.highlightMatches(nil, -1)
+	if m.editor != nil {
+		Focus(m.editor)
+	}
+}
+
+// findTags are the tags used to highlight find matches, with the current
+// match emphasized.
+var findTags = []string{"findMatch", "findCurrent"}
+
+// highlightMatches highlights matches in the focused editor, with the
+// match at index cur emphasized, and removes the highlighting from the
+// other editors. The highlighting is removed from all editors if
+// matches is nil.
+func (m *miko) highlightMatches(matches []match, cur int) {
+	for _, p := range m.panes {
+		for _, tag := range findTags {
+			p.text.TagRemove(tag, "1.0", "end")
+		}
+	}
+	w := m.editor
+	if len(matches) == 0 || w == nil {
+		return
+	}
+	ix := newIndexer(w.Text())
+	for i, mt := range matches {
+		tag := "findMatch"
+		if i == cur {
+			tag = "findCurrent"
+		}
+		w.TagAdd(tag, ix.index(mt.start), ix.index(mt.end))
+	}
+}
+
+// countMatches reports the position of the current match in the find
+// bar's message.
+func (f *findBar) countMatches(matches []match, cur int) {
+	switch {
+	case len(matches) == 0:
+		f.message.Configure(Txt("No matches"))
+	case cur < 0:
+		f.message.Configure(Txt(fmt.Sprintf("%d matches", len(matches))))
+	default:
+		f.message.Configure(Txt(fmt.Sprintf("%d of %d", cur+1, len(matches))))
+	}
+}
+
+// updateFind refreshes the match highlighting while the find bar is
+// open and the pattern, options or text have changed.
+func (m *miko) updateFind() {
+	f := m.find
+	if f.remove == nil || !f.dirty {
+		return
+	}
+	f.dirty = false
+	re, _, ok := m.findRegexp()
+	if !ok {
+		m.highlightMatches(nil, -1)
+		if f.find.Textvariable() == "" {
+			f.message.Configure(Txt(""))
+		}
+		return
+	}
+	w := m.editor
+	matches := findAll(re, w.Text())
+	cur := -1
+	if len(w.TagRanges("sel")) != 0 {
+		start, end := offset(w, "sel.first"), offset(w, "sel.last")
+		cur = slices.Index(matches, match{start: start, end: end})
+	}
+	m.highlightMatches(matches, cur)
+	f.countMatches(matches, cur)
+}
+
+// findRegexp returns the compiled pattern from the find bar, reporting
+// any error in the bar.
+func (m *miko) findRegexp() (*regexp.Regexp, findOptions, bool) {
+	f := m.find
+	pattern := f.find.Textvariable()
+	if pattern == "" || m.editor == nil {
+		return nil, findOptions{}, false
+	}
+	opts := f.options()
+	re, err := compileFind(pattern, opts)
+	if err != nil {
+		f.message.Configure(Txt(err.Error()))
+		return nil, opts, false
+	}
+	return re, opts, true
+}
+
+// findNext selects the next match in the focused editor after the
+// insertion cursor, or the previous match before the selection if
+// forward is false, wrapping around the ends of the text.
+func (m *miko) findNext(forward bool) {
+	re, _, ok := m.findRegexp()
+	if !ok {
+		return
+	}
+	w := m.editor
+	text := w.Text()
+	matches := findAll(re, text)
+	if len(matches) == 0 {
+		m.highlightMatches(nil, -1)
+		m.find.countMatches(nil, -1)
+		return
+	}
+	var next int
+	if forward {
+		pos := offset(w, "insert")
+		for i, mt := range matches {
+			if mt.start >= pos {
+				next = i
+				break
+			}
+		}
+	} else {
+		pos := offset(w, "insert")
+		if len(w.TagRanges("sel")) != 0 {
+			pos = offset(w, "sel.first")
+		}
+		next = len(matches) - 1
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i].start < pos {
+				next = i
+				break
+			}
+		}
+	}
+	selectMatch(w, text, matches[next].start, matches[next].end)
+	m.highlightMatches(matches, next)
+	m.find.countMatches(matches, next)
+	m.find.dirty = false
+}
+
+// replaceNext replaces the selection if it is a match and then selects
+// the next match.
+func (m *miko) replaceNext() {
+	re, opts, ok := m.findRegexp()
+	if !ok {
+		return
+	}
+	w := m.editor
+	if sel := selection(w); sel != "" {
+		if loc := re.FindStringIndex(sel); loc != nil && loc[0] == 0 && loc[1] == len(sel) {
+			w.Replace("sel.first", "sel.last", replacement(re, sel, m.find.replace.Textvariable(), opts))
+		}
+	}
+	m.findNext(true)
+}
+
+// replaceAll replaces all matches in the focused editor.
+func (m *miko) replaceAll() {
+	re, opts, ok := m.findRegexp()
+	if !ok {
+		return
+	}
+	w := m.editor
+	text, n := replaceAll(re, w.Text(), m.find.replace.Textvariable(), opts)
+	if n != 0 {
+		setText(w, text)
+	}
+	m.find.message.Configure(Txt(fmt.Sprintf("%d replaced", n)))
+}
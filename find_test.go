@@ -0,0 +1,104 @@
+package main
+
+import "testing"
+
+var replaceAllTests = []struct {
+	name    string
+	pattern string
+	text    string
+	repl    string
+	opts    findOptions
+	want    string
+	wantN   int
+}{
+	{
+		name:    "literal",
+		pattern: "a.b",
+		text:    "a.b axb A.B",
+		repl:    "x",
+		want:    "x axb x",
+		wantN:   2,
+	},
+	{
+		name:    "regexp_expand",
+		pattern: `(\w+)=(\w+)`,
+		text:    "a=1, b=2",
+		repl:    "$2=$1",
+		opts:    findOptions{regexp: true},
+		want:    "1=a, 2=b",
+		wantN:   2,
+	},
+	{
+		name:    "empty_matches",
+		pattern: "a*",
+		text:    "bab",
+		repl:    "X",
+		opts:    findOptions{regexp: true},
+		want:    "bXb",
+		wantN:   1,
+	},
+	{
+		name:    "only_empty_matches",
+		pattern: "x*",
+		text:    "bab",
+		repl:    "X",
+		opts:    findOptions{regexp: true},
+		want:    "bab",
+		wantN:   0,
+	},
+	{
+		name:    "empty_matches_preserve_case",
+		pattern: "a*",
+		text:    "bAAb",
+		repl:    "x",
+		opts:    findOptions{regexp: true, preserveCase: true},
+		want:    "bXb",
+		wantN:   1,
+	},
+}
+
+func TestReplaceAll(t *testing.T) {
+	for _, test := range replaceAllTests {
+		t.Run(test.name, func(t *testing.T) {
+			re, err := compileFind(test.pattern, test.opts)
+			if err != nil {
+				t.Fatalf("unexpected error compiling pattern: %v", err)
+			}
+			got, n := replaceAll(re, test.text, test.repl, test.opts)
+			if got != test.want || n != test.wantN {
+				t.Errorf("unexpected result: got:%q (%d) want:%q (%d)", got, n, test.want, test.wantN)
+			}
+			if n != len(findAll(re, test.text)) {
+				t.Errorf("replacement count %d differs from match count %d", n, len(findAll(re, test.text)))
+			}
+		})
+	}
+}
+
+var applyCaseTests = []struct {
+	matched string
+	repl    string
+	want    string
+}{
+	{matched: "hello", repl: "World", want: "world"},
+	{matched: "HELLO", repl: "World", want: "WORLD"},
+	{matched: "Hello", repl: "world", want: "World"},
+	{matched: "HeLLo", repl: "world", want: "world"},
+	{matched: "hELLO", repl: "World", want: "World"},
+	{matched: "Hi", repl: "greetings", want: "Greetings"},
+	{matched: "HI", repl: "greetings", want: "GREETINGS"},
+	{matched: "hi", repl: "GREETINGS", want: "greetings"},
+	{matched: "A", repl: "apple", want: "APPLE"},
+	{matched: "42", repl: "Answer", want: "Answer"},
+	{matched: "Ünïcode", repl: "ölçü", want: "Ölçü"},
+	{matched: "hello", repl: "", want: ""},
+}
+
+func TestApplyCase(t *testing.T) {
+	for _, test := range applyCaseTests {
+		got := applyCase(test.matched, test.repl)
+		if got != test.want {
+			t.Errorf("unexpected result for applyCase(%q, %q): got:%q want:%q", test.matched, test.repl, got, test.want)
+		}
+	}
+}
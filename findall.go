@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "modernc.org/tk9.0"
+)
+
+// findResult is a match listed in the Find in All Inputs dialog.
+type findResult struct {
+	pane  *pane
+	match match
+}
+
+// showFindAll shows the Find in All Inputs dialog, which lists the
+// matches in all the input panes grouped by pane. Selecting a match
+// selects it in its editor.
+func (m *miko) showFindAll() {
+	var initial string
+	if m.editor != nil {
+		if sel := selection(m.editor); !strings.Contains(sel, "\n") {
+			initial = sel
+		}
+	}
+
+	top := App.Toplevel()
+	top.WmTitle("Find in All Inputs")
+	GridColumnConfigure(top.Window, 1, Weight(1))
+	GridRowConfigure(top.Window, 3, Weight(1))
+	find := top.TEntry(Textvariable(initial))
+	replace := top.TEntry(Textvariable(""))
+	matchCase := top.TCheckbutton(Txt("Aa"), Variable(0))
+	useRegexp := top.TCheckbutton(Txt(".*"), Variable(0))
+	message := top.TLabel()
+	tree := top.TTreeview(Show("tree"), Selectmode("browse"))
+	scroll := top.TScrollbar(Command(func(e *Event) { e.Yview(tree) }))
+	tree.Configure(Yscrollcommand(func(e *Event) { e.ScrollSet(scroll) }))
+
+	results := make(map[string]findResult)
+	options := func() findOptions {
+		return findOptions{
+			matchCase: matchCase.Variable() == "1",
+			regexp:    useRegexp.Variable() == "1",
+		}
+	}
+	search := func() {
+		tree.Delete(tree.Children(""))
+		clear(results)
+		pattern := find.Textvariable()
+		if pattern == "" {
+			message.Configure(Txt(""))
+			return
+		}
+		re, err := compileFind(pattern, options())
+		if err != nil {
+			message.Configure(Txt(err.Error()))
+			return
+		}
+		total := 0
+		for _, p := range m.panes {
+			text := p.text.Text()
+			matches := findAll(re, text)
+			if len(matches) == 0 {
+				continue
+			}
+			total += len(matches)
+			parent := tree.Insert("", "end", Txt(fmt.Sprintf("%s (%d)", p.name, len(matches))), Open(true))
+			ix := newIndexer(text)
+			for _, mt := range matches {
+				line, _ := position(ix.index(mt.start))
+				start := strings.LastIndexByte(text[:mt.start], '\n') + 1
+				end := strings.IndexByte(text[mt.start:], '\n')
+				if end < 0 {
+					end = len(text)
+				} else {
+					end += mt.start
+				}
+				item := tree.Insert(parent, "end", Txt(fmt.Sprintf("%d: %s", line, strings.TrimSpace(text[start:end]))))
+				results[item] = findResult{pane: p, match: mt}
+			}
+		}
+		message.Configure(Txt(fmt.Sprintf("%d matches", total)))
+	}
+	Bind(tree, "<<TreeviewSelect>>", Command(func() {
+		sel := tree.Selection("")
+		if len(sel) == 0 {
+			return
+		}
+		r, ok := results[sel[0]]
+		if !ok {
+			return
+		}
+		if !r.pane.shown {
+			r.pane.shown = true
+			m.layoutPanes()
+			m.sync()
+		}
+		w := r.pane.text
+		text := w.Text()
+		if r.match.end > len(text) {
+			// The text has been edited since the search.
+			return
+		}
+		selectMatch(w, text, r.match.start, r.match.end)
+		m.editor = w
+	}))
+	replaceAll := func() {
+		pattern := find.Textvariable()
+		if pattern == "" {
+			return
+		}
+		opts := options()
+		re, err := compileFind(pattern, opts)
+		if err != nil {
+			message.Configure(Txt(err.Error()))
+			return
+		}
+		var (
+			names []string
+			total int
+		)
+		for _, p := range m.panes {
+			if n := len(findAll(re, p.text.Text())); n != 0 {
+				names = append(names, p.name)
+				total += n
+			}
+		}
+		if total == 0 {
+			return
+		}
+		ok := MessageBox(
+			Parent(top),
+			Icon("question"),
+			Type("okcancel"),
+			Title("Replace All"),
+			Msg(fmt.Sprintf("Replace %d matches in %s?", total, strings.Join(names, ", "))),
+		)
+		if ok != "ok" {
+			return
+		}
+		repl := replace.Textvariable()
+		for _, p := range m.panes {
+			text, n := replaceAll(re, p.text.Text(), repl, opts)
+			if n != 0 {
+				setText(p.text, text)
+			}
+		}
+		search()
+	}
+
+	Grid(top.TLabel(Txt("Find")), Row(0), Column(0), Sticky("w"), Padx("4"), Pady("2"))
+	Grid(find, Row(0), Column(1), Sticky("ew"), Pady("2"))
+	Grid(matchCase, Row(0), Column(2))
+	Grid(useRegexp, Row(0), Column(3))
+	Grid(top.TButton(Txt("Find"), Command(search)), Row(0), Column(4), Sticky("ew"), Padx("4"))
+	Grid(top.TLabel(Txt("Replace")), Row(1), Column(0), Sticky("w"), Padx("4"), Pady("2"))
+	Grid(replace, Row(1), Column(1), Sticky("ew"), Pady("2"))
+	Grid(top.TButton(Txt("Replace All"), Command(replaceAll)), Row(1), Column(4), Sticky("ew"), Padx("4"))
+	Grid(message, Row(2), Column(0), Columnspan(5), Sticky("w"), Padx("4"))
+	Grid(tree, Row(3), Column(0), Columnspan(4), Sticky("news"), Padx("4"), Pady("4"))
+	Grid(scroll, Row(3), Column(4), Sticky("nsw"), Pady("4"))
+	Bind(find, "<Return>", Command(search))
+	m.dialog(top)
+	Focus(find)
+	search()
+}
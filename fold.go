@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+const (
+	foldWidth  = 14     // Width of the fold gutter in pixels.
+	foldPrefix = "fold" // Prefix of the tags eliding folded regions.
+)
+
+// folds is a gutter beside a text widget holding markers that fold and
+// unfold its multi-line blocks. Folding a block hides the lines between
+// its brackets. Each folded region is hidden by its own elided tag so
+// that it moves with the text as it is edited and is restored exactly
+// when unfolded.
+type folds struct {
+	text   *TextWidget
+	canvas *CanvasWidget
+
+	shown   bool
+	dirty   bool        // The regions must be recomputed.
+	view    string      // Last seen yview of text.
+	regions map[int]int // Closing line of the region opening on each line.
+	tags    int         // Number of fold tags created.
+}
+
+func newFolds(parent *Window, text *TextWidget) *folds {
+	f := &folds{
+		text: text,
+		canvas: parent.Canvas(
+			Width(foldWidth),
+			Background(White),
+			Highlightthickness(0),
+		),
+		dirty: true,
+	}
+	Bind(f.canvas, "<Button-1>", Command(func(e *Event) { f.click(e.Y) }))
+	Bind(f.canvas, "<Configure>", Command(func() { f.view = "" }))
+	return f
+}
+
+// toggle shows or hides the gutter in column 0 of the text's frame,
+// unfolding everything when it is hidden.
+func (f *folds) toggle() {
+	if f.shown {
+		f.dirty = true
+		Grid(f.canvas, Row(1), Column(0), Sticky("ns"))
+		return
+	}
+	GridForget(f.canvas.Window)
+	f.unfoldAll()
+}
+
+// update recomputes the foldable regions if the text has changed and
+// redraws the markers if the text has changed or been scrolled. It is
+// intended to be called from the UI ticker.
+func (f *folds) update() {
+	if !f.shown {
+		return
+	}
+	if f.dirty {
+		f.regions = foldRegions(f.text.Text())
+		f.prune()
+		f.dirty = false
+		f.view = ""
+	}
+	view := f.text.Yview()
+	if view == f.view {
+		return
+	}
+	f.view = view
+	f.draw()
+}
+
+// draw places a marker beside each visible line that opens a region.
+func (f *folds) draw() {
+	f.canvas.Delete("all")
+	first := lineOf(f.text.Index("@0,0"))
+	last := lineOf(f.text.Index(fmt.Sprintf("@0,%s", WinfoHeight(f.text.Window))))
+	for line := first; line <= last; line++ {
+		if _, ok := f.regions[line]; !ok {
+			continue
+		}
+		info := strings.Fields(EvalErr(fmt.Sprintf("%s dlineinfo %d.0", f.text, line)))
+		if len(info) != 5 {
+			// The line is not displayed.
+			continue
+		}
+		y, _ := strconv.Atoi(info[1])
+		h, _ := strconv.Atoi(info[3])
+		marker := "▾"
+		if f.folded(line) != "" {
+			marker = "▸"
+		}
+		f.canvas.CreateText(foldWidth/2, y+h/2, Txt(marker), Fill("gray40"))
+	}
+}
+
+// click folds or unfolds the region opening on the line at y.
+func (f *folds) click(y int) {
+	line := lineOf(f.text.Index(fmt.Sprintf("@0,%d", y)))
+	end, ok := f.regions[line]
+	if !ok {
+		return
+	}
+	if tag := f.folded(line); tag != "" {
+		f.text.TagDelete(tag)
+	} else {
+		f.tags++
+		tag := fmt.Sprintf("%s%d", foldPrefix, f.tags)
+		f.text.TagConfigure(tag, Elide(true))
+		f.text.TagAdd(tag, fmt.Sprintf("%d.0", line+1), fmt.Sprintf("%d.0", end))
+	}
+	f.view = ""
+}
+
+// folded returns the tag folding the region opening on line, or the
+// empty string if it is not folded.
+func (f *folds) folded(line int) string {
+	start := fmt.Sprintf("%d.0", line+1)
+	for _, tag := range f.text.TagNames(start) {
+		if !strings.HasPrefix(tag, foldPrefix) {
+			continue
+		}
+		if r := f.text.TagRanges(tag); len(r) != 0 && r[0] == start {
+			return tag
+		}
+	}
+	return ""
+}
+
+// prune unfolds regions whose brackets no longer enclose them after an
+// edit, so that text is never left hidden without a marker to show it.
+func (f *folds) prune() {
+	for _, tag := range f.text.TagNames("") {
+		if !strings.HasPrefix(tag, foldPrefix) {
+			continue
+		}
+		r := f.text.TagRanges(tag)
+		if len(r) != 2 {
+			f.text.TagDelete(tag)
+			continue
+		}
+		open := lineOf(r[0]) - 1
+		if end, ok := f.regions[open]; !ok || end != lineOf(r[1]) {
+			f.text.TagDelete(tag)
+		}
+	}
+}
+
+// unfoldAll removes all folds.
+func (f *folds) unfoldAll() {
+	for _, tag := range f.text.TagNames("") {
+		if strings.HasPrefix(tag, foldPrefix) {
+			f.text.TagDelete(tag)
+		}
+	}
+}
+
+// lineOf returns the line number of a text index in line.char form.
+func lineOf(index string) int {
+	n, _ := strconv.Atoi(strings.Split(index, ".")[0])
+	return n
+}
+
+// foldRegions returns the bracketed blocks of a CEL program that span
+// more than two lines as a map from the line of each opening bracket to
+// the line of its closing bracket, ignoring brackets in strings and
+// comments. Where several blocks open on the same line, the longest is
+// used.
+func foldRegions(src string) map[int]int {
+	regions := make(map[int]int)
+	var stack []int
+	for _, b := range scanBrackets(src) {
+		if b.open {
+			stack = append(stack, b.line)
+			continue
+		}
+		if b.depth < 0 {
+			continue
+		}
+		open := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if b.line > open+1 && b.line > regions[open] {
+			regions[open] = b.line
+		}
+	}
+	return regions
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	. "modernc.org/tk9.0"
+)
+
+// fontConfig describes a font family and size.
+type fontConfig struct {
+	Family string `yaml:"family"`
+	Size   int    `yaml:"size"`
+}
+
+// fontSet is a named font shared by a set of text widgets. Changing the
+// font updates all the widgets and recomputes their tab stops, since
+// these depend on the width of a space in the font.
+type fontSet struct {
+	face     *FontFace
+	tabWidth int // Pixel width of a tab stop.
+	tw       int // Width of a tab stop in spaces.
+	widgets  []*TextWidget
+}
+
+func newFontSet(fc fontConfig, tw int) *fontSet {
+	face := NewFont(Family(fc.Family), Size(fc.Size))
+	return &fontSet{
+		face:     face,
+		tabWidth: face.Measure(App, strings.Repeat(" ", tw)),
+		tw:       tw,
+	}
+}
+
+// add registers w as using the font set.
+func (f *fontSet) add(w *TextWidget) {
+	f.widgets = append(f.widgets, w)
+}
+
+// set changes the font and updates the tab stops of the registered
+// widgets.
+func (f *fontSet) set(fc fontConfig) {
+	FontConfigure(f.face.String(), Family(fc.Family), Size(fc.Size))
+	f.tabWidth = f.face.Measure(App, strings.Repeat(" ", f.tw))
+	for _, w := range f.widgets {
+		w.Configure(Tabs(f.tabWidth))
+	}
+}
+
+// setTabWidth changes the width of tab stops to tw spaces and updates
+// the registered widgets.
+func (f *fontSet) setTabWidth(tw int) {
+	f.tw = tw
+	f.tabWidth = f.face.Measure(App, strings.Repeat(" ", tw))
+	for _, w := range f.widgets {
+		w.Configure(Tabs(f.tabWidth))
+	}
+}
+
+// chooseFont shows the font chooser dialog and applies the selected
+// font to f, calling done with the new font configuration.
+func chooseFont(title string, f *fontSet, done func(fontConfig)) {
+	Fontchooser(Title(title), Font(f.face), Command(func() {
+		spec := FontchooserFont()
+		if len(spec) < 2 {
+			return
+		}
+		size, err := strconv.Atoi(spec[1])
+		if err != nil || size == 0 {
+			return
+		}
+		fc := fontConfig{Family: spec[0], Size: size}
+		f.set(fc)
+		done(fc)
+	}))
+	FontchooserShow()
+}
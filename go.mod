@@ -5,6 +5,7 @@ go 1.24.5
 require (
 	golang.org/x/sys v0.33.0
 	golang.org/x/tools v0.34.0
+	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/tk9.0 v1.71.2
 )
 
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// guideTags are the tags used to shade alternating indentation levels.
+var guideTags = [2]string{"indent0", "indent1"}
+
+// indentGuides shades the leading white space of each line in a set of
+// text widgets with alternating faint bands, one per tab stop, so that
+// the nesting of a document is easier to follow. Guides are computed
+// from tab stops of tw characters, matching the widgets' tab width.
+type indentGuides struct {
+	shown  bool
+	tw     int
+	colors [2]string
+	dirty  map[*TextWidget]bool
+}
+
+func newIndentGuides(tw int) *indentGuides {
+	return &indentGuides{
+		tw:    tw,
+		dirty: make(map[*TextWidget]bool),
+	}
+}
+
+// add starts tracking guides for w.
+func (g *indentGuides) add(w *TextWidget) {
+	for i, tag := range guideTags {
+		w.TagConfigure(tag, Background(g.colors[i]))
+		// Keep the guides below the selection and any other tags.
+		EvalErr(fmt.Sprintf("%s tag lower %s", w, tag))
+	}
+	g.dirty[w] = true
+}
+
+// setColors changes the colours of the guide bands.
+func (g *indentGuides) setColors(colors [2]string) {
+	g.colors = colors
+	for w := range g.dirty {
+		for i, tag := range guideTags {
+			w.TagConfigure(tag, Background(g.colors[i]))
+		}
+	}
+}
+
+// toggle applies or removes the guides on all tracked widgets.
+func (g *indentGuides) toggle() {
+	for w := range g.dirty {
+		if g.shown {
+			g.dirty[w] = true
+		} else {
+			for _, tag := range guideTags {
+				w.TagRemove(tag, "1.0", "end")
+			}
+		}
+	}
+}
+
+// update recomputes the guides for widgets whose content has changed.
+// It is intended to be called from the UI ticker.
+func (g *indentGuides) update() {
+	if !g.shown {
+		return
+	}
+	for w, dirty := range g.dirty {
+		if dirty {
+			g.draw(w)
+			g.dirty[w] = false
+		}
+	}
+}
+
+func (g *indentGuides) draw(w *TextWidget) {
+	for _, tag := range guideTags {
+		w.TagRemove(tag, "1.0", "end")
+	}
+	for i, ranges := range indentRanges(w.Text(), g.tw) {
+		if len(ranges) != 0 {
+			w.TagAdd(guideTags[i], ranges...)
+		}
+	}
+}
+
+// indentRanges returns the text index ranges of the leading white space
+// in text belonging to even and odd indentation levels given tab stops
+// every tw columns.
+func indentRanges(text string, tw int) [2][]any {
+	var ranges [2][]any
+	for i, line := range strings.Split(text, "\n") {
+		col := 0
+		for j, c := range line {
+			if c != ' ' && c != '\t' {
+				break
+			}
+			level := col / tw
+			ranges[level%2] = append(ranges[level%2],
+				fmt.Sprintf("%d.%d", i+1, j),
+				fmt.Sprintf("%d.%d", i+1, j+1),
+			)
+			if c == '\t' {
+				col += tw - col%tw
+			} else {
+				col++
+			}
+		}
+	}
+	return ranges
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/txtar"
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// maxHistory is the number of runs kept in the run history.
+const maxHistory = 100
+
+// runHistory is the in-memory record of the runs of the session, with
+// the inputs of each and the notes the user has attached to them. Runs
+// are finished from the goroutine waiting for them.
+type runHistory struct {
+	mu      sync.Mutex
+	runs    []*runRecord
+	next    int // Number of the next run.
+	version int // Incremented on each change to runs.
+
+	top   *ToplevelWidget // The history window, or nil if not shown.
+	tree  *TTreeviewWidget
+	shown int // Value of version when tree was filled.
+}
+
+// runRecord is a run in the history.
+type runRecord struct {
+	number   int
+	start    time.Time
+	duration time.Duration  // How long the run took, zero while running.
+	status   string         // Exit status, empty while running.
+	note     string         // Label given by the user.
+	inputs   *txtar.Archive // The inputs of the run.
+}
+
+// add records the start of a run with inputs and returns its record.
+func (h *runHistory) add(inputs *txtar.Archive) *runRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.next++
+	r := &runRecord{number: h.next, start: time.Now(), inputs: inputs}
+	h.runs = append(h.runs, r)
+	if len(h.runs) > maxHistory {
+		h.runs = h.runs[len(h.runs)-maxHistory:]
+	}
+	h.version++
+	return r
+}
+
+// finish records the end of the run r.
+func (h *runHistory) finish(r *runRecord, d time.Duration, status string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r.duration = d
+	r.status = status
+	h.version++
+}
+
+// setNote sets the note of the run numbered n.
+func (h *runHistory) setNote(n int, note string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.runs {
+		if r.number == n {
+			r.note = note
+			h.version++
+		}
+	}
+}
+
+// records returns copies of the runs in the history, oldest first, and
+// the version of the history they were taken from.
+func (h *runHistory) records() ([]runRecord, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	recs := make([]runRecord, len(h.runs))
+	for i, r := range h.runs {
+		recs[i] = *r
+	}
+	return recs, h.version
+}
+
+// runInputs returns the inputs of a run of the program src.
+func (m *miko) runInputs(src string) *txtar.Archive {
+	ar := m.archive(false)
+	for i, f := range ar.Files {
+		if f.Name == "src.cel" {
+			ar.Files[i].Data = []byte(src)
+		}
+	}
+	return ar
+}
+
+// showHistory shows the Run History window, which lists the runs of the
+// session with their notes. Double-clicking a run edits its note.
+func (m *miko) showHistory() {
+	h := m.history
+	if h.top != nil {
+		h.top.Raise(nil)
+		return
+	}
+	top := App.Toplevel()
+	top.WmTitle("Run History")
+	GridRowConfigure(top.Window, 0, Weight(1))
+	GridColumnConfigure(top.Window, 0, Weight(1))
+	tree := top.TTreeview(Columns("time duration status note"), Selectmode("browse"), Height(15))
+	for _, c := range []struct {
+		id, title string
+		width     int
+	}{
+		{"#0", "Run", 60},
+		{"time", "Started", 80},
+		{"duration", "Duration", 80},
+		{"status", "Status", 140},
+		{"note", "Note", 240},
+	} {
+		tree.Heading(c.id, Txt(c.title))
+		tree.Column(c.id, Width(c.width))
+	}
+	scroll := top.TScrollbar(Command(func(e *Event) { e.Yview(tree) }))
+	tree.Configure(Yscrollcommand(func(e *Event) { e.ScrollSet(scroll) }))
+	Grid(tree, Row(0), Column(0), Sticky("news"))
+	Grid(scroll, Row(0), Column(1), Sticky("ns"))
+	edit := func() {
+		sel := tree.Selection("")
+		if len(sel) == 0 {
+			return
+		}
+		if n, err := strconv.Atoi(sel[0]); err == nil {
+			m.editNote(n)
+		}
+	}
+	Bind(tree, "<Double-Button-1>", Command(edit))
+	Bind(tree, "<Return>", Command(edit))
+	buttons := top.TFrame()
+	Grid(buttons, Row(1), Column(0), Columnspan(2), Sticky("e"), Padx("4"), Pady("4"))
+	Grid(buttons.TButton(Txt("Edit Note..."), Command(edit)), Row(0), Column(0), Padx("2"))
+	Grid(buttons.TButton(Txt("Export..."), Command(m.exportHistory)), Row(0), Column(1), Padx("2"))
+	h.top, h.tree, h.shown = top, tree, -1
+	remove := m.pushOverlay(overlayDialog, func() { Destroy(top) })
+	Bind(top, "<Destroy>", Command(func(e *Event) {
+		remove()
+		if e.EventWindow != nil && e.EventWindow.String() == top.String() {
+			// The window may have moved to another tab's
+			// history since it was opened.
+			m.history.top, m.history.tree = nil, nil
+		}
+	}))
+	m.updateHistory()
+	Focus(tree)
+}
+
+// updateHistory refills the Run History window if it is shown and the
+// history has changed, keeping the selection.
+func (m *miko) updateHistory() {
+	h := m.history
+	if h.top == nil {
+		return
+	}
+	recs, version := h.records()
+	if version == h.shown {
+		return
+	}
+	h.shown = version
+	sel := h.tree.Selection("")
+	h.tree.Delete(h.tree.Children(""))
+	for i := len(recs) - 1; i >= 0; i-- {
+		r := recs[i]
+		duration, status := "", "running"
+		if r.status != "" {
+			duration, status = elapsed(r.duration), r.status
+		}
+		h.tree.Insert("", "end", Id(strconv.Itoa(r.number)), Txt(strconv.Itoa(r.number)),
+			Values([]string{r.start.Format(time.TimeOnly), duration, status, r.note}))
+	}
+	for _, item := range sel {
+		if EvalErr(fmt.Sprintf("%s exists %s", h.tree, item)) == "1" {
+			h.tree.Selection("set", item)
+		}
+	}
+}
+
+// moveWindow hands the Run History window, if it is shown, to the
+// history to, which is redrawn in it.
+func (h *runHistory) moveWindow(to *runHistory) {
+	if h == to {
+		return
+	}
+	to.top, to.tree, to.shown = h.top, h.tree, -1
+	h.top, h.tree = nil, nil
+}
+
+// noteLatest edits the note of the latest run.
+func (m *miko) noteLatest() {
+	recs, _ := m.history.records()
+	if len(recs) == 0 {
+		m.announce("No runs to note")
+		Bell()
+		return
+	}
+	m.editNote(recs[len(recs)-1].number)
+}
+
+// editNote shows a dialog to edit the note of the run numbered n.
+func (m *miko) editNote(n int) {
+	var note string
+	recs, _ := m.history.records()
+	for _, r := range recs {
+		if r.number == n {
+			note = r.note
+		}
+	}
+	top := App.Toplevel()
+	top.WmTitle(fmt.Sprintf("Note for Run %d", n))
+	WmTransient(top, App)
+	entry := top.TEntry(Textvariable(note), Width(40))
+	Grid(top.TLabel(Txt("Note")), Row(0), Column(0), Padx("4"), Pady("4"))
+	Grid(entry, Row(0), Column(1), Sticky("ew"), Padx("4"), Pady("4"))
+	Bind(entry, "<Return>", Command(func() {
+		m.history.setNote(n, strings.TrimSpace(entry.Textvariable()))
+		Destroy(top)
+	}))
+	m.dialog(top)
+	Focus(entry)
+	EvalErr(fmt.Sprintf("%s selection range 0 end", entry))
+}
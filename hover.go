@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// tip is a small undecorated window showing help beside the pointer or
+// the insertion cursor.
+type tip struct {
+	top   *ToplevelWidget
+	text  *TextWidget
+	shown bool
+}
+
+func (m *miko) newTip() *tip {
+	top := App.Toplevel()
+	EvalErr(fmt.Sprintf("wm overrideredirect %s 1", top))
+	WmWithdraw(top.Window)
+	text := top.Text(
+		Font(m.editorFont.face),
+		Wrap("none"),
+		Borderwidth(1),
+		Relief("solid"),
+		Padx("4"), Pady("2"),
+	)
+	Grid(text, Row(0), Column(0))
+	t := &tip{top: top, text: text}
+	t.setColors(m.theme)
+	m.tips = append(m.tips, t)
+	return t
+}
+
+// setColors colours the tip from th.
+func (t *tip) setColors(th theme) {
+	t.text.Configure(Background(th["pinned"]), Foreground(th["foreground"]))
+}
+
+// show shows body in the tip with its top left corner at the screen
+// position x, y. The text widget is returned so that parts of body can
+// be tagged.
+func (t *tip) show(x, y int, body string) *TextWidget {
+	lines := strings.Split(body, "\n")
+	width := 0
+	for _, l := range lines {
+		width = max(width, len([]rune(l)))
+	}
+	t.text.Configure(State("normal"))
+	t.text.Delete("1.0", "end")
+	t.text.Insert("end", body)
+	t.text.Configure(Width(width), Height(len(lines)), State("disabled"))
+	WmGeometry(t.top.Window, fmt.Sprintf("+%d+%d", x, y))
+	if !t.shown {
+		t.shown = true
+		WmDeiconify(t.top.Window)
+	}
+	EvalErr("raise " + t.top.String())
+	return t.text
+}
+
+// hide withdraws the tip if it is shown.
+func (t *tip) hide() {
+	if t.shown {
+		t.shown = false
+		WmWithdraw(t.top.Window)
+	}
+}
+
+// Defaults for hover help. The tip is hidden after hoverTimeout even if
+// the pointer has not moved.
+const (
+	defaultHoverDelay = 600 * time.Millisecond
+	hoverTimeout      = 8 * time.Second
+)
+
+// hoverDelay returns the configured hover help delay. A negative delay
+// disables hover help.
+func (c *config) hoverDelay() time.Duration {
+	if c.HoverDelay == 0 {
+		return defaultHoverDelay
+	}
+	return c.HoverDelay
+}
+
+// hover shows the signature and description of a mito function in the
+// src pane when the pointer rests on its name.
+type hover struct {
+	tip          *tip
+	inside       bool      // The pointer is over the src pane.
+	x, y         int       // Pointer position relative to the pane.
+	xRoot, yRoot int       // Pointer position on the screen.
+	moved        time.Time // When the pointer last moved.
+	shownAt      time.Time // When the tip was shown, or zero.
+}
+
+// trackHover follows the pointer over w for hover help.
+func (m *miko) trackHover(w *TextWidget) {
+	h := &hover{tip: m.newTip()}
+	m.hover = h
+	Bind(w, "<Motion>", Command(func(e *Event) {
+		// Small movements, such as those made while reading the
+		// tip, leave it shown.
+		if h.shownAt.IsZero() || abs(e.X-h.x) > 4 || abs(e.Y-h.y) > 4 {
+			h.x, h.y = e.X, e.Y
+			h.xRoot, h.yRoot = e.XRoot, e.YRoot
+			h.moved = time.Now()
+			m.hideHover()
+		}
+		h.inside = true
+	}))
+	Bind(w, "<Leave>", Command(func() {
+		h.inside = false
+		m.hideHover()
+	}))
+	Bind(w, "<ButtonPress>", Command(m.hideHover))
+}
+
+// hideHover hides the hover tip.
+func (m *miko) hideHover() {
+	m.hover.shownAt = time.Time{}
+	m.hover.tip.hide()
+}
+
+// updateHover shows the hover tip once the pointer has rested on a
+// known function name for the configured delay, and hides it after a
+// timeout.
+func (m *miko) updateHover() {
+	h := m.hover
+	delay := m.config.hoverDelay()
+	switch {
+	case !h.shownAt.IsZero():
+		if time.Since(h.shownAt) > hoverTimeout {
+			m.hideHover()
+		}
+		return
+	case delay < 0 || !h.inside || h.moved.IsZero() || time.Since(h.moved) < delay:
+		return
+	}
+	// Only try once for each resting place of the pointer.
+	h.moved = time.Time{}
+	funcs := lookupFunc(identAt(m.src, fmt.Sprintf("@%d,%d", h.x, h.y)))
+	if len(funcs) == 0 {
+		return
+	}
+	h.tip.show(h.xRoot+12, h.yRoot+16, describeFuncs(funcs))
+	h.shownAt = time.Now()
+}
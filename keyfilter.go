@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// keyFilter is the bar below the display that highlights every
+// occurrence of a JSON key, and optionally of its values, in the output
+// events. Unlike find it only matches object keys, not text in string
+// values.
+type keyFilter struct {
+	frame   *TFrameWidget
+	key     *TEntryWidget
+	values  *TCheckbuttonWidget
+	message *TLabelWidget
+	remove  func() // Removes the bar from the overlay stack.
+	dirty   bool   // The highlighting needs updating.
+
+	// name and withValues are the filter applied to events as they
+	// are drained. An empty name highlights nothing.
+	name       string
+	withValues bool
+}
+
+func (m *miko) newKeyFilter(parent *Window) *keyFilter {
+	frame := parent.TFrame(Padding("2"))
+	k := &keyFilter{
+		frame:   frame,
+		key:     frame.TEntry(Textvariable("")),
+		values:  frame.TCheckbutton(Txt("Values"), Variable(0)),
+		message: frame.TLabel(),
+	}
+	Grid(frame.TLabel(Txt("Key")), Row(0), Column(0), Sticky("w"))
+	Grid(k.key, Row(0), Column(1), Sticky("ew"))
+	Grid(k.values, Row(0), Column(2))
+	Grid(frame.TButton(Txt("Prev"), Command(func() { m.nextKey(false) })), Row(0), Column(3), Sticky("ew"))
+	Grid(frame.TButton(Txt("Next"), Command(func() { m.nextKey(true) })), Row(0), Column(4), Sticky("ew"))
+	Grid(k.message, Row(0), Column(5), Sticky("w"), Padx("4"))
+	GridColumnConfigure(frame.Window, 1, Weight(1))
+	m.describe(k.key, "Key filter: JSON key to highlight in the output")
+	m.bindKey(k.key, "<Return>", shortcut{"Navigate", "Return", "Go to the next highlighted key in the output"}, func() { m.nextKey(true) })
+	m.bindKey(k.key, "<Shift-Return>", shortcut{"Navigate", "Shift+Return", "Go to the previous highlighted key in the output"}, func() { m.nextKey(false) })
+	Bind(k.key, "<KeyRelease>", Command(func() { k.dirty = true }))
+	k.values.Configure(Command(func() { k.dirty = true }))
+	return k
+}
+
+// showKeyFilter shows the key filter bar, starting with the selection in
+// the display if there is one.
+func (m *miko) showKeyFilter() {
+	k := m.keys
+	if k.remove == nil {
+		Grid(k.frame, Row(1), Column(0), Sticky("ew"))
+		k.remove = m.pushOverlay(overlayBar, m.hideKeyFilter)
+	}
+	if sel := strings.Trim(selection(m.display), `"`); sel != "" && !strings.Contains(sel, "\n") {
+		k.key.Configure(Textvariable(sel))
+	}
+	k.dirty = true
+	Focus(k.key)
+	EvalErr(fmt.Sprintf("%s selection range 0 end", k.key))
+}
+
+// hideKeyFilter hides the key filter bar and removes its highlighting.
+func (m *miko) hideKeyFilter() {
+	k := m.keys
+	if k.remove == nil {
+		return
+	}
+	k.remove()
+	k.remove = nil
+	GridForget(k.frame.Window)
+	k.name = ""
+	k.message.Configure(Txt(""))
+	m.highlightKeys()
+}
+
+// keyTags are the tags of the keys and values highlighted by the key
+// filter, and of the key last moved to.
+var keyTags = []string{"keyName", "keyValue", "keyCurrent"}
+
+// updateKeyFilter applies a changed key filter to the events in the
+// display.
+func (m *miko) updateKeyFilter() {
+	k := m.keys
+	if k.remove == nil || !k.dirty {
+		return
+	}
+	k.dirty = false
+	k.name = k.key.Textvariable()
+	k.withValues = k.values.Variable() == "1"
+	n := m.highlightKeys()
+	switch {
+	case k.name == "":
+		k.message.Configure(Txt(""))
+	case n == 1:
+		k.message.Configure(Txt("1 match"))
+	default:
+		k.message.Configure(Txt(fmt.Sprintf("%d matches", n)))
+	}
+}
+
+// highlightKeys replaces the highlighting of the events in the display
+// with that of the current filter and returns the number of keys
+// highlighted.
+func (m *miko) highlightKeys() int {
+	d := m.display
+	k := m.keys
+	for _, tag := range keyTags {
+		d.TagRemove(tag, "1.0", "end")
+	}
+	if k.name == "" {
+		return 0
+	}
+	n := 0
+	seen := make(map[string]bool)
+	for _, mark := range m.trees.marks {
+		// Marks of trimmed events share the start of the display.
+		index := d.Index(mark)
+		if seen[index] {
+			continue
+		}
+		seen[index] = true
+		line, _ := position(index)
+		first, last := m.eventRange(line)
+		src := strings.Join(d.Get(fmt.Sprintf("%d.0", first), fmt.Sprintf("%d.0 lineend", last)), "")
+		spans := keySpans(src, k.name, k.withValues)
+		at := indices(src, spans)
+		for _, sp := range spans {
+			if sp.tag == "keyName" {
+				n++
+			}
+			d.TagAdd(sp.tag, shiftIndex(at[sp.start], first-1), shiftIndex(at[sp.end], first-1))
+		}
+	}
+	return n
+}
+
+// eventKeySpans returns the spans of the keys highlighted by the key
+// filter in the output event t.
+func (k *keyFilter) eventKeySpans(t text) []span {
+	if k.name == "" || t.tag != "output" {
+		return nil
+	}
+	return keySpans(t.data, k.name, k.withValues)
+}
+
+// nextKey moves the display's insertion mark to the next highlighted
+// key after it, or the previous one before it if forward is false,
+// wrapping around at the end, and scrolls the key into view.
+func (m *miko) nextKey(forward bool) {
+	d := m.display
+	from, wrap, search := "insert+1c", "1.0", "nextrange"
+	if !forward {
+		from, wrap, search = "insert", "end", "prevrange"
+	}
+	find := func(from string) []string {
+		return strings.Fields(EvalErr(fmt.Sprintf("%s tag %s keyName {%s}", d, search, from)))
+	}
+	r := find(from)
+	if len(r) != 2 {
+		r = find(wrap)
+	}
+	if len(r) != 2 {
+		Bell()
+		return
+	}
+	d.MarkSet("insert", r[0])
+	d.TagRemove("keyCurrent", "1.0", "end")
+	d.TagAdd("keyCurrent", r[0], r[1])
+	d.See(r[0])
+}
+
+// keySpans returns the spans of the object keys named key in the JSON
+// text src, with the spans of their values if values is true. Keys are
+// tagged keyName and values keyValue. Text in string values is never
+// matched.
+func keySpans(src, key string, values bool) []span {
+	var spans []span
+	for i := 0; i < len(src); i++ {
+		if src[i] != '"' {
+			continue
+		}
+		end := jsonStringEnd(src, i)
+		if end < 0 {
+			break
+		}
+		colon := skipJSONSpace(src, end)
+		if colon < len(src) && src[colon] == ':' {
+			var name string
+			if json.Unmarshal([]byte(src[i:end]), &name) == nil && name == key {
+				spans = append(spans, span{tag: "keyName", start: i, end: end})
+				if values {
+					v := skipJSONSpace(src, colon+1)
+					if ve := jsonValueEnd(src, v); ve > v {
+						spans = append(spans, span{tag: "keyValue", start: v, end: ve})
+					}
+				}
+			}
+		}
+		i = end - 1
+	}
+	return spans
+}
+
+// jsonStringEnd returns the offset after the end of the JSON string
+// starting with the quote at src[i], or -1 if it is not terminated.
+func jsonStringEnd(src string, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// skipJSONSpace returns the offset of the first character at or after i
+// that is not JSON white space.
+func skipJSONSpace(src string, i int) int {
+	for i < len(src) && strings.IndexByte(" \t\r\n", src[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// jsonValueEnd returns the offset after the end of the JSON value
+// starting at src[i].
+func jsonValueEnd(src string, i int) int {
+	if i >= len(src) {
+		return i
+	}
+	switch src[i] {
+	case '"':
+		if end := jsonStringEnd(src, i); end >= 0 {
+			return end
+		}
+		return len(src)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(src); j++ {
+			switch src[j] {
+			case '"':
+				end := jsonStringEnd(src, j)
+				if end < 0 {
+					return len(src)
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(src)
+	}
+	j := i
+	for j < len(src) && strings.IndexByte(",}] \t\r\n", src[j]) < 0 {
+		j++
+	}
+	return j
+}
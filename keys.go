@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	. "modernc.org/tk9.0"
+)
+
+// Categories of keyboard shortcuts, in the order they are listed.
+var shortcutCategories = []string{"Run", "Edit", "Navigate", "View"}
+
+// shortcut describes a keyboard binding for the shortcut reference.
+type shortcut struct {
+	category string
+	keys     string // The keys as shown to the user.
+	action   string
+}
+
+// findShortcut is installed on the editors as well as the main window so
+// that it takes precedence over the text class's own Control-f binding.
+var findShortcut = shortcut{"Edit", "Ctrl+F", "Find in the focused input"}
+
+// bindKey binds handler to event on w and records the binding as s so
+// that it is listed by showShortcuts. Bindings installed on several
+// widgets are listed once.
+func (m *miko) bindKey(w any, event string, s shortcut, handler any) {
+	Bind(w, event, Command(handler))
+	if !slices.Contains(m.shortcuts, s) {
+		m.shortcuts = append(m.shortcuts, s)
+	}
+}
+
+// showShortcuts opens a window listing the keyboard shortcuts grouped
+// by category.
+func (m *miko) showShortcuts() {
+	width := 0
+	for _, s := range m.shortcuts {
+		width = max(width, len(s.keys))
+	}
+	var buf strings.Builder
+	for _, c := range shortcutCategories {
+		heading := false
+		for _, s := range m.shortcuts {
+			if s.category != c {
+				continue
+			}
+			if !heading {
+				if buf.Len() != 0 {
+					buf.WriteByte('\n')
+				}
+				fmt.Fprintln(&buf, c)
+				heading = true
+			}
+			fmt.Fprintf(&buf, "  %-*s  %s\n", width, s.keys, s.action)
+		}
+	}
+	m.showText("Keyboard Shortcuts", buf.String())
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	. "modernc.org/tk9.0"
+)
+
+// defaultLargeData is the default size in bytes of the data pane above
+// which a warning is shown before a run.
+const defaultLargeData = 1 << 20
+
+// largeData returns the configured large data warning threshold. A
+// negative threshold disables the warning.
+func (c *config) largeData() int {
+	if c.LargeData == 0 {
+		return defaultLargeData
+	}
+	return c.LargeData
+}
+
+// configureDataFile sets up the link shown in the large data warning.
+// Runs read the data from the file chosen with the link until the data
+// pane is next edited, rather than writing a new copy for each run.
+func (m *miko) configureDataFile() {
+	d := m.display
+	d.TagConfigure("dataFileLink", Underline(true))
+	d.TagBind("dataFileLink", "<Button-1>", m.useDataFile)
+	m.onChange(m.data, func() { m.dataFile = "" })
+}
+
+// warnLargeData shows a warning in the display if data is larger than
+// the configured threshold and is not being read from a data file. The
+// warning does not prevent the run.
+func (m *miko) warnLargeData(data string) {
+	limit := m.config.largeData()
+	if limit < 0 || len(data) <= limit || m.dataFile != "" {
+		return
+	}
+	d := m.display
+	d.Configure(State("normal"))
+	defer m.lockDisplay()
+	d.Insert("end", fmt.Sprintf("data is %s, which is written for every run: ", byteSize(len(data))), "note")
+	d.Insert("end", "read it from a file instead", "dataFileLink")
+	d.Insert("end", "\n", "note")
+}
+
+// useDataFile asks for a file to save the data pane to and makes runs
+// read the data from it.
+func (m *miko) useDataFile() {
+	path := m.getSaveFile(Title("Save Data for Runs"), Defaultextension(".json"))
+	if path == "" {
+		return
+	}
+	err := os.WriteFile(path, []byte(m.data.Text()), 0o600)
+	if err != nil {
+		m.printError(fmt.Errorf("saving data: %w", err))
+		return
+	}
+	m.dataFile = path
+	d := m.display
+	d.Configure(State("normal"))
+	d.Insert("end", fmt.Sprintf("runs read data from %s until the data is edited\n", path), "note")
+	m.lockDisplay()
+}
+
+// byteSize formats n bytes for display.
+func byteSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// largeDataThreshold parses s as a large data warning threshold in
+// bytes. A threshold of "off" or zero disables the warning and is
+// returned as a negative number.
+func largeDataThreshold(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, errors.New("large data warning must be a positive number of bytes or off")
+	}
+	return n, nil
+}
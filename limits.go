@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// editCfg returns the cfg document cfg after applying edit to its
+// top-level mapping. An empty document is edited as an empty mapping.
+func editCfg(cfg string, edit func(doc map[string]any) error) (string, error) {
+	var doc map[string]any
+	err := yaml.Unmarshal([]byte(cfg), &doc)
+	if err != nil {
+		return "", err
+	}
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+	err = edit(doc)
+	if err != nil {
+		return "", err
+	}
+	b, err := yaml.Marshal(doc)
+	return string(b), err
+}
+
+// withMaxExecutions returns the cfg document cfg with its
+// max_executions set to n, which limits the number of evaluations of
+// the program, and so the pages fetched by a paginating program.
+func withMaxExecutions(cfg string, n int) (string, error) {
+	cfg, err := editCfg(cfg, func(doc map[string]any) error {
+		doc["max_executions"] = n
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("setting max executions: %w", err)
+	}
+	return cfg, nil
+}
+
+// parseMaxExecutions parses the content of the Max Executions field. An
+// empty field gives zero, which leaves the limit to the cfg pane.
+func parseMaxExecutions(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("max executions must be a positive integer: %q", s)
+	}
+	return n, nil
+}
+
+// maxExecutionsText returns the content of the Max Executions field for
+// the configured limit.
+func (c *config) maxExecutionsText() string {
+	if c.MaxExecutions <= 0 {
+		return ""
+	}
+	return strconv.Itoa(c.MaxExecutions)
+}
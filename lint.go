@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	. "modernc.org/tk9.0"
+)
+
+// lintWarning is a possible mistake found in the src or cfg pane.
+type lintWarning struct {
+	pane      string // The pane holding the mistake; empty for src.
+	line, col int    // Position of the mistake; col counts characters.
+	msg       string
+}
+
+func (w lintWarning) String() string {
+	if w.pane != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", w.pane, w.line, w.col+1, w.msg)
+	}
+	return fmt.Sprintf("%d:%d: %s", w.line, w.col+1, w.msg)
+}
+
+// celFuncs are the functions, methods and macros of CEL and the cel-go
+// extensions used by mito that are not in mitoRef.
+var celFuncs = []string{
+	// Macros and standard functions.
+	"all", "bool", "bytes", "contains", "double", "duration", "dyn", "endsWith",
+	"exists", "exists_one", "filter", "has", "int", "map", "matches", "size",
+	"startsWith", "string", "timestamp", "type", "uint",
+	"getDate", "getDayOfMonth", "getDayOfWeek", "getDayOfYear", "getFullYear",
+	"getHours", "getMilliseconds", "getMinutes", "getMonth", "getSeconds",
+	// Bindings, optionals and the strings, math, lists and encoders
+	// extensions.
+	"as", "bind", "of", "none", "ofNonZeroValue", "hasValue", "value", "or", "orValue", "optMap", "optFlatMap",
+	"charAt", "format", "indexOf", "lastIndexOf", "lowerAscii", "replace", "reverse", "upperAscii",
+	"greatest", "least", "abs", "ceil", "floor", "round", "sign", "trunc", "sqrt",
+	"distinct", "range", "slice", "sort", "sortBy", "encode", "decode",
+}
+
+// Patterns used by lint. They are matched against the program with the
+// content of strings and comments masked.
+var (
+	lintCall    = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+	lintBinding = regexp.MustCompile(`\.(as|map|filter|all|exists|exists_one)\(\s*([A-Za-z_][A-Za-z0-9_]*)\s*,|\bcel\.bind\(\s*([A-Za-z_][A-Za-z0-9_]*)\s*,`)
+	lintConcat  = regexp.MustCompile(`["']\s*\+\s*[0-9]|\b[0-9]+(\.[0-9]+)?\s*\+\s*["']`)
+)
+
+// lint returns warnings about likely mistakes in the CEL program src:
+// bindings that are not used, string literals added to numbers, and
+// calls of functions that neither CEL nor mito provide.
+func lint(src string) []lintWarning {
+	masked := maskStrings(src)
+	var warnings []lintWarning
+	warn := func(at int, format string, args ...any) {
+		line := strings.Count(src[:at], "\n") + 1
+		col := utf8.RuneCountInString(src[strings.LastIndexByte(src[:at], '\n')+1 : at])
+		warnings = append(warnings, lintWarning{line: line, col: col, msg: fmt.Sprintf(format, args...)})
+	}
+	for _, loc := range lintBinding.FindAllStringSubmatchIndex(masked, -1) {
+		nameAt, nameEnd := loc[4], loc[5]
+		if nameAt < 0 {
+			nameAt, nameEnd = loc[6], loc[7]
+		}
+		name := masked[nameAt:nameEnd]
+		open := strings.IndexByte(masked[loc[0]:], '(') + loc[0]
+		end := closing(masked, open)
+		body := masked[loc[1]:end]
+		if !usesIdent(body, name) {
+			warn(nameAt, "%s is bound but not used", name)
+		}
+	}
+	for _, loc := range lintConcat.FindAllStringIndex(masked, -1) {
+		warn(loc[0], "string added to a number; convert one with string() or int()")
+	}
+	for _, loc := range lintCall.FindAllStringSubmatchIndex(masked, -1) {
+		name := masked[loc[2]:loc[3]]
+		if len(lookupFunc(name)) == 0 && !slices.Contains(celFuncs, name) && !celKeyword(name) {
+			warn(loc[2], "%s is not a CEL or mito function", name)
+		}
+	}
+	slices.SortFunc(warnings, func(a, b lintWarning) int {
+		if a.line != b.line {
+			return a.line - b.line
+		}
+		return a.col - b.col
+	})
+	return warnings
+}
+
+// celKeyword reports whether name is a CEL keyword that may be followed
+// by a parenthesis.
+func celKeyword(name string) bool {
+	switch name {
+	case "in", "true", "false", "null":
+		return true
+	}
+	return false
+}
+
+// maskStrings returns src with the content of string literals and
+// comments replaced by spaces, keeping the quotes and line breaks so
+// that offsets and positions are unchanged.
+func maskStrings(src string) string {
+	b := []byte(src)
+	blank := func(from, to int) {
+		for i := from; i < to && i < len(b); i++ {
+			if b[i] != '\n' {
+				b[i] = ' '
+			}
+		}
+	}
+	for i := 0; i < len(src); {
+		switch c := src[i]; c {
+		case '/':
+			if strings.HasPrefix(src[i:], "//") {
+				n := strings.IndexByte(src[i:], '\n')
+				if n < 0 {
+					n = len(src) - i
+				}
+				blank(i, i+n)
+				i += n
+				continue
+			}
+		case '"', '\'':
+			raw := i > 0 && (src[i-1] == 'r' || src[i-1] == 'R')
+			quote := string(c)
+			if strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			j := i + len(quote)
+			for j < len(src) && !strings.HasPrefix(src[j:], quote) {
+				if src[j] == '\\' && !raw {
+					j++
+				}
+				j++
+			}
+			blank(i+len(quote), j)
+			i = min(j+len(quote), len(src))
+			continue
+		}
+		i++
+	}
+	return string(b)
+}
+
+// closing returns the offset of the parenthesis closing the one at open
+// in the masked program src, or the length of src if it is not closed.
+func closing(src string, open int) int {
+	depth := 0
+	for i := open; i < len(src); i++ {
+		switch src[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(src)
+}
+
+// usesIdent reports whether the identifier name is used in src other
+// than as a field name.
+func usesIdent(src, name string) bool {
+	for i := 0; ; {
+		j := strings.Index(src[i:], name)
+		if j < 0 {
+			return false
+		}
+		j += i
+		end := j + len(name)
+		before := j == 0 || !isIdent("a"+src[j-1:j]) && src[j-1] != '.'
+		after := end == len(src) || !isIdent("a"+src[end:end+1])
+		if before && after {
+			return true
+		}
+		i = end
+	}
+}
+
+// lintCache holds the warnings for the program and cfg last linted, and
+// the messages of the lines marked with them.
+type lintCache struct {
+	src, cfg string
+	warnings []lintWarning
+	schema   *cfgSchema
+	messages map[*TextWidget]map[int]string // Warnings by pane and line.
+	marked   int                            // Number of warnings marked.
+}
+
+// check returns the warnings for src and cfg, reusing those of the last
+// check if neither has changed. The cfg is only checked if a schema is
+// given.
+func (c *lintCache) check(src, cfg string, schema *cfgSchema) []lintWarning {
+	if src != c.src || cfg != c.cfg || schema != c.schema || c.warnings == nil {
+		c.src, c.cfg, c.schema = src, cfg, schema
+		c.warnings = lint(src)
+		if schema != nil {
+			c.warnings = append(c.warnings, checkCfg(cfg, schema)...)
+		}
+		if c.warnings == nil {
+			c.warnings = []lintWarning{}
+		}
+	}
+	return c.warnings
+}
+
+// trackLint announces the warning of a line marked by lintBeforeRun when
+// it is pointed at.
+func (m *miko) trackLint() {
+	for _, w := range []*TextWidget{m.src, m.cfg} {
+		w.TagBind("lintWarning", "<Enter>", func(e *Event) {
+			line, _ := position(w.Index(fmt.Sprintf("@%d,%d", e.X, e.Y)))
+			if msg, ok := m.lint.messages[w][line]; ok {
+				m.announce(msg)
+			}
+		})
+	}
+}
+
+// lintBeforeRun checks the program and cfg to be run, marking the lines
+// with warnings and counting them in the status bar. Warnings never stop
+// the run.
+func (m *miko) lintBeforeRun(src, cfg string) {
+	c := &m.lint
+	warnings := c.check(src, cfg, m.cfgCheck.schema)
+	c.messages = make(map[*TextWidget]map[int]string)
+	c.marked = 0
+	for _, w := range []*TextWidget{m.src, m.cfg} {
+		w.TagRemove("lintWarning", "1.0", "end")
+		c.messages[w] = make(map[int]string)
+	}
+	if len(warnings) == 0 {
+		return
+	}
+	for _, lw := range warnings {
+		w := m.src
+		line := lw.line + m.srcOffset
+		if lw.pane == "cfg" {
+			w, line = m.cfg, lw.line
+		}
+		w.TagAdd("lintWarning", fmt.Sprintf("%d.0", line), fmt.Sprintf("%d.0+1l", line))
+		if c.messages[w][line] != "" {
+			c.messages[w][line] += "; "
+		}
+		c.messages[w][line] += lw.String()
+	}
+	c.marked = len(warnings)
+}
+
+// lintStatus returns the number of warnings marked by the last
+// lintBeforeRun for the status bar, or the empty string if there were
+// none.
+func (m *miko) lintStatus() string {
+	switch n := m.lint.marked; n {
+	case 0:
+		return ""
+	case 1:
+		return ", 1 lint warning"
+	default:
+		return fmt.Sprintf(", %d lint warnings", n)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// logField is a key/value pair from a structured log line.
+type logField struct {
+	key, value string
+}
+
+// parseLog parses line as a structured log record, either a JSON object
+// or logfmt key=value pairs, preserving the order of the fields. It
+// reports whether line was structured.
+func parseLog(line string) ([]logField, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		return parseJSONLog(line)
+	}
+	return parseLogfmt(line)
+}
+
+func parseJSONLog(line string) ([]logField, bool) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	var fields []logField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, false
+		}
+		var raw json.RawMessage
+		err = dec.Decode(&raw)
+		if err != nil {
+			return nil, false
+		}
+		value := string(raw)
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			value = s
+		}
+		fields = append(fields, logField{key: key, value: value})
+	}
+	_, err = dec.Token()
+	if err != nil || dec.More() || len(fields) == 0 {
+		return nil, false
+	}
+	return fields, true
+}
+
+// parseLogfmt parses line as logfmt. At least two pairs are required
+// so that prose containing an '=' is not mistaken for a record.
+func parseLogfmt(line string) ([]logField, bool) {
+	var fields []logField
+	for line != "" {
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok || key == "" || strings.IndexFunc(key, unicode.IsSpace) >= 0 || strings.Contains(key, `"`) {
+			return nil, false
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			q, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(q)
+			rest = rest[len(q):]
+			if rest != "" && rest[0] != ' ' {
+				return nil, false
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		fields = append(fields, logField{key: key, value: value})
+		line = strings.TrimLeft(rest, " ")
+	}
+	return fields, len(fields) >= 2
+}
+
+// summaryKeys are the fields shown in a log record's summary line.
+var summaryKeys = []string{"time", "ts", "level", "lvl", "msg", "message"}
+
+// slowRequest is the duration above which a logged request's duration
+// is emphasized.
+const slowRequest = time.Second
+
+// logValue returns the value of the first of keys found in fields.
+func logValue(fields []logField, keys []string) (string, bool) {
+	for _, k := range keys {
+		for _, f := range fields {
+			if f.key == k {
+				return f.value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// statusTag returns the tag colouring an HTTP status code by its class,
+// or "" if s is not a status code with a coloured class.
+func statusTag(s string) string {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 100 || n > 599 {
+		return ""
+	}
+	switch n / 100 {
+	case 2:
+		return "status2xx"
+	case 4:
+		return "status4xx"
+	case 5:
+		return "status5xx"
+	}
+	return ""
+}
+
+// durationTag returns the tag emphasizing a slow request duration, or
+// "" if s is not a duration or is not slow.
+func durationTag(s string) string {
+	d, ok := parseLogDuration(s)
+	if !ok || d < slowRequest {
+		return ""
+	}
+	return "slowRequest"
+}
+
+// formatLog returns a one line summary of a structured log record and
+// the record's fields as aligned key/value lines. The summary of a
+// logged request also gives its status code and duration, and status
+// codes and slow durations are tagged in both.
+func formatLog(fields []logField) (summary, body text) {
+	var sum strings.Builder
+	add := func(s, tag string) {
+		if sum.Len() != 0 {
+			sum.WriteByte(' ')
+		}
+		if tag != "" {
+			summary.spans = append(summary.spans, span{tag: tag, start: sum.Len(), end: sum.Len() + len(s)})
+		}
+		sum.WriteString(s)
+	}
+	for _, k := range summaryKeys {
+		for _, f := range fields {
+			if f.key == k {
+				add(f.value, "")
+			}
+		}
+	}
+	if status, ok := logValue(fields, statusKeys); ok {
+		add(status, statusTag(status))
+	}
+	if v, ok := logValue(fields, durationKeys); ok {
+		if d, ok := parseLogDuration(v); ok {
+			add(d.String(), durationTag(v))
+		}
+	}
+	if sum.Len() == 0 {
+		add(fields[0].key+"="+fields[0].value, "")
+	}
+	width := 0
+	for _, f := range fields {
+		width = max(width, len(f.key))
+	}
+	var buf bytes.Buffer
+	for i, f := range fields {
+		if i != 0 {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(&buf, "    %-*s  ", width, f.key)
+		var tag string
+		switch {
+		case slices.Contains(statusKeys, f.key):
+			tag = statusTag(f.value)
+		case slices.Contains(durationKeys, f.key):
+			tag = durationTag(f.value)
+		}
+		if tag != "" {
+			body.spans = append(body.spans, span{tag: tag, start: buf.Len(), end: buf.Len() + len(f.value)})
+		}
+		buf.WriteString(f.value)
+	}
+	summary.data = fmt.Sprintf("%s (%d fields)", sum.String(), len(fields))
+	summary.tag = "logSummary"
+	body.data = buf.String()
+	body.tag = "logBody"
+	return summary, body
+}
+
+// configureLogs sets up the tags used for structured log records. The
+// fields of a record are hidden until its summary line is clicked.
+func (m *miko) configureLogs() {
+	d := m.display
+	d.TagConfigure("logBody", Elide(true))
+	// logExpanded is created after logBody so that it takes priority.
+	d.TagConfigure("logExpanded", Elide(false))
+	d.TagBind("logSummary", "<Button-1>", func() {
+		body := EvalErr(fmt.Sprintf("%s tag nextrange logBody {current lineend}", d))
+		r := strings.Fields(body)
+		if len(r) != 2 {
+			return
+		}
+		if EvalErr(fmt.Sprintf("%s tag nextrange logExpanded %s %s", d, r[0], r[1])) != "" {
+			d.TagRemove("logExpanded", r[0], r[1])
+		} else {
+			d.TagAdd("logExpanded", r[0], r[1])
+		}
+	})
+}
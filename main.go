@@ -3,7 +3,9 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -13,6 +15,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -21,93 +25,336 @@ import (
 	"golang.org/x/tools/txtar"
 	. "modernc.org/tk9.0"
 	. "modernc.org/tk9.0/extensions/autoscroll"
+	. "modernc.org/tk9.0/extensions/eval"
 )
 
+// usageExamples follows the flag defaults in the usage message.
+const usageExamples = `
+Examples:
+  miko -txtar session.txtar
+    	open the program, data and cfg held in a txtar archive
+  miko -txtar - < session.txtar
+    	read the archive from standard input
+  miko -src prog.cel -data input.json -cfg cfg.yaml
+    	open the inputs from separate files
+  miko -src prog.cel -data a.json -data b.json
+    	open several data documents, run in turn with Run All
+
+A txtar archive holds all of the inputs, so -txtar cannot be used with
+-src, -data or -cfg.
+`
+
+// usage prints the usage message, with example invocations.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+	fmt.Fprint(out, usageExamples)
+}
+
+// flags holds the command line flags that check validates.
+type flags struct {
+	txtar, src, cfg string
+	data            []string
+	args            []string // Arguments after the flags.
+	tw              uint
+	poll            time.Duration
+	faceSize        uint
+}
+
+// conflict returns an error naming the input flags given with -txtar,
+// which cannot be used together since the archive holds all of the
+// inputs.
+func (f *flags) conflict() error {
+	if f.txtar == "" {
+		return nil
+	}
+	var given []string
+	if f.src != "" {
+		given = append(given, "-src")
+	}
+	if len(f.data) != 0 {
+		given = append(given, "-data")
+	}
+	if f.cfg != "" {
+		given = append(given, "-cfg")
+	}
+	switch len(given) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("-txtar cannot be used with %s", given[0])
+	default:
+		return fmt.Errorf("-txtar cannot be used with %s or %s", strings.Join(given[:len(given)-1], ", "), given[len(given)-1])
+	}
+}
+
+// check corrects nonsensical flag values so that miko can start, and
+// returns the problems found so that they can be reported in the output
+// display. Conflicting flags are found by conflict.
+func (f *flags) check() []error {
+	var problems []error
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+	if len(f.args) != 0 {
+		problem("ignoring unexpected arguments: %s", strings.Join(f.args, " "))
+	}
+	if f.tw == 0 {
+		problem("-tw must be positive: using 4")
+		f.tw = 4
+	}
+	if f.poll <= 0 {
+		problem("-fr must be positive: using 10ms")
+		f.poll = 10 * time.Millisecond
+	}
+	if f.faceSize == 0 {
+		problem("-face_size must be positive: using 10")
+		f.faceSize = 10
+	}
+	return problems
+}
+
 func main() {
-	txt := flag.String("txtar", "", "txtar file containing src.cel, data.json and cfg.yaml (incompatible with any other argument)")
+	flag.Usage = usage
+	txt := flag.String("txtar", "", "txtar file containing src.cel, data.json and cfg.yaml, or - for standard input (incompatible with any other argument)")
 	srcPath := flag.String("src", "", "path to a CEL program")
-	dataPath := flag.String("data", "", "path to a JSON object holding input (exposed as the label state)")
+	var dataPaths dataFlag
+	flag.Var(&dataPaths, "data", "path to a JSON object holding input (exposed as the label state); may be repeated to run against each in turn")
 	cfgPath := flag.String("cfg", "", "path to a YAML file holding run control configuration (see pkg.go.dev/github.com/elastic/mito/cmd/mito)")
 	font := flag.String("font", "Courier", "font family")
 	size := flag.Uint("face_size", 10, "font face size")
+	displayFont := flag.String("display_font", "", "output display font family (defaults to -font)")
+	displaySize := flag.Uint("display_face_size", 0, "output display font face size (defaults to -face_size)")
 	tw := flag.Uint("tw", 4, "width of tab stops measured in spaces")
 	poll := flag.Duration("fr", 10*time.Millisecond, "refresh poll rate")
+	mitoPath := flag.String("mito", "mito", "path to the mito executable")
+	celfmtPath := flag.String("celfmt", "celfmt", "path to the celfmt executable")
 	flag.Parse()
-	if *txt != "" && (*dataPath != "" || *cfgPath != "" || *srcPath != "") || *tw == 0 {
+	f := flags{
+		txtar:    *txt,
+		src:      *srcPath,
+		data:     dataPaths,
+		cfg:      *cfgPath,
+		args:     flag.Args(),
+		tw:       *tw,
+		poll:     *poll,
+		faceSize: *size,
+	}
+	if err := f.conflict(); err != nil {
+		// This is misuse, so it is reported like a bad flag.
+		fmt.Fprintf(flag.CommandLine.Output(), "%v\n\n", err)
 		flag.Usage()
 		os.Exit(2)
 	}
-	m := newMiko(*font, int(*size), int(*tw), *poll)
-	if *txt != "" {
-		b, err := os.ReadFile(*txt)
-		if err != nil {
-			log.Fatal(err)
-		}
-		ar := txtar.Parse(b)
-		for _, f := range ar.Files {
-			switch f.Name {
-			case "src.cel":
-				m.src.Insert("end", string(f.Data))
-			case "data.json":
-				m.data.Insert("end", string(f.Data))
-			case "cfg.yaml":
-				m.cfg.Insert("end", string(f.Data))
-			}
+	problems := f.check()
+	*tw, *poll, *size = f.tw, f.poll, f.faceSize
+	conf, err := loadConfig()
+	if err != nil {
+		log.Printf("using default configuration: %v", err)
+	}
+	// Flags given on the command line override values from the
+	// configuration file, which override the flag defaults.
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["tw"] && conf.TabWidth > 0 {
+		*tw = uint(conf.TabWidth)
+	}
+	if !set["fr"] && conf.PollRate > 0 {
+		*poll = conf.PollRate
+	}
+	if !set["mito"] && conf.Mito != "" {
+		*mitoPath = conf.Mito
+	}
+	if !set["celfmt"] && conf.Celfmt != "" {
+		*celfmtPath = conf.Celfmt
+	}
+	editorFont := fontConfig{Family: *font, Size: int(*size)}
+	if conf.EditorFont != nil {
+		if !set["font"] {
+			editorFont.Family = conf.EditorFont.Family
 		}
+		if !set["face_size"] {
+			editorFont.Size = conf.EditorFont.Size
+		}
+	}
+	outputFont := editorFont
+	if conf.DisplayFont != nil {
+		outputFont = *conf.DisplayFont
+	}
+	if *displayFont != "" {
+		outputFont.Family = *displayFont
 	}
-	if *srcPath != "" {
-		b, err := os.ReadFile(*srcPath)
+	if *displaySize != 0 {
+		outputFont.Size = int(*displaySize)
+	}
+	m := newMiko(conf, editorFont, outputFont, int(*tw), *poll)
+	m.mitoPath = *mitoPath
+	m.celfmtPath = *celfmtPath
+	for _, err := range problems {
+		m.printError(err)
+	}
+	if *txt != "" {
+		var (
+			b   []byte
+			err error
+		)
+		if *txt == "-" {
+			b, err = io.ReadAll(os.Stdin)
+		} else {
+			b, err = os.ReadFile(*txt)
+		}
 		if err != nil {
-			log.Fatal(err)
+			m.printError(err)
 		}
-		m.src.Insert("end", string(b))
+		m.load(txtar.Parse(b))
 	}
-	if *dataPath != "" {
-		b, err := os.ReadFile(*dataPath)
+	for _, input := range []struct {
+		path string
+		text *TextWidget
+	}{
+		{path: *srcPath, text: m.src},
+		{path: *cfgPath, text: m.cfg},
+	} {
+		if input.path == "" {
+			continue
+		}
+		b, err := os.ReadFile(input.path)
 		if err != nil {
-			log.Fatal(err)
+			m.printError(err)
+			continue
 		}
-		m.data.Insert("end", string(b))
+		input.text.Insert("end", string(b))
 	}
-	if *cfgPath != "" {
-		b, err := os.ReadFile(*cfgPath)
+	var docs []dataDoc
+	for i, name := range uniqueNames(dataPaths) {
+		b, err := os.ReadFile(dataPaths[i])
 		if err != nil {
-			log.Fatal(err)
+			m.printError(err)
+			continue
 		}
-		m.cfg.Insert("end", string(b))
+		docs = append(docs, dataDoc{name: name, text: string(b)})
+	}
+	m.setDataDocs(docs)
+	m.setModified(false)
+	if *txt == "" && *srcPath == "" && len(dataPaths) == 0 && *cfgPath == "" {
+		m.restoreTabs()
+		m.restoreDraft()
 	}
 	m.main()
 }
 
 type miko struct {
-	ps          atomic.Pointer[os.Process]
-	results     chan text
-	src         *TextWidget
-	data        *TextWidget
-	cfg         *TextWidget
-	display     *TextWidget
-	insecure    bool
-	logRequests bool
-	dumpCrash   bool
+	runs         *runner    // Runs of the current document.
+	cache        inputCache // Input files written for runs.
+	results      chan text
+	held         []text       // Results of the current document held while it was not shown.
+	closed       bool         // results has been closed and is no longer drained.
+	dropped      atomic.Int64 // Results not sent to a full results channel.
+	src          *TextWidget
+	data         *TextWidget
+	cfg          *TextWidget
+	stdin        *TextWidget
+	params       *TextWidget
+	mock         *TextWidget
+	recording    bool        // Responses of runs are recorded as mock routes.
+	bell         atomic.Bool // The end of a long run is to be notified with the bell.
+	display      *TextWidget
+	paned        *TPanedwindowWidget
+	inputs       *TPanedwindowWidget
+	maximized    bool
+	prevSash     int
+	panes        []*pane
+	config       *config
+	editor       *TextWidget // Most recently focused editor.
+	editorFont   *fontSet
+	displayFont  *fontSet
+	minimap      *minimap
+	guides       *indentGuides
+	folds        *folds
+	shortcuts    []shortcut // Keyboard bindings installed by bindKey.
+	tabs         *tabs
+	theme        theme
+	controls     []Widget          // The main window's buttons.
+	stylePadding map[string]string // Default padding of ttk styles.
+	rainbow      *rainbow
+	waterfall    *waterfall
+	captured     *captured
+	hover        *hover
+	signature    *signature
+	lint         lintCache
+	typeFormat   typeFormat
+	cfgCheck     cfgCheck
+	tips         []*tip        // Popup help windows, coloured by the theme.
+	replay       *replayWindow // The open Replay Requests window, if any.
+	changeHooks  map[*TextWidget][]func()
+	syncs        []func() // Update controls from settings.
+	poll         time.Duration
+	mitoPath     string
+	celfmtPath   string
+	noQuit       bool // Don't confirm quitting for the rest of the session.
+	draft        *draft
+	overlays     []*overlay // Transient UI dismissed by Escape.
+	status       *status
+	find         *findBar
+	dataDocs     *dataDocs // Documents from repeated -data flags.
+	payloads     *payloadBar
+	dataFile     string // File holding the unedited data pane, if any.
+	srcOffset    int    // Lines of the src pane above the program run.
+	pinned       bool   // An output event is pinned.
+	unlocked     bool   // The output display can be edited.
+	piped        bool   // The current run is of a follow-up expression, not the src pane.
+	pipeExpr     string // Last follow-up expression piped to.
+	runCount     int
+	repeats      repeats
+	trees        eventTrees
+	tee          tee
+	keys         *keyFilter
+	history      *runHistory
+}
+
+// pane is an input editor pane that can be shown or hidden.
+type pane struct {
+	name  string
+	frame *FrameWidget
+	text  *TextWidget
+	shown bool
 }
 
 type text struct {
-	data string
-	tag  string
+	data  string
+	tag   string
+	spans []span // Parts of data given further tags.
+	// doc is the id of the document whose run produced the text, or
+	// zero if the text is for the current document.
+	doc int
+	// gen is the generation of the run ended by a runEnded result,
+	// and record is whether its logged responses are to be recorded.
+	gen    uint64
+	record bool
+}
+
+// span is a part of a text, as byte offsets, given an extra tag.
+type span struct {
+	tag        string
+	start, end int
 }
 
-func newMiko(font string, size, tw int, poll time.Duration) *miko {
+func newMiko(conf *config, editorFont, displayFont fontConfig, tw int, poll time.Duration) *miko {
 	App.WmTitle("miko")
 	// Allow the main window to be resized.
 	App.SetResizable(true, true)
 	// Only render scroll bars when needed.
 	InitializeExtension("autoscroll")
+	// Allow raw Tcl for operations not exposed by the tk9.0 API.
+	InitializeExtension("eval")
 
-	m := &miko{results: make(chan text)}
+	m := &miko{runs: new(runner), results: make(chan text, resultsBuffer), config: conf, poll: poll, draft: newDraft(conf)}
 
 	// Use a TPanedwindow with a horizontal orientation for the main layout.
 	// This will create two panes (left and right) separated by a movable sash.
 	paned := App.TPanedwindow(Orient("horizontal"))
+	m.paned = paned
 	Grid(paned, Row(0), Column(0), Sticky("news"))
 
 	// Configure the main window's grid so that the paned window expands
@@ -115,6 +362,10 @@ func newMiko(font string, size, tw int, poll time.Duration) *miko {
 	GridRowConfigure(App, 0, Weight(1))
 	GridColumnConfigure(App, 0, Weight(1))
 
+	// The status bar runs along the bottom of the main window.
+	m.status = m.newStatus(App)
+	Grid(m.status.frame, Row(1), Column(0), Sticky("ew"))
+
 	// Create frames for the left and right panes.
 	leftPane := App.Frame()
 	rightPane := App.Frame()
@@ -129,148 +380,233 @@ func newMiko(font string, size, tw int, poll time.Duration) *miko {
 	// Configure its grid so that the content can expand horizontally.
 	GridColumnConfigure(leftPane, 0, Weight(1))
 
+	// Tabs for switching between documents run along the top.
+	m.tabs = m.newTabs(leftPane.Window)
+	m.layoutTabs()
+	Grid(m.tabs.frame, Row(0), Column(0), Sticky("w"))
+
 	buttons := leftPane.Frame()
 
 	run := buttons.Window.Button(
 		Txt("Run"),
-		Command(func() {
-			if ps := m.ps.Load(); ps != nil {
-				err := ps.Kill()
-				if err != nil {
-					m.printError(err)
-				}
-			}
-			ps, err := m.mito(false)
-			m.ps.Store(ps)
-			if err != nil {
-				m.printError(err)
-			}
-		}),
+		Command(func() { m.run(runOptions{}) }),
 	)
+	// Shift-clicking Run makes a single insecure run without changing
+	// the Insecure HTTPS toggle.
+	Bind(run, "<Shift-Button-1>", Command(func(e *Event) {
+		m.run(runOptions{insecure: true})
+		e.SetReturnCodeBreak()
+	}))
 
 	format := buttons.Window.Button(
 		Txt("Format"),
-		Command(func() {
-			src, err := m.celfmt()
-			if err != nil {
-				m.printError(err)
-				return
-			}
-			if src != "" {
-				m.src.Clear()
-				m.src.Insert("end", src)
-			}
-			data, err := m.jsonfmt()
-			if err != nil {
-				m.printError(err)
-				return
-			}
-			if data != "" {
-				m.data.Clear()
-				m.data.Insert("end", data)
-			}
-		}),
+		Command(m.format),
 	)
 
 	cancel := buttons.Window.Button(
 		Txt("Cancel"),
-		Command(func() {
-			if ps := m.ps.Load(); ps != nil {
-				err := ps.Kill()
-				if err != nil {
-					m.printError(err)
-				}
-			}
-		}),
+		Command(m.cancel),
 	)
 
 	clear := buttons.Window.Button(
 		Txt("Clear Output"),
-		Command(func() {
-			m.display.Configure(State("normal"))
-			m.display.Clear()
-			m.display.TagConfigure("output", Foreground("black"))
-			m.display.TagConfigure("error", Foreground("red"))
-			m.display.Configure(State("disabled"))
-		}),
+		Command(m.clearOutput),
 	)
 
 	snarf := buttons.Window.Button(
 		Txt("Snarf"),
-		Command(func() {
-			var ar txtar.Archive
-			src := m.src.Text()
-			if src != "" {
-				ar.Files = append(ar.Files, txtar.File{Name: "src.cel", Data: []byte(src)})
-			}
-			data := m.data.Text()
-			if data != "" {
-				ar.Files = append(ar.Files, txtar.File{Name: "data.json", Data: []byte(data)})
-			}
-			cfg := m.cfg.Text()
-			if cfg != "" {
-				ar.Files = append(ar.Files, txtar.File{Name: "cfg.yaml", Data: []byte(cfg)})
-			}
-			out := m.display.Text()
-			if out != "" {
-				ar.Files = append(ar.Files, txtar.File{Name: "out.json", Data: []byte(out)})
-			}
-			ClipboardClear()
-			ClipboardAppend(string(txtar.Format(&ar)))
-		}),
+		Command(m.snarf),
 	)
 
+	insecureVar := Variable(btoi(m.config.Insecure))
 	insecure := buttons.Window.Checkbutton(
 		Txt("Insecure HTTPS"),
-		Variable(&m.insecure),
-		Command(func() { m.insecure = !m.insecure }),
+		insecureVar,
+		Command(func() { m.toggle(&m.config.Insecure) }),
 	)
+	m.addSync(func() { insecureVar.Set(btoi(m.config.Insecure)) })
 
+	logRequestsVar := Variable(btoi(m.config.LogRequests))
 	logRequests := buttons.Window.Checkbutton(
 		Txt("Log Requests"),
-		Variable(&m.insecure),
-		Command(func() { m.logRequests = !m.logRequests }),
+		logRequestsVar,
+		Command(func() { m.toggle(&m.config.LogRequests) }),
 	)
+	m.addSync(func() { logRequestsVar.Set(btoi(m.config.LogRequests)) })
 
-	dumpCrash := buttons.Window.Checkbutton(
-		Txt("Dump Crashes"),
-		Variable(&m.insecure),
-		Command(func() { m.dumpCrash = !m.dumpCrash }),
+	// The dump mode is selected from mito's -dump options.
+	dump := buttons.Window.Frame()
+	dumpMode := dump.TCombobox(
+		Values(dumpModes),
+		State("readonly"),
+		Width(7),
+		Textvariable(m.dumpMode()),
 	)
+	Bind(dumpMode, "<<ComboboxSelected>>", Command(func() {
+		m.config.Dump = dumpMode.Textvariable()
+		m.saveConfig()
+	}))
+	m.addSync(func() { dumpMode.Configure(Textvariable(m.dumpMode())) })
+	Grid(dump.Label(Txt("Dump")), Row(0), Column(0), Sticky("w"))
+	Grid(dumpMode, Row(0), Column(1), Sticky("ew"))
+	GridColumnConfigure(dump.Window, 1, Weight(1))
+
+	// The limit on evaluations is written into the cfg given to runs.
+	// An empty field leaves it to the cfg pane.
+	limit := buttons.Window.Frame()
+	maxExec := limit.TEntry(Textvariable(m.config.maxExecutionsText()), Width(6))
+	setMaxExec := func() {
+		n, err := parseMaxExecutions(maxExec.Textvariable())
+		if err != nil {
+			Bell()
+			m.announce(err.Error())
+			maxExec.Configure(Textvariable(m.config.maxExecutionsText()))
+			return
+		}
+		if n != m.config.MaxExecutions {
+			m.config.MaxExecutions = n
+			m.saveConfig()
+		}
+	}
+	Bind(maxExec, "<Return>", Command(setMaxExec))
+	Bind(maxExec, "<FocusOut>", Command(setMaxExec))
+	m.addSync(func() { maxExec.Configure(Textvariable(m.config.maxExecutionsText())) })
+	Grid(limit.Label(Txt("Max Exec")), Row(0), Column(0), Sticky("w"))
+	Grid(maxExec, Row(0), Column(1), Sticky("ew"))
+	GridColumnConfigure(limit.Window, 1, Weight(1))
+
+	hexBinaryVar := Variable(btoi(m.config.HexBinary))
+	hexBinary := buttons.Window.Checkbutton(
+		Txt("Hex Binary"),
+		hexBinaryVar,
+		Command(func() { m.toggle(&m.config.HexBinary) }),
+	)
+	m.addSync(func() { hexBinaryVar.Set(btoi(m.config.HexBinary)) })
+
+	for _, d := range []struct {
+		w    Widget
+		name string
+	}{
+		{run, "Run button: run the program"},
+		{cancel, "Cancel button: cancel the run"},
+		{format, "Format button: format the inputs"},
+		{snarf, "Snarf button: copy the inputs as txtar"},
+		{clear, "Clear Output button"},
+		{insecure, "Insecure HTTPS check box"},
+		{logRequests, "Log Requests check box"},
+		{dumpMode, "Dump mode list"},
+		{hexBinary, "Hex Binary check box"},
+		{maxExec, "Max executions field: limit the evaluations of a run"},
+	} {
+		m.describe(d.w, d.name)
+	}
 
 	buttonLayout := [][]Widget{
 		{run, cancel, format, snarf, clear},
-		{insecure, logRequests, dumpCrash},
+		{insecure, logRequests, dump, hexBinary, limit},
+	}
+	if tools := m.toolButtons(buttons); len(tools) != 0 {
+		buttonLayout = append(buttonLayout, tools)
 	}
 	for i, r := range buttonLayout {
 		for j, b := range r {
 			Grid(b, Row(i), Column(j), Sticky("news"))
 			GridColumnConfigure(buttons.Window, j, Weight(1))
+			m.controls = append(m.controls, b)
 		}
 	}
-	// Place the buttons frame in the first row of the left pane.
-	// It should expand horizontally ("ew") but not vertically.
-	Grid(buttons, Row(0), Column(0), Sticky("ew"))
+	// Place the buttons frame in the second row of the left pane, below
+	// the tabs. It should expand horizontally ("ew") but not vertically.
+	Grid(buttons, Row(1), Column(0), Sticky("ew"))
 
-	face := NewFont(Family(font), Size(size))
-	tabWidth := face.Measure(App, strings.Repeat(" ", tw))
+	// The editors and the display have independent fonts.
+	m.editorFont = newFontSet(editorFont, tw)
+	m.displayFont = newFontSet(displayFont, tw)
 
-	// Create and place the three input text widgets in the left pane.
-	for i, input := range []struct {
-		name string
-		text **TextWidget
+	// The input text widgets are held in a vertical paned window below the
+	// buttons so that their relative heights can be adjusted.
+	m.inputs = leftPane.TPanedwindow(Orient("vertical"))
+	Grid(m.inputs, Row(2), Column(0), Sticky("news"))
+	GridRowConfigure(leftPane, 2, Weight(1))
+	// The find bar is shown below the input panes when needed.
+	m.find = m.newFindBar(leftPane.Window)
+
+	// Create the input text widgets. The stdin pane is hidden unless
+	// the user has chosen to show it.
+	if conf.HiddenPanes == nil {
+		conf.HiddenPanes = []string{"stdin", "params", "mock"}
+	}
+	var srcFrame *FrameWidget
+	for _, input := range []struct {
+		name  string
+		title string
+		text  **TextWidget
 	}{
-		{name: "src (CEL)", text: &m.src},
-		{name: "data (JSON)", text: &m.data},
-		{name: "cfg (YAML)", text: &m.cfg},
+		{name: "src", title: "src (CEL)", text: &m.src},
+		{name: "data", title: "data (JSON)", text: &m.data},
+		{name: "cfg", title: "cfg (YAML)", text: &m.cfg},
+		{name: "stdin", title: "stdin", text: &m.stdin},
+		{name: "params", title: "params (name=JSON, as state.params)", text: &m.params},
+		{name: "mock", title: "mock (YAML routes, as mock_url)", text: &m.mock},
 	} {
 		// Each text widget gets its own frame.
 		frame := leftPane.Frame()
-		textWidget(input.text, frame, input.name, face, tabWidth, true)
-		Grid(frame, Row(i+1), Column(0), Sticky("news"))
-		// Configure the row in the left pane to expand vertically.
-		GridRowConfigure(leftPane, i+1, Weight(1))
+		label := textWidget(input.text, frame, input.title, m.editorFont.face, m.editorFont.tabWidth, true)
+		m.editorFont.add(*input.text)
+		w := *input.text
+		title := input.title
+		showModified(w, label, title)
+		Bind(w, "<FocusIn>", Command(func() {
+			m.editor = w
+			m.status.dirty = true
+			m.find.dirty = true
+			m.announce(title + " editor")
+		}))
+		m.track(w)
+		m.bindKey(w, "<Tab>", shortcut{"Edit", "Tab", "Indent the line or selection"}, func(e *Event) { m.insertTab(w, e) })
+		// Prevent the text class's Control-f binding from moving the
+		// cursor when opening the find bar.
+		m.bindKey(w, "<Control-f>", findShortcut, func(e *Event) {
+			m.showFind()
+			e.SetReturnCodeBreak()
+		})
+		if input.text == &m.src {
+			srcFrame = frame
+			// Help on a known mito function takes the place of the
+			// shortcut list.
+			m.bindKey(w, "<F1>", shortcut{"Edit", "F1 in src", "Show the documentation of the mito function at the cursor"}, func(e *Event) {
+				if m.lookupDocs() {
+					e.SetReturnCodeBreak()
+				}
+			})
+		}
+		if input.text == &m.data {
+			m.payloads = m.newPayloadBar(frame)
+		}
+		m.onChange(w, func() {
+			m.draft.dirty = true
+			m.find.dirty = true
+		})
+		m.panes = append(m.panes, &pane{
+			name:  input.name,
+			frame: frame,
+			text:  w,
+			// The mock pane is only shown while the mock server
+			// is in use.
+			shown: !slices.Contains(conf.HiddenPanes, input.name) && (input.name != "mock" || conf.Mock),
+		})
 	}
+	m.layoutPanes()
+	// Sash positions can only be restored once the paned window has
+	// been given its size, so wait for the first configure event.
+	var restored bool
+	Bind(m.inputs, "<Configure>", Command(func() {
+		if !restored {
+			restored = true
+			m.restoreSashes()
+		}
+	}))
+	Bind(m.inputs, "<ButtonRelease-1>", Command(m.saveSashes))
 
 	// --- Configure the Right Pane ---
 	// This pane contains the single output display widget.
@@ -278,34 +614,470 @@ func newMiko(font string, size, tw int, poll time.Duration) *miko {
 	GridRowConfigure(rightPane, 0, Weight(1))
 	GridColumnConfigure(rightPane, 0, Weight(1))
 	displayFrame := rightPane.Frame()
-	textWidget(&m.display, displayFrame, "", face, tabWidth, false)
+	textWidget(&m.display, displayFrame, "", m.displayFont.face, m.displayFont.tabWidth, false)
+	m.displayFont.add(m.display)
+	m.describe(m.display, "Output display")
 	Grid(displayFrame, Row(0), Column(0), Sticky("news"))
+	m.keys = m.newKeyFilter(rightPane.Window)
+
+	m.lockDisplay()
+	// The display's tags are created in order of priority and are
+	// coloured by applyTheme.
+	for _, tt := range displayTags {
+		m.display.TagConfigure(tt.tag)
+	}
+	m.configureSnapshot()
+	m.configureLogs()
+	m.configureRepeats()
+	m.configureTrees()
+	m.configureDataFile()
+	m.configureProfile()
+	m.configureErrors()
+
+	// Context menu for the output display. Entries are enabled or
+	// disabled depending on the current selection when the menu is
+	// posted.
+	displayMenu := App.Menu()
+	decode := displayMenu.AddCommand(
+		Lbl("Decode base64"),
+		Command(func() {
+			b, ok := decodeBase64(selection(m.display))
+			if !ok {
+				return
+			}
+			m.showText("Decoded base64", renderBytes(b))
+		}),
+	)
+	inflate := displayMenu.AddCommand(
+		Lbl("Decompress base64"),
+		Command(func() {
+			b, ok := decodeBase64(selection(m.display))
+			if !ok {
+				return
+			}
+			b, kind, ok := decompress(b)
+			if !ok {
+				return
+			}
+			m.showText("Decompressed "+kind, renderBytes(b))
+		}),
+	)
+	viewHex := displayMenu.AddCommand(
+		Lbl("View as hex"),
+		Command(func() {
+			sel := selection(m.display)
+			if sel == "" {
+				return
+			}
+			m.showText("Hex", hex.Dump([]byte(jsonString(sel))))
+		}),
+	)
+	// Pinning an event keeps it in view while output streams in.
+	var pinAt string
+	displayMenu.AddSeparator()
+	pinItem := displayMenu.AddCommand(Lbl("Pin Event"), Command(func() {
+		if m.pinned {
+			m.unpin()
+		} else {
+			m.pin(pinAt)
+		}
+	}))
+	var treeAt string
+	treeItem := displayMenu.AddCommand(Lbl("Show Event as Tree"), Command(func() { m.toggleTree(treeAt) }))
+	displayMenu.AddCommand(Lbl("Pipe Output to CEL..."), Command(m.pipeToCEL))
+	Bind(m.display, "<Button-3>", Command(func(e *Event) {
+		sel := selection(m.display)
+		state, inflateState := "disabled", "disabled"
+		if b, ok := decodeBase64(sel); ok {
+			state = "normal"
+			if _, _, ok := decompress(b); ok {
+				inflateState = "normal"
+			}
+		}
+		displayMenu.EntryConfigure(decode, State(state))
+		displayMenu.EntryConfigure(inflate, State(inflateState))
+		state = "disabled"
+		if sel != "" {
+			state = "normal"
+		}
+		displayMenu.EntryConfigure(viewHex, State(state))
+		pinAt = fmt.Sprintf("@%d,%d", e.X, e.Y)
+		lbl := "Pin Event"
+		if m.pinned {
+			lbl = "Unpin Event"
+		}
+		displayMenu.EntryConfigure(pinItem, Lbl(lbl))
+		var ok bool
+		treeAt, ok = m.eventAt(pinAt)
+		state = "disabled"
+		if ok {
+			state = "normal"
+		}
+		lbl = "Show Event as Tree"
+		if _, shown := m.trees.trees[treeAt]; shown {
+			lbl = "Show Event as Text"
+		}
+		displayMenu.EntryConfigure(treeItem, Lbl(lbl), State(state))
+		Popup(displayMenu.Window, e.XRoot, e.YRoot, nil)
+	}))
+
+	// The src context menu runs part of the program.
+	srcMenu := App.Menu()
+	runSelection := srcMenu.AddCommand(Lbl("Run Selection"), Command(m.runSelection))
+	Bind(m.src, "<Button-3>", Command(func(e *Event) {
+		lbl := "Run Selection"
+		if selection(m.src) == "" {
+			lbl = "Run"
+		}
+		srcMenu.EntryConfigure(runSelection, Lbl(lbl))
+		Popup(srcMenu.Window, e.XRoot, e.YRoot, nil)
+	}))
+
+	// The minimap is placed to the right of the src scroll bar when shown.
+	m.minimap = newMinimap(srcFrame.Window, m.src, tw)
+	m.onChange(m.src, func() { m.minimap.dirty = true })
+	// The fold gutter is placed to the left of src when shown.
+	m.folds = newFolds(srcFrame.Window, m.src)
+	m.onChange(m.src, func() { m.folds.dirty = true })
+
+	m.guides = newIndentGuides(tw)
+	for _, w := range []*TextWidget{m.src, m.data, m.cfg} {
+		m.guides.add(w)
+		m.onChange(w, func() { m.guides.dirty[w] = true })
+	}
+
+	// Brackets are coloured in the program and in the JSON output, but
+	// not in notes, errors or logs.
+	m.rainbow = newRainbow()
+	m.waterfall = &waterfall{}
+	m.captured = &captured{}
+	m.history = &runHistory{}
+	m.rainbow.add(m.src, "")
+	m.onChange(m.src, func() { m.rainbow.dirty[m.src] = true })
+	m.rainbow.add(m.display, "output")
+	m.onChange(m.display, func() { m.rainbow.dirty[m.display] = true })
+
+	// The menu bar holds options that are not needed for every run.
+	menubar := App.Menu()
+	view := menubar.Menu()
+	for _, p := range m.panes {
+		m.menuCheck(view, "Show "+p.name, &p.shown, m.layoutPanes)
+	}
+	view.AddSeparator()
+	m.menuCheck(view, "Unlock Output for Editing", &m.unlocked, m.toggleUnlocked)
+	view.AddCommand(Lbl("Highlight Key in Output..."), Accelerator("Ctrl+Shift+K"), Command(m.showKeyFilter))
+	m.bindKey(App, "<Control-Shift-Key-K>", shortcut{"View", "Ctrl+Shift+K", "Highlight a JSON key in all output events"}, m.showKeyFilter)
+	view.AddCommand(Lbl("Show All Events as Trees"), Accelerator("Ctrl+Shift+E"), Command(m.showAllTrees))
+	view.AddCommand(Lbl("Show All Events as Text"), Accelerator("Ctrl+Shift+C"), Command(m.hideAllTrees))
+	m.bindKey(App, "<Control-Shift-Key-E>", shortcut{"View", "Ctrl+Shift+E", "Show all output events as trees"}, m.showAllTrees)
+	m.bindKey(App, "<Control-Shift-Key-C>", shortcut{"View", "Ctrl+Shift+C", "Show all output events as text"}, m.hideAllTrees)
+	view.AddSeparator()
+	m.menuCheck(view, "Minimap", &m.minimap.shown, func() {
+		if m.minimap.shown {
+			m.minimap.dirty = true
+			Grid(m.minimap.canvas, Row(1), Column(3), Sticky("ns"))
+		} else {
+			GridForget(m.minimap.canvas.Window)
+		}
+	})
+	m.menuCheck(view, "Indentation Guides", &m.guides.shown, m.guides.toggle)
+	m.menuCheck(view, "Code Folding", &m.folds.shown, m.folds.toggle)
+	m.menuCheck(view, "Rainbow Brackets", &m.rainbow.shown, m.rainbow.toggle)
+	m.menuCheck(view, "Expand JSON in Strings", &m.config.NestedJSON, m.saveConfig)
+	m.menuCheck(view, "Collapse Repeated Output", &m.config.CollapseRepeats, m.saveConfig)
+	m.menuCheck(view, "Raw Output", &m.config.RawOutput, m.saveConfig)
+	m.menuCheck(view, "Wrap Error Lines", &m.config.WrapErrors, func() {
+		m.wrapErrors()
+		m.saveConfig()
+	})
+	view.AddSeparator()
+	themes := view.Menu()
+	m.menuRadio(themes, themeNames, func() string { return cmp.Or(m.config.Theme, "light") }, m.setTheme)
+	themes.AddCommand(Lbl("Load Theme File..."), Command(m.loadThemeFile))
+	themes.AddCommand(Lbl("Import VS Code or TextMate Theme..."), Command(m.importThemeFile))
+	view.AddCascade(Lbl("Theme"), Mnu(themes))
+	view.AddCommand(Lbl("Editor Font..."), Command(func() {
+		chooseFont("Editor Font", m.editorFont, func(fc fontConfig) {
+			m.config.EditorFont = &fc
+			m.saveConfig()
+		})
+	}))
+	view.AddCommand(Lbl("Display Font..."), Command(func() {
+		chooseFont("Display Font", m.displayFont, func(fc fontConfig) {
+			m.config.DisplayFont = &fc
+			m.saveConfig()
+		})
+	}))
+	view.AddSeparator()
+	view.AddCommand(Lbl("Maximize Output"), Accelerator("Ctrl+Shift+M"), Command(m.toggleMaximized))
+	m.bindKey(App, "<Control-Shift-Key-M>", shortcut{"View", "Ctrl+Shift+M", "Maximize or restore the output"}, m.toggleMaximized)
+
+	runMenu := menubar.Menu()
+	runMenu.AddCommand(Lbl("Run Insecure Once"), Accelerator("Shift+Click Run"), Command(func() { m.run(runOptions{insecure: true}) }))
+	runMenu.AddCommand(Lbl("Export Shell Script..."), Command(m.exportScript))
+	runMenu.AddCommand(Lbl("Request Waterfall"), Command(m.showWaterfall))
+	runMenu.AddCommand(Lbl("Replay Requests..."), Command(m.showReplay))
+	runMenu.AddCommand(Lbl("Pipe Output to CEL..."), Command(m.pipeToCEL))
+	runMenu.AddCommand(Lbl("Run History..."), Command(m.showHistory))
+	runMenu.AddCommand(Lbl("Note Latest Run..."), Command(m.noteLatest))
+	runMenu.AddCommand(Lbl("Export Run History..."), Command(m.exportHistory))
+	m.menuCheck(runMenu, "Use Mock Server", &m.config.Mock, m.toggleMock)
+	m.menuCheck(runMenu, "Tee Output to File...", &m.tee.on, m.toggleTee)
+	m.menuCheck(runMenu, "Don't Display Teed Output", &m.tee.hide, nil)
+	m.menuCheck(runMenu, "Record Responses to Mock", &m.recording, func() {
+		// Recording needs the real responses.
+		if m.recording && m.config.Mock {
+			m.config.Mock = false
+			m.toggleMock()
+			m.sync()
+		}
+	})
+	runMenu.AddSeparator()
+	runMenu.AddCommand(Lbl("Set Working Directory..."), Command(func() {
+		dir := ChooseDirectory(Initialdir(m.config.WorkDir), Mustexist(true))
+		if dir == "" {
+			return
+		}
+		m.config.WorkDir = dir
+		m.saveConfig()
+	}))
+	runMenu.AddCommand(Lbl("Use Temporary Working Directory"), Command(func() {
+		m.config.WorkDir = ""
+		m.saveConfig()
+	}))
+	runMenu.AddSeparator()
+	var accumulate bool
+	m.addSync(func() { accumulate = m.outputMode() == "accumulate" })
+	m.menuCheck(runMenu, "Don't Clear Between Runs", &accumulate, func() {
+		m.config.RunOutput = "clear"
+		if accumulate {
+			m.config.RunOutput = "accumulate"
+		}
+		m.saveConfig()
+	})
+	runMenu.AddSeparator()
+	runMenu.AddCommand(Lbl("Show Temporary Directories"), Command(m.showTempDirs))
+	runMenu.AddCommand(Lbl("Remove Stale Temporary Directories"), Command(func() {
+		for _, r := range m.runners() {
+			m.printError(r.removeStale(false))
+		}
+	}))
+
+	edit := menubar.Menu()
+	m.menuCheck(edit, "Insert Spaces for Tab", &m.config.ExpandTabs, m.saveConfig)
+	indent := edit.Menu()
+	m.menuRadio(indent, []string{"2", "4", "8"}, func() string { return strconv.Itoa(m.indentWidth()) }, func(s string) {
+		m.config.IndentWidth, _ = strconv.Atoi(s)
+		m.saveConfig()
+	})
+	edit.AddCascade(Lbl("Indent Width"), Mnu(indent))
+	m.menuCheck(edit, "Keep Trailing White Space on Format", &m.config.KeepTrailingSpace, m.saveConfig)
+	m.menuCheck(edit, "Remove Trailing White Space on Save", &m.config.TrimOnSave, m.saveConfig)
+	m.menuCheck(edit, "Format Data as Typed", &m.config.FormatData, m.saveConfig)
+	edit.AddCommand(Lbl("Convert Tabs to Spaces"), Command(func() {
+		if m.editor != nil {
+			m.convertTabs(m.editor)
+		}
+	}))
+	edit.AddCommand(Lbl("Insert cfg Template"), Command(m.insertCfgTemplate))
+	edit.AddSeparator()
+	edit.AddCommand(Lbl("Find..."), Accelerator("Ctrl+F"), Command(m.showFind))
+	edit.AddCommand(Lbl("Find in All Inputs..."), Accelerator("Ctrl+Shift+F"), Command(m.showFindAll))
+	m.bindKey(App, "<Control-f>", findShortcut, m.showFind)
+	m.bindKey(App, "<Control-Shift-Key-F>", shortcut{"Edit", "Ctrl+Shift+F", "Find in all inputs"}, m.showFindAll)
+	edit.AddSeparator()
+	edit.AddCommand(Lbl("Share Link..."), Command(m.share))
+	edit.AddCommand(Lbl("Import from Link..."), Command(m.importLink))
+	edit.AddCommand(Lbl("Export to Directory..."), Command(m.exportDir))
+	edit.AddCommand(Lbl("Import from Directory..."), Command(m.importDir))
+	edit.AddCommand(Lbl("New Tab"), Accelerator("Ctrl+Shift+T"), Command(m.newTab))
+	m.bindKey(App, "<Control-Shift-Key-T>", shortcut{"Edit", "Ctrl+Shift+T", "Open a new tab"}, m.newTab)
+	edit.AddCommand(Lbl("Duplicate in New Window"), Command(func() {
+		m.printError(m.duplicate())
+	}))
+	edit.AddSeparator()
+	edit.AddCommand(Lbl("Settings..."), Command(m.showSettings))
+	menubar.AddCascade(Lbl("Edit"), Mnu(edit))
+	menubar.AddCascade(Lbl("View"), Mnu(view))
+	menubar.AddCascade(Lbl("Run"), Mnu(runMenu))
+	menubar.AddCascade(Lbl("Examples"), Mnu(m.examplesMenu(menubar)))
+	help := menubar.Menu()
+	help.AddCommand(Lbl("Keyboard Shortcuts"), Accelerator("F1"), Command(m.showShortcuts))
+	m.bindKey(App, "<F1>", shortcut{"View", "F1", "Show this list of shortcuts"}, m.showShortcuts)
+	menubar.AddCascade(Lbl("Help"), Mnu(help))
+	App.Configure(Mnu(menubar))
 
-	m.display.Configure(State("disabled"))
-	m.display.TagConfigure("output", Foreground("black"))
-	m.display.TagConfigure("error", Foreground("red"))
+	// Escape dismisses transient UI or, if there is none, cancels
+	// the current run.
+	m.bindKey(App, "<F8>", shortcut{"Navigate", "F8", "Go to the next error in the output"}, func() { m.nextError(true) })
+	m.bindKey(App, "<Shift-F8>", shortcut{"Navigate", "Shift+F8", "Go to the previous error in the output"}, func() { m.nextError(false) })
+	m.bindKey("all", "<Escape>", shortcut{"Run", "Escape", "Close the topmost popup, bar or dialog, or else cancel the run"}, m.escape)
+
+	// Confirm before closing the window discards a run or edits.
+	WmProtocol(App, "WM_DELETE_WINDOW", m.quit)
 
 	Focus(m.src)
 
+	theme, errs := loadTheme(cmp.Or(conf.Theme, "light"))
+	for _, err := range errs {
+		m.printError(err)
+	}
+	m.applyTheme(theme)
+	m.applyTargets()
+	m.trackHover(m.src)
+	m.trackSignature()
+	m.trackTypeFormat()
+	m.trackCfg()
+	m.trackLint()
+
 	NewTicker(poll, func() {
-		select {
-		case text := <-m.results:
-			m.display.Configure(State("normal"))
-			m.display.Insert("end", text.data+"\n", text.tag)
-			m.display.See(END)
-			m.display.Configure(State("disabled"))
-		default:
-		}
+		m.minimap.update()
+		m.guides.update()
+		m.folds.update()
+		m.rainbow.update()
+		m.autosave()
+		m.updateStatus()
+		m.updateFind()
+		m.updateKeyFilter()
+		m.updateActivity()
+		m.ringBell()
+		m.updateWaterfall()
+		m.updateReplay()
+		m.updateHistory()
+		m.updateHover()
+		m.updateSignature()
+		m.formatAsTyped()
+		m.updateCfgCheck()
+		m.drain()
+		m.runBatch()
 	})
 
 	return m
 }
 
-func textWidget(dst **TextWidget, frame *FrameWidget, title string, face *FontFace, tabWidth int, undo bool) {
+// toggle inverts the configuration option v and saves the configuration.
+func (m *miko) toggle(v *bool) {
+	*v = !*v
+	m.saveConfig()
+}
+
+// dumpModes are the evaluation state dump modes offered for mito's -dump
+// flag. The none mode omits the flag.
+var dumpModes = []string{"none", "error", "always"}
+
+// dumpMode returns the configured dump mode, defaulting to none.
+func (m *miko) dumpMode() string {
+	if slices.Contains(dumpModes, m.config.Dump) {
+		return m.config.Dump
+	}
+	return "none"
+}
+
+// layoutPanes places the shown input panes in the inputs paned window
+// and records the hidden panes in the configuration. Hidden panes
+// retain their content and are still used when running a program.
+func (m *miko) layoutPanes() {
+	managed := strings.Fields(EvalErr(fmt.Sprintf("%s panes", m.inputs)))
+	var hidden []string
+	for _, p := range m.panes {
+		if slices.Contains(managed, p.frame.String()) {
+			EvalErr(fmt.Sprintf("%s forget %s", m.inputs, p.frame))
+		}
+		if p.shown {
+			m.inputs.Add(p.frame.Window, Weight(1))
+		} else {
+			hidden = append(hidden, p.name)
+		}
+	}
+	if !slices.Equal(hidden, m.config.HiddenPanes) {
+		m.config.HiddenPanes = hidden
+		m.saveConfig()
+	}
+}
+
+// toggleMaximized collapses the left pane by moving the main sash to
+// the left edge of the window so that the output fills the window, or
+// returns the sash to its previous position if already collapsed.
+func (m *miko) toggleMaximized() {
+	if m.maximized {
+		EvalErr(fmt.Sprintf("%s sashpos 0 %d", m.paned, m.prevSash))
+		m.maximized = false
+		return
+	}
+	pos, err := strconv.Atoi(EvalErr(fmt.Sprintf("%s sashpos 0", m.paned)))
+	if err != nil {
+		m.printError(err)
+		return
+	}
+	m.prevSash = pos
+	EvalErr(fmt.Sprintf("%s sashpos 0 0", m.paned))
+	m.maximized = true
+}
+
+// saveSashes records the positions of the sashes between the input panes.
+func (m *miko) saveSashes() {
+	n := len(strings.Fields(EvalErr(fmt.Sprintf("%s panes", m.inputs))))
+	var sashes []int
+	for i := range n - 1 {
+		pos, err := strconv.Atoi(EvalErr(fmt.Sprintf("%s sashpos %d", m.inputs, i)))
+		if err != nil {
+			return
+		}
+		sashes = append(sashes, pos)
+	}
+	if !slices.Equal(sashes, m.config.Sashes) {
+		m.config.Sashes = sashes
+		m.saveConfig()
+	}
+}
+
+// restoreSashes moves the sashes between the input panes to their
+// recorded positions.
+func (m *miko) restoreSashes() {
+	n := len(strings.Fields(EvalErr(fmt.Sprintf("%s panes", m.inputs))))
+	for i, pos := range m.config.Sashes {
+		if i >= n-1 {
+			break
+		}
+		EvalErr(fmt.Sprintf("%s sashpos %d %d", m.inputs, i, pos))
+	}
+}
+
+// saveConfig writes the current configuration, reporting any error in
+// the display.
+func (m *miko) saveConfig() {
+	err := m.config.save()
+	if err != nil {
+		m.printError(fmt.Errorf("saving config: %w", err))
+	}
+}
+
+// getSaveFile asks for a file to save to, starting in the directory of
+// the last file saved and remembering the directory of the chosen file
+// for next time. It returns the empty string if the user cancels.
+func (m *miko) getSaveFile(options ...Opt) string {
+	if dir := m.config.SaveDir; dir != "" {
+		options = append(options, Initialdir(dir))
+	}
+	path := GetSaveFile(options...)
+	if path == "" {
+		return ""
+	}
+	if dir := filepath.Dir(path); dir != m.config.SaveDir {
+		m.config.SaveDir = dir
+		m.saveConfig()
+	}
+	return path
+}
+
+// textWidget creates a scrolled text widget in frame, stored in dst, and
+// returns its title label, or nil if title is empty.
+func textWidget(dst **TextWidget, frame *FrameWidget, title string, face *FontFace, tabWidth int, undo bool) *LabelWidget {
 	w := frame.Window
-	// Configure the grid within the widget's frame to allow the text area to expand.
+	// Configure the grid within the widget's frame to allow the text area
+	// to expand. Column 0 is left free for a gutter beside the text.
 	GridRowConfigure(w, 1, Weight(1))
-	GridColumnConfigure(w, 0, Weight(1))
+	GridColumnConfigure(w, 1, Weight(1))
 
 	scrollX := Autoscroll(w.TScrollbar(Command(func(e *Event) { e.Xview(*dst) }), Orient("horizontal")).Window)
 	scrollY := Autoscroll(w.TScrollbar(Command(func(e *Event) { e.Yview(*dst) }), Orient("vertical")).Window)
@@ -321,14 +1093,208 @@ func textWidget(dst **TextWidget, frame *FrameWidget, title string, face *FontFa
 		Xscrollcommand(func(e *Event) { e.ScrollSet(scrollX) }),
 		Yscrollcommand(func(e *Event) { e.ScrollSet(scrollY) }),
 	)
+	var label *LabelWidget
 	if title != "" {
-		Grid(w.Label(Anchor("w"), Txt(title)), Row(0), Column(0), Sticky("w"))
+		label = w.Label(Anchor("w"), Txt(title))
+		Grid(label, Row(0), Column(0), Columnspan(2), Sticky("w"))
 	}
 	// The text widget expands in all directions ("news").
-	Grid(*dst, Row(1), Column(0), Sticky("news"))
+	Grid(*dst, Row(1), Column(1), Sticky("news"))
 	// The scrollbars only expand in their respective directions.
-	Grid(scrollY, Row(1), Column(1), Sticky("ns"))
-	Grid(scrollX, Row(2), Column(0), Sticky("ew"))
+	Grid(scrollY, Row(1), Column(2), Sticky("ns"))
+	Grid(scrollX, Row(2), Column(1), Sticky("ew"))
+	bindWheel(*dst, scrollX, scrollY)
+	return label
+}
+
+// resultsBuffer is the capacity of the results channel. Results that
+// do not fit are dropped rather than blocking the reading of mito's
+// output.
+const resultsBuffer = 4096
+
+// send sends t to the display without blocking. If the results channel
+// is full, t is dropped and counted, and a note of the number dropped
+// is sent before the next result that fits.
+func (m *miko) send(t text) {
+	if n := m.dropped.Load(); n != 0 {
+		note := lagging(n)
+		note.doc = t.doc
+		select {
+		case m.results <- note:
+			m.dropped.Add(-n)
+		default:
+			m.dropped.Add(1)
+			return
+		}
+	}
+	select {
+	case m.results <- t:
+	default:
+		m.dropped.Add(1)
+	}
+}
+
+// flushDropped sends any outstanding note of dropped results for the
+// document with id doc, waiting for room in the results channel.
+func (m *miko) flushDropped(doc int) {
+	if n := m.dropped.Swap(0); n != 0 {
+		t := lagging(n)
+		t.doc = doc
+		m.results <- t
+	}
+}
+
+func lagging(n int64) text {
+	return text{data: fmt.Sprintf("(display lagging: %d lines dropped)", n), tag: "note"}
+}
+
+// maxDrain is the maximum number of results added to the display in
+// each poll, so that a burst of output cannot starve the UI.
+const maxDrain = 500
+
+// placedSpan is a span of a text placed in a batch of text inserted in
+// the display, with lines counted from the batch's first line.
+type placedSpan struct {
+	tag        string
+	start, end string
+}
+
+// batch is the results collected by one drain, ready to be inserted in
+// the display.
+type batch struct {
+	runs   []string // Alternating text and tag.
+	events []int    // Lines at which output events start, from the first line inserted.
+	spans  []placedSpan
+	errors []text // Errors whose location is marked in the program.
+	ended  bool   // The current run has ended.
+	record bool   // The responses of the run that ended are to be recorded.
+}
+
+// drain adds the results that are waiting to the display, up to
+// maxDrain. Consecutive results with the same tag are inserted as a
+// single run of text, and the spans of results are tagged once the text
+// is inserted. Once the results channel is closed it is no longer
+// drained.
+func (m *miko) drain() {
+	if m.closed {
+		return
+	}
+	b := m.collect()
+	for _, t := range b.errors {
+		m.markError(t)
+	}
+	if b.ended {
+		m.runFinished()
+	}
+	if b.record {
+		m.recordResponses()
+	}
+	if len(b.runs) == 0 {
+		return
+	}
+	m.display.Configure(State("normal"))
+	start, _ := position(m.display.Index("end-1c"))
+	m.display.Insert("end", b.runs[0], b.runs[1:]...)
+	for i := range b.events {
+		b.events[i] += start
+	}
+	m.markEvents(b.events)
+	for _, sp := range b.spans {
+		m.display.TagAdd(sp.tag, shiftIndex(sp.start, start), shiftIndex(sp.end, start))
+	}
+	m.updateRepeats()
+	m.trimOutput()
+	m.follow()
+	m.lockDisplay()
+	m.restoreTrees()
+}
+
+// collect takes up to maxDrain waiting results, first those held for
+// the current document and then those on the results channel, and
+// returns them as a batch. Results of other documents are held for them.
+// If the channel is found to be closed, m.closed is set.
+func (m *miko) collect() batch {
+	var (
+		b     batch
+		buf   strings.Builder
+		tag   string
+		lines int // Lines in b.runs and buf.
+	)
+loop:
+	for range maxDrain {
+		var t text
+		if len(m.held) != 0 {
+			t, m.held = m.held[0], m.held[1:]
+		} else {
+			select {
+			case r, ok := <-m.results:
+				if !ok {
+					// A closed channel would otherwise give a
+					// stream of empty results.
+					m.closed = true
+					break loop
+				}
+				t = r
+			default:
+				break loop
+			}
+		}
+		// Results of runs of other tabs are kept for them.
+		if t.doc != 0 && t.doc != m.tabs.currentID() {
+			m.tabs.hold(t)
+			continue
+		}
+		if t.tag == "runEnded" {
+			// The end of a run that has been superseded is not
+			// announced, and its logged requests have been
+			// replaced.
+			if m.runs.current(t.gen) {
+				b.ended = true
+				b.record = b.record || t.record
+			}
+			continue
+		}
+		m.status.events++
+		if slices.Contains(errorTags, t.tag) {
+			m.status.errors++
+		}
+		if t.tag == "compileError" || t.tag == "runtimeError" {
+			b.errors = append(b.errors, t)
+		}
+		tTag, marker := m.repeat(t)
+		if marker {
+			if buf.Len() != 0 {
+				b.runs = append(b.runs, buf.String(), tag)
+				buf.Reset()
+			}
+			b.runs = append(b.runs, repeatMarker(2)+"\n", "repeatCount")
+			lines++
+		}
+		if buf.Len() != 0 && tTag != tag {
+			b.runs = append(b.runs, buf.String(), tag)
+			buf.Reset()
+		}
+		tag = tTag
+		if tTag == "output" {
+			b.events = append(b.events, lines)
+		}
+		// Spans are placed by line relative to the first line
+		// inserted.
+		t.spans = append(t.spans, m.keys.eventKeySpans(t)...)
+		if len(t.spans) != 0 {
+			at := indices(t.data, t.spans)
+			for _, sp := range t.spans {
+				b.spans = append(b.spans, placedSpan{sp.tag, shiftIndex(at[sp.start], lines-1), shiftIndex(at[sp.end], lines-1)})
+			}
+		}
+		lines += strings.Count(t.data, "\n") + 1
+		buf.WriteString(t.data)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() != 0 {
+		b.runs = append(b.runs, buf.String(), tag)
+	}
+	return b
 }
 
 func (m *miko) printError(err error) {
@@ -337,90 +1303,451 @@ func (m *miko) printError(err error) {
 	}
 	m.display.Configure(State("normal"))
 	m.display.Insert("end", err.Error()+"\n", "error")
-	m.display.Configure(State("disabled"))
+	m.lockDisplay()
+}
+
+// selection returns the currently selected text in w, or the empty string
+// if there is no selection.
+func selection(w *TextWidget) string {
+	if len(w.TagRanges("sel")) == 0 {
+		return ""
+	}
+	return w.Get("sel.first", "sel.last")[0]
+}
+
+// showText opens a read-only window holding body.
+func (m *miko) showText(title, body string) {
+	top := App.Toplevel()
+	top.WmTitle(title)
+	GridRowConfigure(top.Window, 0, Weight(1))
+	GridColumnConfigure(top.Window, 0, Weight(1))
+	frame := top.Frame()
+	var text *TextWidget
+	textWidget(&text, frame, "", m.displayFont.face, m.displayFont.tabWidth, false)
+	text.Insert("end", body)
+	text.Configure(State("disabled"))
+	Grid(frame, Row(0), Column(0), Sticky("news"))
+	m.dialog(top)
+	Focus(text)
+}
+
+// showTempDirs lists the temporary directories created for the runs of
+// every tab that have not yet been removed.
+func (m *miko) showTempDirs() {
+	var buf strings.Builder
+	for _, r := range m.runners() {
+		dirs, current := r.tempDirs()
+		for _, dir := range dirs {
+			buf.WriteString(dir)
+			if dir == current {
+				buf.WriteString(" (running)")
+			}
+			buf.WriteByte('\n')
+		}
+	}
+	if buf.Len() == 0 {
+		buf.WriteString("No temporary directories.\n")
+	}
+	m.showText("Temporary Directories", buf.String())
+}
+
+// cancel kills the running mito process, if any, and abandons any
+// remaining Run All documents.
+func (m *miko) cancel() {
+	m.stopBatch()
+	err := m.runs.cancel()
+	if err != nil {
+		m.printError(err)
+	}
 }
 
-func (*miko) main() {
+// duplicate starts another miko process holding a copy of the inputs.
+// The archive is passed on the new process's standard input so that no
+// temporary file is needed. The new process has its own runs and
+// temporary directories.
+func (m *miko) duplicate() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("duplicating window: %w", err)
+	}
+	cmd := execabs.Command(exe, "-txtar", "-", "-mito", m.mitoPath, "-celfmt", m.celfmtPath)
+	cmd.Stdin = bytes.NewReader(txtar.Format(m.archive(false)))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("duplicating window: %w", err)
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// archive returns the non-empty inputs as a txtar archive, with the
+// output display if out is true.
+func (m *miko) archive(out bool) *txtar.Archive {
+	var ar txtar.Archive
+	for _, f := range []struct {
+		name string
+		text *TextWidget
+	}{
+		{name: "src.cel", text: m.src},
+		{name: "data.json", text: m.data},
+		{name: "cfg.yaml", text: m.cfg},
+		{name: "stdin.txt", text: m.stdin},
+		{name: "params.txt", text: m.params},
+		{name: "mock.yaml", text: m.mock},
+	} {
+		if text := f.text.Text(); text != "" {
+			ar.Files = append(ar.Files, txtar.File{Name: f.name, Data: []byte(text)})
+		}
+	}
+	if out {
+		if text := m.outputText(); text != "" {
+			ar.Files = append(ar.Files, txtar.File{Name: "out.json", Data: []byte(text)})
+		}
+	}
+	return &ar
+}
+
+// load sets the inputs from the files in ar.
+func (m *miko) load(ar *txtar.Archive) {
+	for _, f := range ar.Files {
+		switch f.Name {
+		case "src.cel":
+			m.src.Insert("end", string(f.Data))
+		case "data.json":
+			m.data.Insert("end", string(f.Data))
+		case "cfg.yaml":
+			m.cfg.Insert("end", string(f.Data))
+		case "stdin.txt":
+			m.stdin.Insert("end", string(f.Data))
+		case "params.txt":
+			m.params.Insert("end", string(f.Data))
+		case "mock.yaml":
+			m.mock.Insert("end", string(f.Data))
+		}
+	}
+}
+
+func (m *miko) main() {
 	App.Wait()
+	// The session ended cleanly, so the draft is not needed.
+	m.clearDraft()
+	for _, r := range m.runners() {
+		r.cancel()
+		err := r.removeStale(true)
+		if err != nil {
+			log.Printf("removing temporary directories: %v", err)
+		}
+	}
+	err := m.cache.remove()
+	if err != nil {
+		log.Printf("removing cached inputs: %v", err)
+	}
+}
+
+// run starts a run of the current inputs, replacing any current run.
+// The program is taken from the src pane unless opts gives one, and the
+// data is taken from the data pane.
+func (m *miko) run(opts runOptions) {
+	m.stopBatch()
+	gen, err := m.runs.begin()
+	m.prepareOutput()
+	if err != nil {
+		m.printError(err)
+	}
+	m.clearErrors()
+	m.piped = false
+	opts, m.srcOffset = runSource(opts, m.src, m.data)
+	m.lintBeforeRun(opts.src, m.cfg.Text())
+	opts.dataFile = m.dataFile
+	m.warnLargeData(opts.data)
+	err = m.mito(gen, opts)
+	if err != nil {
+		m.printError(err)
+	}
+}
+
+// editorReader is the part of a text widget read to start a run. It
+// has no way to move the cursor or change the selection, so starting a
+// run cannot disturb the editors.
+type editorReader interface {
+	Text() string
+	Index(index any) string
+	TagRanges(tagName string) []string
+}
+
+// runSource returns opts with the program taken from src unless opts
+// gives one, and the data taken from data. It also returns the line
+// offset of the program within src, which is non-zero when a selection
+// is run.
+func runSource(opts runOptions, src, data editorReader) (runOptions, int) {
+	var offset int
+	if opts.src == "" {
+		opts.src = src.Text()
+	} else if len(src.TagRanges("sel")) != 0 {
+		// Locations in errors are relative to the selection.
+		offset, _ = position(src.Index("sel.first"))
+		offset--
+	}
+	opts.data = data.Text()
+	return opts, offset
+}
+
+// runSelection runs the text selected in the src pane as the program,
+// or the whole program if nothing is selected.
+func (m *miko) runSelection() {
+	m.run(runOptions{src: selection(m.src)})
+}
+
+// runOptions are the options for a single run.
+type runOptions struct {
+	src      string // Program to run.
+	data     string // Content of the data pane to use.
+	dataFile string // File holding data, used instead of a copy.
+	insecure bool   // Pass -insecure even if the toggle is off.
+	keep     bool   // Don't remove the run's temporary directory.
+}
+
+// runFlags returns the mito flags for the toolbar options.
+func (m *miko) runFlags(opts runOptions) []string {
+	var args []string
+	if m.config.Insecure || opts.insecure {
+		args = append(args, "-insecure")
+	}
+	if m.config.LogRequests || m.recording {
+		args = append(args, "-log_requests")
+	}
+	if mode := m.dumpMode(); mode != "none" {
+		args = append(args, "-dump", mode)
+	}
+	return args
 }
 
-func (m *miko) mito(keep bool) (*os.Process, error) {
-	src := m.src.Text()
+// errNoProgram is reported when Run is used with an empty src pane.
+var errNoProgram = errors.New("no program to run")
+
+// mito starts a run of mito on the current inputs as run generation
+// gen.
+func (m *miko) mito(gen uint64, opts runOptions) error {
+	src := opts.src
 	if src == "" {
-		return nil, nil
+		return errNoProgram
+	}
+	if _, err := directory(m.config.WorkDir); err != nil {
+		return err
+	}
+	// The run belongs to the current tab, which may no longer be
+	// current when its output arrives or it ends.
+	runs, doc := m.runs, m.tabs.currentID()
+	wf, captured, history := m.waterfall, m.captured, m.history
+	send := func(t text) {
+		t.doc = doc
+		m.send(t)
 	}
-	dir, err := os.MkdirTemp("", "miko-*")
+	dir, err := runs.tempDir()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	var cmd *execabs.Cmd
+	started := false
 	defer func() {
-		if cmd == nil {
-			os.RemoveAll(dir)
+		if !started {
+			runs.removeDir(dir)
 		}
 	}()
+	data, err := withParams(opts.data, m.params.Text())
+	if err != nil {
+		return err
+	}
 	var args []string
-	data := m.data.Text()
-	if data != "" {
-		dataPath := filepath.Join(dir, "data.json")
-		err = os.WriteFile(dataPath, []byte(data), 0o600)
+	if opts.dataFile != "" && data == opts.data {
+		// The data has no params added, so the file can be used
+		// as it is.
+		args = append(args, "-data", opts.dataFile)
+	} else if data != "" {
+		// Inputs are written to the cache, which only writes them
+		// when they have changed since an earlier run.
+		dataPath, err := m.cache.path("data.json", []byte(data))
 		if err != nil {
-			return nil, err
+			return err
 		}
 		args = append(args, "-data", dataPath)
 	}
 	config := m.cfg.Text()
-	if config != "" {
-		cfgPath := filepath.Join(dir, "cfg.yml")
-		err = os.WriteFile(cfgPath, []byte(config), 0o600)
+	var mock *mockServer
+	if m.config.Mock {
+		routes, err := parseMock(m.mock.Text())
 		if err != nil {
-			return nil, err
+			return err
+		}
+		mock, err = startMock(routes, func(s string) { send(text{data: s, tag: "note"}) })
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if !started {
+				mock.close()
+			}
+		}()
+		config, err = withMockURL(config, mock.url)
+		if err != nil {
+			return err
 		}
-		args = append(args, "-cfg", cfgPath)
 	}
-	if m.insecure {
-		args = append(args, "-insecure")
+	if n := m.config.MaxExecutions; n > 0 {
+		config, err = withMaxExecutions(config, n)
+		if err != nil {
+			return err
+		}
 	}
-	if m.logRequests {
-		args = append(args, "-log_requests")
+	if config != "" {
+		cfgPath, err := m.cache.path("cfg.yml", []byte(config))
+		if err != nil {
+			return err
+		}
+		args = append(args, "-cfg", cfgPath)
 	}
-	if m.dumpCrash {
-		args = append(args, "-dump", "error")
+	args = append(args, m.runFlags(opts)...)
+	// A profiled run's directory is kept so that the profile can be
+	// found after the run.
+	var profile string
+	if m.config.Profile {
+		profile = filepath.Join(dir, profileName)
+		args = append(args, profileFlag, profile)
 	}
-	srcPath := filepath.Join(dir, "src.cel")
-	err = os.WriteFile(srcPath, []byte(src), 0o600)
+	srcPath, err := m.cache.path("src.cel", []byte(src))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	args = append(args, srcPath)
-	cmd = execabs.Command("mito", args...)
+	cmd = execabs.Command(m.mitoPath, args...)
+	// The process's stdin is closed once the pane's content has been
+	// written, so that mito sees EOF.
+	if stdin := m.stdin.Text(); stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	cmd.Dir = dir
+	if m.config.WorkDir != "" {
+		cmd.Dir = m.config.WorkDir
+	}
+	if mock != nil {
+		cmd.Env = append(os.Environ(), "MIKO_"+strings.ToUpper(mockURLKey)+"="+mock.url)
+	}
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	// The output is collected for the post-run command.
+	var postRun []string
+	if len(m.config.PostRun) != 0 {
+		postRun = slices.Clone(m.config.PostRun)
+	}
+	workDir := m.config.WorkDir
+	// The responses logged by a run started while recording are
+	// recorded once it ends.
+	recording := m.recording
+	notifyAfter, notify := m.config.notifyAfter(), m.notifyMethod()
+	var output strings.Builder
+	hexBinary := m.config.HexBinary
+	nestedJSON := m.config.NestedJSON
+	rawOutput := m.config.RawOutput
+	accumulate := m.outputMode() == "accumulate"
+	// The output is copied to the tee file, if any, as it is read,
+	// before it is decoded.
+	tee, err := m.startTee()
+	if err != nil {
+		return err
+	}
+	var out io.Reader = stdout
+	hideOutput := false
+	if tee != nil {
+		out = io.TeeReader(stdout, tee)
+		hideOutput = m.tee.hide
+	}
+	// Output from stdout and stderr is sequenced so that it is
+	// displayed in the order it was written.
+	seq := newSequencer(send)
+	outStream := seq.stream(out)
+	errStream := seq.stream(stderr)
 	ctxStdout, cancelStdout := context.WithCancel(context.Background())
 	ctxStderr, cancelStderr := context.WithCancel(context.Background())
 	go func() {
 		defer cancelStdout()
-		dec := json.NewDecoder(stdout)
+		defer outStream.close()
+		if tee != nil {
+			defer tee.close()
+		}
+		if hideOutput {
+			io.Copy(io.Discard, outStream)
+			return
+		}
+		in := &resyncReader{r: outStream}
+		dec := json.NewDecoder(in)
+		var sawRaw bool
 		for {
-			var v any
-			err := dec.Decode(&v)
-			var pe *fs.PathError
+			var (
+				v   any
+				raw json.RawMessage
+				err error
+			)
+			if rawOutput {
+				err = dec.Decode(&raw)
+			} else {
+				err = dec.Decode(&v)
+			}
+			var (
+				pe *fs.PathError
+				se *json.SyntaxError
+			)
 			switch {
+			case err == nil && rawOutput:
+				// The value is shown as written, keeping its white
+				// space and field order.
+				outStream.send(text{data: string(raw), tag: "output"})
+				if postRun != nil {
+					output.Write(raw)
+					output.WriteByte('\n')
+				}
 			case err == nil:
+				if nestedJSON {
+					v = expandJSON(v, 0)
+				}
+				if hexBinary {
+					v = hexify(v)
+				}
 				b, err := json.MarshalIndent(v, "", "\t")
 				if err != nil {
 					log.Println(err)
 					return
 				}
-				m.results <- text{data: string(b), tag: "output"}
+				outStream.send(text{data: string(b), tag: "output"})
+				if postRun != nil {
+					output.Write(b)
+					output.WriteByte('\n')
+				}
 			case err == io.EOF, errors.As(err, &pe) && pe.Err == fs.ErrClosed:
 				return
+			case errors.As(err, &se), err == io.ErrUnexpectedEOF:
+				// Show the invalid input as raw text and continue
+				// decoding from the next line. Any read error will
+				// be seen again by the new decoder.
+				if !sawRaw {
+					sawRaw = true
+					outStream.send(text{data: fmt.Sprintf("invalid JSON on stdout: %v: showing raw output", err), tag: "error"})
+				}
+				buffered, _ := io.ReadAll(dec.Buffered())
+				line, _ := in.skipLine(buffered)
+				outStream.send(text{data: string(line), tag: "raw"})
+				if postRun != nil {
+					output.Write(line)
+					output.WriteByte('\n')
+				}
+				dec = json.NewDecoder(in)
 			default:
 				log.Println(err)
 				return
@@ -429,35 +1756,102 @@ func (m *miko) mito(keep bool) (*os.Process, error) {
 	}()
 	go func() {
 		defer cancelStderr()
-		sc := bufio.NewScanner(stderr)
-		for sc.Scan() {
-			m.results <- text{data: sc.Text(), tag: "error"}
-		}
-		err := sc.Err()
-		var pe *fs.PathError
-		switch {
-		case err == nil:
-		case err == io.EOF, errors.As(err, &pe) && pe.Err == fs.ErrClosed:
-			return
-		default:
+		defer errStream.close()
+		var kind errorKind
+		err := readLines(errStream, func(line string) {
+			// Structured log records are shown as collapsible
+			// blocks, distinct from other stderr output.
+			if fields, ok := parseLog(line); ok {
+				if r, ok := parseTiming(fields); ok {
+					wf.add(r)
+				}
+				if r, ok := parseRequest(fields); ok {
+					captured.add(r)
+				}
+				summary, body := formatLog(fields)
+				errStream.send(summary)
+				errStream.send(body)
+			} else {
+				errStream.send(text{data: line, tag: kind.tag(line)})
+			}
+		})
+		if err != nil {
 			log.Println(err)
-			return
 		}
 	}()
+	wf.reset()
+	captured.reset()
 	err = cmd.Start()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	started = true
+	runs.started(gen, cmd.Process, dir)
+	m.runStarted()
+	record := history.add(m.runInputs(src))
 	go func() {
 		<-ctxStdout.Done()
 		<-ctxStderr.Done()
-		cmd.Wait()
-		m.ps.Store(nil)
-		if !keep {
-			os.RemoveAll(dir)
+		m.flushDropped(doc)
+		err := cmd.Wait()
+		if mock != nil {
+			mock.close()
+		}
+		if accumulate {
+			status := "exit status 0"
+			if err != nil {
+				status = err.Error()
+			}
+			m.results <- text{data: status, tag: "note", doc: doc}
+		}
+		if profile != "" {
+			m.reportProfile(runs, doc, profile)
+		}
+		d, ended := runs.finished(gen, err, cmd.ProcessState)
+		if ended {
+			m.notifyLongRun(d, err, notifyAfter, notify)
+		}
+		status := "exit status 0"
+		switch {
+		case !ended:
+			status, d = "cancelled", time.Since(record.start)
+		case err != nil:
+			status = err.Error()
+		}
+		history.finish(record, d, status)
+		m.results <- text{tag: "runEnded", doc: doc, gen: gen, record: recording}
+		if err == nil && postRun != nil {
+			m.postRun(postRun, output.String(), workDir, doc)
+		}
+		if !opts.keep && profile == "" {
+			runs.removeDir(dir)
 		}
 	}()
-	return cmd.Process, nil
+	return nil
+}
+
+// readLines calls fn with each line read from r, without its line
+// ending, until r is exhausted or closed. Lines are read without a
+// length limit since a logged request body or stack trace may be very
+// long.
+func readLines(r io.Reader, fn func(line string)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			fn(line)
+		}
+		var pe *fs.PathError
+		switch {
+		case err == nil:
+		case err == io.EOF, errors.As(err, &pe) && pe.Err == fs.ErrClosed:
+			return nil
+		default:
+			return err
+		}
+	}
 }
 
 func (m *miko) celfmt() (string, error) {
@@ -465,7 +1859,7 @@ func (m *miko) celfmt() (string, error) {
 	if strings.TrimSpace(text) == "" {
 		return "", nil
 	}
-	cmd := execabs.Command("celfmt")
+	cmd := execabs.Command(m.celfmtPath)
 	cmd.Stdin = strings.NewReader(text)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -481,7 +1875,12 @@ func (m *miko) celfmt() (string, error) {
 }
 
 func (m *miko) jsonfmt() (string, error) {
-	text := m.data.Text()
+	return indentJSON(m.data.Text())
+}
+
+// indentJSON returns the JSON document text indented with tabs, or the
+// empty string if text is blank.
+func indentJSON(text string) (string, error) {
 	if strings.TrimSpace(text) == "" {
 		return "", nil
 	}
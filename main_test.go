@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadLinesLong checks that a very long stderr line, such as a
+// logged response body, arrives whole.
+func TestReadLinesLong(t *testing.T) {
+	long := strings.Repeat("0123456789abcdef", 500<<10/16)
+	r, w := io.Pipe()
+	go func() {
+		// The line is written in pieces, as mito's output may be.
+		for s := "first\n" + long + "\r\nlast"; s != ""; {
+			n := min(len(s), 4093)
+			io.WriteString(w, s[:n])
+			s = s[n:]
+		}
+		w.Close()
+	}()
+	var got []string
+	err := readLines(r, func(line string) { got = append(got, line) })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"first", long, "last"}
+	if !slices.Equal(got, want) {
+		lens := make([]int, len(got))
+		for i, l := range got {
+			lens[i] = len(l)
+		}
+		t.Errorf("unexpected lines: got %d lines of lengths %d, want lengths %d, %d and %d",
+			len(got), lens, len(want[0]), len(want[1]), len(want[2]))
+	}
+}
+
+// validFlags are flags that check accepts unchanged.
+var validFlags = flags{tw: 4, poll: 10 * time.Millisecond, faceSize: 10}
+
+var checkFlagsTests = []struct {
+	name         string
+	flags        func(*flags)
+	want         func(*flags)
+	wantProblems []string
+}{
+	{
+		name:  "valid",
+		flags: func(f *flags) { f.src, f.data, f.cfg = "src.cel", []string{"a.json", "b.json"}, "cfg.yaml" },
+		want:  func(f *flags) { f.src, f.data, f.cfg = "src.cel", []string{"a.json", "b.json"}, "cfg.yaml" },
+	},
+	{
+		name:  "valid_txtar",
+		flags: func(f *flags) { f.txtar = "-" },
+		want:  func(f *flags) { f.txtar = "-" },
+	},
+	{
+		name:         "arguments",
+		flags:        func(f *flags) { f.args = []string{"src.cel", "data.json"} },
+		want:         func(f *flags) { f.args = []string{"src.cel", "data.json"} },
+		wantProblems: []string{"ignoring unexpected arguments: src.cel data.json"},
+	},
+	{
+		name:         "zero_tw",
+		flags:        func(f *flags) { f.tw = 0 },
+		want:         func(f *flags) {},
+		wantProblems: []string{"-tw must be positive"},
+	},
+	{
+		name:         "negative_fr",
+		flags:        func(f *flags) { f.poll = -time.Second },
+		want:         func(f *flags) {},
+		wantProblems: []string{"-fr must be positive"},
+	},
+	{
+		name:         "zero_face_size",
+		flags:        func(f *flags) { f.faceSize = 0 },
+		want:         func(f *flags) {},
+		wantProblems: []string{"-face_size must be positive"},
+	},
+	{
+		name:  "several",
+		flags: func(f *flags) { f.tw, f.faceSize = 0, 0 },
+		want:  func(f *flags) {},
+		wantProblems: []string{
+			"-tw must be positive",
+			"-face_size must be positive",
+		},
+	},
+}
+
+var flagConflictTests = []struct {
+	name  string
+	flags flags
+	want  string
+}{
+	{name: "txtar", flags: flags{txtar: "a.txtar"}},
+	{name: "inputs", flags: flags{src: "src.cel", data: []string{"data.json"}, cfg: "cfg.yaml"}},
+	{name: "txtar_src", flags: flags{txtar: "a.txtar", src: "src.cel"}, want: "-txtar cannot be used with -src"},
+	{name: "txtar_data", flags: flags{txtar: "-", data: []string{"data.json"}}, want: "-txtar cannot be used with -data"},
+	{name: "txtar_cfg", flags: flags{txtar: "a.txtar", cfg: "cfg.yaml"}, want: "-txtar cannot be used with -cfg"},
+	{name: "txtar_src_cfg", flags: flags{txtar: "a.txtar", src: "src.cel", cfg: "cfg.yaml"}, want: "-txtar cannot be used with -src or -cfg"},
+	{
+		name:  "txtar_all",
+		flags: flags{txtar: "a.txtar", src: "src.cel", data: []string{"data.json"}, cfg: "cfg.yaml"},
+		want:  "-txtar cannot be used with -src, -data or -cfg",
+	},
+}
+
+func TestFlagConflict(t *testing.T) {
+	for _, test := range flagConflictTests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.flags.conflict()
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != test.want {
+				t.Errorf("unexpected conflict: got:%q want:%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	for _, test := range checkFlagsTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, want := validFlags, validFlags
+			test.flags(&got)
+			test.want(&want)
+			problems := got.check()
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected corrected flags:\ngot:  %+v\nwant: %+v", got, want)
+			}
+			if len(problems) != len(test.wantProblems) {
+				t.Fatalf("unexpected problems: got:%v want:%q", problems, test.wantProblems)
+			}
+			for i, p := range problems {
+				if !strings.HasPrefix(p.Error(), test.wantProblems[i]) {
+					t.Errorf("unexpected problem %d: got:%q want prefix:%q", i, p, test.wantProblems[i])
+				}
+			}
+		})
+	}
+}
+
+// TestDrainClosed checks that a closed results channel stops drain
+// rather than giving a stream of empty results.
+func TestDrainClosed(t *testing.T) {
+	m := &miko{results: make(chan text, 1)}
+	close(m.results)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.drain()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("drain did not return")
+	}
+	if !m.closed {
+		t.Fatal("closed channel not recorded")
+	}
+	// A later drain must not touch the display, which m does not have.
+	m.drain()
+}
+
+// TestCollectClosed checks that the results sent before the results
+// channel was closed are collected, and that the closed channel is
+// recorded without adding empty results.
+func TestCollectClosed(t *testing.T) {
+	m := &miko{
+		runs:    new(runner),
+		results: make(chan text, 4),
+		status:  &status{},
+		config:  &config{},
+		keys:    &keyFilter{},
+		tabs:    &tabs{docs: []*document{{id: 1}}},
+	}
+	m.results <- text{data: "{}", tag: "output"}
+	m.results <- text{data: "failed", tag: "error", doc: 1}
+	close(m.results)
+	b := m.collect()
+	if !m.closed {
+		t.Error("closed channel not recorded")
+	}
+	want := []string{"{}\n", "output", "failed\n", "error"}
+	if !slices.Equal(b.runs, want) {
+		t.Errorf("unexpected runs: got:%q want:%q", b.runs, want)
+	}
+	if m.status.events != 2 || m.status.errors != 1 {
+		t.Errorf("unexpected counts: got:%d events, %d errors want:2 events, 1 error", m.status.events, m.status.errors)
+	}
+	if b = m.collect(); len(b.runs) != 0 {
+		t.Errorf("unexpected runs after close: %q", b.runs)
+	}
+}
+
+// fakeEditor is an editor holding text, an insertion cursor and a
+// selection.
+type fakeEditor struct {
+	text   string
+	insert string
+	sel    []string
+}
+
+func (e *fakeEditor) Text() string { return e.text }
+
+func (e *fakeEditor) Index(index any) string {
+	switch index {
+	case "insert":
+		return e.insert
+	case "sel.first":
+		if len(e.sel) != 0 {
+			return e.sel[0]
+		}
+	}
+	panic(fmt.Sprintf("unexpected index: %v", index))
+}
+
+func (e *fakeEditor) TagRanges(tag string) []string {
+	if tag != "sel" {
+		panic(fmt.Sprintf("unexpected tag: %s", tag))
+	}
+	return slices.Clone(e.sel)
+}
+
+var runSourceTests = []struct {
+	name       string
+	opts       runOptions
+	src        fakeEditor
+	wantSrc    string
+	wantOffset int
+}{
+	{
+		name:    "whole",
+		src:     fakeEditor{text: "a\nb\nc", insert: "2.1"},
+		wantSrc: "a\nb\nc",
+	},
+	{
+		name:    "whole_with_selection",
+		src:     fakeEditor{text: "a\nb\nc", insert: "3.1", sel: []string{"2.0", "3.1"}},
+		wantSrc: "a\nb\nc",
+	},
+	{
+		name:       "selection",
+		opts:       runOptions{src: "b\nc"},
+		src:        fakeEditor{text: "a\nb\nc", insert: "3.1", sel: []string{"2.0", "3.1"}},
+		wantSrc:    "b\nc",
+		wantOffset: 1,
+	},
+	{
+		name:    "given",
+		opts:    runOptions{src: "x"},
+		src:     fakeEditor{text: "a\nb\nc", insert: "1.0"},
+		wantSrc: "x",
+	},
+}
+
+// TestRunSource checks that starting a run takes the program and data
+// from the editors and leaves their cursors and selections as they were.
+func TestRunSource(t *testing.T) {
+	for _, test := range runSourceTests {
+		t.Run(test.name, func(t *testing.T) {
+			src := test.src
+			src.sel = slices.Clone(test.src.sel)
+			data := fakeEditor{text: `{"a":1}`, insert: "1.3", sel: []string{"1.1", "1.3"}}
+			opts, offset := runSource(test.opts, &src, &data)
+			if opts.src != test.wantSrc {
+				t.Errorf("unexpected program: got:%q want:%q", opts.src, test.wantSrc)
+			}
+			if opts.data != data.text {
+				t.Errorf("unexpected data: got:%q want:%q", opts.data, data.text)
+			}
+			if offset != test.wantOffset {
+				t.Errorf("unexpected offset: got:%d want:%d", offset, test.wantOffset)
+			}
+			if src.insert != test.src.insert || !slices.Equal(src.sel, test.src.sel) {
+				t.Errorf("src cursor changed: got:%s %q want:%s %q", src.insert, src.sel, test.src.insert, test.src.sel)
+			}
+			if data.insert != "1.3" || !slices.Equal(data.sel, []string{"1.1", "1.3"}) {
+				t.Errorf("data cursor changed: got:%s %q", data.insert, data.sel)
+			}
+		})
+	}
+}
+
+var collectRunEndedTests = []struct {
+	name       string
+	ended      text
+	wantEnded  bool
+	wantRecord bool
+}{
+	{name: "current", ended: text{tag: "runEnded", gen: 2}, wantEnded: true},
+	{name: "current_recording", ended: text{tag: "runEnded", gen: 2, record: true}, wantEnded: true, wantRecord: true},
+	{name: "superseded", ended: text{tag: "runEnded", gen: 1, record: true}},
+}
+
+// TestCollectRunEnded checks that the end of a run is reported however
+// quickly the run ended, unless the run has been superseded, and that
+// its end is not counted as a result.
+func TestCollectRunEnded(t *testing.T) {
+	for _, test := range collectRunEndedTests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &miko{
+				runs:    new(runner),
+				results: make(chan text, 4),
+				status:  &status{},
+				config:  &config{},
+				keys:    &keyFilter{},
+				tabs:    &tabs{docs: []*document{{id: 1}}},
+			}
+			m.runs.begin()
+			m.runs.begin()
+			m.results <- text{data: "{}", tag: "output", doc: 1}
+			m.results <- test.ended
+			b := m.collect()
+			if b.ended != test.wantEnded || b.record != test.wantRecord {
+				t.Errorf("unexpected end: got:ended=%t record=%t want:ended=%t record=%t",
+					b.ended, b.record, test.wantEnded, test.wantRecord)
+			}
+			if m.status.events != 1 {
+				t.Errorf("unexpected event count: got:%d want:1", m.status.events)
+			}
+		})
+	}
+}
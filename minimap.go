@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+const (
+	minimapWidth      = 80                     // Width of the minimap in pixels.
+	minimapLineHeight = 2                      // Maximum height of a line in pixels.
+	minimapRate       = 250 * time.Millisecond // Minimum time between redraws.
+)
+
+// minimap is a scaled-down overview of a text widget's content that
+// can be clicked to scroll the text.
+type minimap struct {
+	text   *TextWidget
+	canvas *CanvasWidget
+	tw     int // Width of a tab in characters.
+
+	shown bool
+	dirty bool
+	drawn time.Time
+	view  string  // Last seen yview of text.
+	scale float64 // Height of a line in pixels for the last draw.
+}
+
+func newMinimap(parent *Window, text *TextWidget, tw int) *minimap {
+	mm := &minimap{
+		text: text,
+		tw:   tw,
+		canvas: parent.Canvas(
+			Width(minimapWidth),
+			Background(White),
+			Highlightthickness(0),
+		),
+		dirty: true,
+	}
+	jump := Command(func(e *Event) { mm.jump(e.Y) })
+	Bind(mm.canvas, "<Button-1>", jump)
+	Bind(mm.canvas, "<B1-Motion>", jump)
+	Bind(mm.canvas, "<Configure>", Command(func() { mm.dirty = true }))
+	return mm
+}
+
+// update redraws the minimap if the text has changed since the last
+// draw, and updates the view indicator if the text has been scrolled.
+// It is intended to be called from the UI ticker.
+func (mm *minimap) update() {
+	if !mm.shown {
+		return
+	}
+	if mm.dirty && time.Since(mm.drawn) >= minimapRate {
+		mm.draw()
+		mm.dirty = false
+		mm.drawn = time.Now()
+		mm.view = ""
+	}
+	view := mm.text.Yview()
+	if view == mm.view {
+		return
+	}
+	mm.view = view
+	first, last, ok := parseView(view)
+	if !ok {
+		return
+	}
+	lines := mm.lines()
+	mm.canvas.Delete("view")
+	mm.canvas.CreateRectangle(
+		0, first*float64(lines)*mm.scale,
+		minimapWidth-1, last*float64(lines)*mm.scale,
+		Outline("gray50"), Tags("view"),
+	)
+}
+
+// draw renders a line for each line of the text, coloured according to
+// the foreground colour of any tags applied to the start of the line's
+// content, so that syntax highlighting is reflected in the minimap.
+func (mm *minimap) draw() {
+	mm.canvas.Delete("all")
+	content := strings.Split(mm.text.Text(), "\n")
+	height, _ := strconv.Atoi(WinfoHeight(mm.canvas.Window))
+	mm.scale = minimapLineHeight
+	if len(content) > 0 && height > 0 && float64(len(content))*mm.scale > float64(height) {
+		mm.scale = float64(height) / float64(len(content))
+	}
+	colors := make(map[string]string)
+	for i, line := range content {
+		start := len(line) - len(strings.TrimLeft(line, " \t"))
+		if start == len(line) {
+			continue
+		}
+		indent := strings.Count(line[:start], "\t")*mm.tw + strings.Count(line[:start], " ")
+		end := indent + len(line) - start
+		y := float64(i) * mm.scale
+		mm.canvas.CreateLine(
+			min(indent, minimapWidth), y, min(end, minimapWidth), y,
+			Fill(mm.color(fmt.Sprintf("%d.%d", i+1, start), colors)),
+			Width(max(1, mm.scale/2)),
+		)
+	}
+}
+
+// color returns the foreground colour of the highest priority tag at
+// index that has one, or gray if there is none. Tag colours are cached
+// in colors for the duration of a draw.
+func (mm *minimap) color(index string, colors map[string]string) string {
+	tags := mm.text.TagNames(index)
+	for i := len(tags) - 1; i >= 0; i-- {
+		tag := tags[i]
+		c, ok := colors[tag]
+		if !ok {
+			c = EvalErr(fmt.Sprintf("%s tag cget %s -foreground", mm.text, tag))
+			colors[tag] = c
+		}
+		if c != "" {
+			return c
+		}
+	}
+	return "gray60"
+}
+
+// jump scrolls the text so that the line at y in the minimap is centred.
+func (mm *minimap) jump(y int) {
+	lines := mm.lines()
+	if lines == 0 || mm.scale == 0 {
+		return
+	}
+	first, last, ok := parseView(mm.text.Yview())
+	if !ok {
+		return
+	}
+	frac := float64(y)/(float64(lines)*mm.scale) - (last-first)/2
+	mm.text.Yviewmoveto(max(0, frac))
+}
+
+func (mm *minimap) lines() int {
+	n, _ := strconv.Atoi(strings.Split(mm.text.Index("end-1c"), ".")[0])
+	return n
+}
+
+// parseView parses the fractions returned by a yview query.
+func parseView(view string) (first, last float64, ok bool) {
+	f := strings.Fields(view)
+	if len(f) != 2 {
+		return 0, 0, false
+	}
+	first, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	last, err = strconv.ParseFloat(f[1], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return first, last, true
+}
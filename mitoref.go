@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/execabs"
+	. "modernc.org/tk9.0"
+)
+
+// mitoDocs is the documentation of mito's CEL extensions. Each
+// extension is described under the function that provides it.
+const mitoDocs = "https://pkg.go.dev/github.com/elastic/mito/lib"
+
+// mitoFunc describes a function or method added to CEL by one of mito's
+// extensions.
+type mitoFunc struct {
+	name string
+	lib  string // The lib function providing the extension.
+	sig  string // The signature, with the receiver for methods.
+	doc  string // A one line description.
+}
+
+// url returns the documentation page of the extension providing f.
+func (f mitoFunc) url() string {
+	return mitoDocs + "#" + f.lib
+}
+
+// mitoRef is the reference data for mito's functions. Functions with
+// several overloads have an entry for each.
+var mitoRef = []mitoFunc{
+	{"collate", "Collections", "<list<map>>.collate(<string>) -> <list>", "Return the values at a dotted path in each element of a list."},
+	{"drop", "Collections", "<map>.drop(<string>) -> <map>", "Remove the value at a dotted path from a map."},
+	{"drop_empty", "Collections", "<map>.drop_empty() -> <map>", "Remove empty values from a map recursively."},
+	{"flatten", "Collections", "<list>.flatten() -> <list>", "Flatten nested lists into a single list."},
+	{"front", "Collections", "<list>.front(<int>) -> <list>", "Return the first n elements of a list."},
+	{"keys", "Collections", "<map>.keys() -> <list>", "Return the keys of a map."},
+	{"max", "Collections", "<list>.max() -> <dyn>", "Return the largest element of a list."},
+	{"min", "Collections", "<list>.min() -> <dyn>", "Return the smallest element of a list."},
+	{"sum", "Collections", "<list>.sum() -> <dyn>", "Return the sum of the elements of a list."},
+	{"tail", "Collections", "<list>.tail() -> <list>", "Return all but the first element of a list."},
+	{"values", "Collections", "<map>.values() -> <list>", "Return the values of a map."},
+	{"with", "Collections", "<map>.with(<map>) -> <map>", "Merge a map into another, replacing existing values."},
+	{"with_replace", "Collections", "<map>.with_replace(<map>) -> <map>", "Merge a map into another, only replacing existing keys."},
+	{"with_update", "Collections", "<map>.with_update(<map>) -> <map>", "Merge a map into another, only adding missing keys."},
+	{"zip", "Collections", "<list>.zip(<list>) -> <map>", "Make a map from a list of keys and a list of values."},
+	{"base64", "Crypto", "<bytes>.base64() -> <string>", "Encode bytes or a string as standard base64."},
+	{"base64_decode", "Crypto", "<string>.base64_decode() -> <bytes>", "Decode standard base64."},
+	{"base64_raw", "Crypto", "<bytes>.base64_raw() -> <string>", "Encode bytes or a string as unpadded base64."},
+	{"base64_raw_decode", "Crypto", "<string>.base64_raw_decode() -> <bytes>", "Decode unpadded base64."},
+	{"hex", "Crypto", "<bytes>.hex() -> <string>", "Encode bytes or a string as hexadecimal."},
+	{"hmac", "Crypto", "<bytes>.hmac(<string>, <bytes>) -> <bytes>", "Compute an HMAC with the named hash and a key."},
+	{"sha1", "Crypto", "<bytes>.sha1() -> <bytes>", "Compute the SHA-1 hash."},
+	{"sha256", "Crypto", "<bytes>.sha256() -> <bytes>", "Compute the SHA-256 hash."},
+	{"uuid", "Crypto", "uuid() -> <string>", "Return a random version 4 UUID."},
+	{"debug", "Debug", "debug(<string>, <dyn>) -> <dyn>", "Log a tagged value and return it unchanged."},
+	{"dir", "File", "dir(<string>) -> <list<map>>", "List the contents of a directory."},
+	{"file", "File", "file(<string>, <string>) -> <dyn>", "Read a file with the given MIME type."},
+	{"basic_authentication", "HTTP", "<map>.basic_authentication(<string>, <string>) -> <map>", "Add basic authentication to a request."},
+	{"do_request", "HTTP", "<map>.do_request() -> <map>", "Send a request made with request and return the response."},
+	{"format_query", "HTTP", "<map>.format_query() -> <string>", "Format a map of query parameters."},
+	{"format_url", "HTTP", "<map>.format_url() -> <string>", "Format a parsed URL."},
+	{"get", "HTTP", "get(<string>) -> <map>", "Send a GET request and return the response."},
+	{"head", "HTTP", "head(<string>) -> <map>", "Send a HEAD request and return the response."},
+	{"parse_query", "HTTP", "<string>.parse_query() -> <map>", "Parse URL query parameters."},
+	{"parse_url", "HTTP", "<string>.parse_url() -> <map>", "Parse a URL into its parts."},
+	{"post", "HTTP", "post(<string>, <string>, <bytes>) -> <map>", "Send a POST request with a content type and body."},
+	{"request", "HTTP", "request(<string>, <string>) -> <map>", "Make a request with a method and URL."},
+	{"request", "HTTP", "request(<string>, <string>, <bytes>) -> <map>", "Make a request with a method, URL and body."},
+	{"decode_json", "JSON", "<bytes>.decode_json() -> <dyn>", "Decode a JSON value."},
+	{"decode_json_stream", "JSON", "<bytes>.decode_json_stream() -> <list>", "Decode a stream of JSON values."},
+	{"encode_json", "JSON", "<dyn>.encode_json() -> <string>", "Encode a value as JSON."},
+	{"rate_limit", "Limit", "rate_limit(<map>, <string>, <duration>) -> <map>", "Derive a rate limit from response headers."},
+	{"mime", "MIME", "<bytes>.mime(<string>) -> <dyn>", "Decode bytes with a registered MIME type."},
+	{"sprintf", "Printf", "<string>.sprintf(<list>) -> <string>", "Format a list of values with a Go format string."},
+	{"re_find", "Regexp", "<string>.re_find(<string>) -> <string>", "Return the first match of a named regular expression."},
+	{"re_find_all", "Regexp", "<string>.re_find_all(<string>) -> <list>", "Return all matches of a named regular expression."},
+	{"re_find_all_submatch", "Regexp", "<string>.re_find_all_submatch(<string>) -> <list>", "Return the submatches of all matches."},
+	{"re_find_submatch", "Regexp", "<string>.re_find_submatch(<string>) -> <list>", "Return the submatches of the first match."},
+	{"re_match", "Regexp", "<string>.re_match(<string>) -> <bool>", "Report whether a named regular expression matches."},
+	{"re_replace_all", "Regexp", "<string>.re_replace_all(<string>, <string>) -> <string>", "Replace all matches of a named regular expression."},
+	{"compare", "Strings", "<string>.compare(<string>) -> <int>", "Compare two strings lexically."},
+	{"contains_substr", "Strings", "<string>.contains_substr(<string>) -> <bool>", "Report whether a substring is present."},
+	{"equal_fold", "Strings", "<string>.equal_fold(<string>) -> <bool>", "Compare strings ignoring case."},
+	{"fields", "Strings", "<string>.fields() -> <list<string>>", "Split a string around white space."},
+	{"has_prefix", "Strings", "<string>.has_prefix(<string>) -> <bool>", "Report whether a string begins with a prefix."},
+	{"has_suffix", "Strings", "<string>.has_suffix(<string>) -> <bool>", "Report whether a string ends with a suffix."},
+	{"index", "Strings", "<string>.index(<string>) -> <int>", "Return the index of the first instance of a substring."},
+	{"join", "Strings", "<list<string>>.join(<string>) -> <string>", "Join a list of strings with a separator."},
+	{"last_index", "Strings", "<string>.last_index(<string>) -> <int>", "Return the index of the last instance of a substring."},
+	{"quote", "Strings", "<string>.quote() -> <string>", "Quote a string with Go escapes."},
+	{"repeat", "Strings", "<string>.repeat(<int>) -> <string>", "Repeat a string a number of times."},
+	{"replace_all", "Strings", "<string>.replace_all(<string>, <string>) -> <string>", "Replace all instances of a substring."},
+	{"split", "Strings", "<string>.split(<string>) -> <list<string>>", "Split a string around a separator."},
+	{"substring", "Strings", "<string>.substring(<int>, <int>) -> <string>", "Return the part of a string between two indexes."},
+	{"to_lower", "Strings", "<string>.to_lower() -> <string>", "Convert a string to lower case."},
+	{"to_upper", "Strings", "<string>.to_upper() -> <string>", "Convert a string to upper case."},
+	{"to_valid_utf8", "Strings", "<string>.to_valid_utf8(<string>) -> <string>", "Replace invalid UTF-8 with a string."},
+	{"trim", "Strings", "<string>.trim(<string>) -> <string>", "Remove leading and trailing characters in a cutset."},
+	{"trim_prefix", "Strings", "<string>.trim_prefix(<string>) -> <string>", "Remove a leading prefix."},
+	{"trim_space", "Strings", "<string>.trim_space() -> <string>", "Remove leading and trailing white space."},
+	{"trim_suffix", "Strings", "<string>.trim_suffix(<string>) -> <string>", "Remove a trailing suffix."},
+	{"unquote", "Strings", "<string>.unquote() -> <string>", "Interpret a Go quoted string."},
+	{"valid_utf8", "Strings", "<string>.valid_utf8() -> <bool>", "Report whether a string is valid UTF-8."},
+	{"format", "Time", "<timestamp>.format(<string>) -> <string>", "Format a time with a Go layout or a named layout."},
+	{"parse_time", "Time", "<string>.parse_time(<string>) -> <timestamp>", "Parse a time with a Go layout or a named layout."},
+	{"is_error", "Try", "is_error(<dyn>) -> <bool>", "Report whether a value from try is an error."},
+	{"try", "Try", "try(<dyn>) -> <dyn>", "Return a value, or its error as a string instead of failing."},
+	{"try", "Try", "try(<dyn>, <string>) -> <dyn>", "Return a value, or its error in a map with the given key."},
+	{"decode_xml", "XML", "<bytes>.decode_xml() -> <dyn>", "Decode an XML document."},
+	{"decode_xml", "XML", "<bytes>.decode_xml(<string>) -> <dyn>", "Decode an XML document using a named XSD."},
+}
+
+// lookupFunc returns the entries of mitoRef for the function name.
+func lookupFunc(name string) []mitoFunc {
+	var funcs []mitoFunc
+	for _, f := range mitoRef {
+		if f.name == name {
+			funcs = append(funcs, f)
+		}
+	}
+	return funcs
+}
+
+// describeFuncs returns the signatures and descriptions of funcs, one
+// per line.
+func describeFuncs(funcs []mitoFunc) string {
+	var lines []string
+	for _, f := range funcs {
+		lines = append(lines, f.sig+"\n    "+f.doc)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// identAt returns the identifier in w at or just before index, or the
+// empty string if there is none.
+func identAt(w *TextWidget, index string) string {
+	for _, i := range []string{index, index + "-1c"} {
+		word := strings.Join(w.Get(i+" wordstart", i+" wordend"), "")
+		if isIdent(word) {
+			return word
+		}
+	}
+	return ""
+}
+
+// isIdent reports whether s is a CEL identifier.
+func isIdent(s string) bool {
+	if s == "" || ('0' <= s[0] && s[0] <= '9') {
+		return false
+	}
+	for _, c := range s {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// openURL opens u in the user's browser.
+func openURL(u string) error {
+	var cmd *execabs.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = execabs.Command("open", u)
+	case "windows":
+		cmd = execabs.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = execabs.Command("xdg-open", u)
+	}
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// lookupDocs shows the description of the mito function under the
+// insertion cursor in the src pane, with a button to open its
+// documentation. It reports whether the cursor was on a known function.
+func (m *miko) lookupDocs() bool {
+	name := identAt(m.src, "insert")
+	funcs := lookupFunc(name)
+	if len(funcs) == 0 {
+		return false
+	}
+	top := App.Toplevel()
+	top.WmTitle(name)
+	WmTransient(top, App)
+	Grid(top.TLabel(Txt(describeFuncs(funcs)), Justify("left"), Font(m.editorFont.face)),
+		Row(0), Column(0), Sticky("w"), Padx("8"), Pady("8"))
+	buttons := top.TFrame()
+	Grid(buttons, Row(1), Column(0), Sticky("e"), Padx("8"), Pady("8"))
+	open := buttons.TButton(Txt("Open Documentation"), Command(func() {
+		Destroy(top)
+		err := openURL(funcs[0].url())
+		if err != nil {
+			m.printError(fmt.Errorf("opening documentation: %w", err))
+		}
+	}))
+	Grid(open, buttons.TButton(Txt("Close"), Command(func() { Destroy(top) })), Row(0), Padx("2"))
+	m.dialog(top)
+	Focus(open)
+	return true
+}
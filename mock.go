@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mockURLKey is the name of the cfg global and, upper-cased with a MIKO_
+// prefix, the environment variable holding the mock server's base URL.
+const mockURLKey = "mock_url"
+
+// mockRoutes is the content of the mock pane, a YAML document listing
+// the responses of the mock server, for example
+//
+//	routes:
+//	  - method: GET
+//	    path: /items
+//	    query: page=2
+//	    status: 200
+//	    header:
+//	      Content-Type: application/json
+//	    body: '{"items": []}'
+type mockRoutes struct {
+	Routes []mockRoute `yaml:"routes"`
+}
+
+// mockRoute is a response of the mock server and the requests it
+// answers. An empty method matches any method, and the query, if
+// given, must hold each of its parameters with the same values.
+type mockRoute struct {
+	Method string            `yaml:"method,omitempty"`
+	Path   string            `yaml:"path"`
+	Query  string            `yaml:"query,omitempty"`
+	Status int               `yaml:"status,omitempty"`
+	Header map[string]string `yaml:"header,omitempty"`
+	Body   string            `yaml:"body,omitempty"`
+}
+
+// parseMock parses the content of the mock pane.
+func parseMock(text string) ([]mockRoute, error) {
+	var r mockRoutes
+	err := yaml.Unmarshal([]byte(text), &r)
+	if err != nil {
+		return nil, fmt.Errorf("mock: %w", err)
+	}
+	for i, rt := range r.Routes {
+		if !strings.HasPrefix(rt.Path, "/") {
+			return nil, fmt.Errorf("mock: route %d: path must start with /", i+1)
+		}
+		if _, err := url.ParseQuery(rt.Query); err != nil {
+			return nil, fmt.Errorf("mock: route %d: %w", i+1, err)
+		}
+	}
+	return r.Routes, nil
+}
+
+// matches reports whether the route answers r.
+func (rt mockRoute) matches(r *http.Request) bool {
+	if rt.Method != "" && !strings.EqualFold(rt.Method, r.Method) || rt.Path != r.URL.Path {
+		return false
+	}
+	want, _ := url.ParseQuery(rt.Query)
+	got := r.URL.Query()
+	for k, v := range want {
+		if strings.Join(got[k], "\x00") != strings.Join(v, "\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+// mockServer is the local HTTP server answering a run's requests from
+// the routes in the mock pane.
+type mockServer struct {
+	srv  *http.Server
+	url  string
+	done sync.WaitGroup
+}
+
+// startMock starts a mock server on a loopback port serving routes.
+// Each request is reported with note, which must be safe to call from
+// the server's goroutines.
+func startMock(routes []mockRoute, note func(string)) (*mockServer, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("mock: %w", err)
+	}
+	s := &mockServer{url: "http://" + l.Addr().String()}
+	s.srv = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rt := range routes {
+				if !rt.matches(r) {
+					continue
+				}
+				for k, v := range rt.Header {
+					w.Header().Set(k, v)
+				}
+				status := rt.Status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				w.WriteHeader(status)
+				fmt.Fprint(w, rt.Body)
+				note(fmt.Sprintf("mock: %s %s: %d", r.Method, r.URL.RequestURI(), status))
+				return
+			}
+			http.Error(w, "no mock route for "+r.Method+" "+r.URL.RequestURI(), http.StatusNotFound)
+			note(fmt.Sprintf("mock: %s %s: no matching route", r.Method, r.URL.RequestURI()))
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		err := s.srv.Serve(l)
+		if !errors.Is(err, http.ErrServerClosed) {
+			note(fmt.Sprintf("mock: %v", err))
+		}
+	}()
+	return s, nil
+}
+
+// close shuts the server down, ending any requests in progress.
+func (s *mockServer) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if s.srv.Shutdown(ctx) != nil {
+		s.srv.Close()
+	}
+	s.done.Wait()
+}
+
+// withMockURL returns the cfg document cfg with the mock server's base
+// URL u added to its globals.
+func withMockURL(cfg, u string) (string, error) {
+	cfg, err := editCfg(cfg, func(doc map[string]any) error {
+		globals, ok := doc["globals"].(map[string]any)
+		if !ok {
+			if doc["globals"] != nil {
+				return errors.New("cfg globals must be a mapping")
+			}
+			globals = make(map[string]any)
+			doc["globals"] = globals
+		}
+		globals[mockURLKey] = u
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("adding mock URL: %w", err)
+	}
+	return cfg, nil
+}
+
+// toggleMock shows the mock pane when the mock server is turned on and
+// hides it when it is turned off.
+func (m *miko) toggleMock() {
+	for _, p := range m.panes {
+		if p.text == m.mock && p.shown != m.config.Mock {
+			p.shown = m.config.Mock
+			m.layoutPanes()
+			m.sync()
+		}
+	}
+	m.saveConfig()
+}
+
+// recordRoutes returns mock routes answering reqs with their logged
+// responses. Only the first response to each request is kept, and the
+// values of fields of JSON bodies with names that are redacted in
+// headers are replaced.
+func recordRoutes(reqs []capturedRequest) []mockRoute {
+	var routes []mockRoute
+	seen := make(map[string]bool)
+	for _, r := range reqs {
+		u, err := url.Parse(r.url)
+		if err != nil {
+			continue
+		}
+		key := r.method + " " + u.Path + "?" + u.RawQuery
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rt := mockRoute{
+			Method: r.method,
+			Path:   u.Path,
+			Query:  u.RawQuery,
+			Body:   redactBody(r.response),
+		}
+		if rt.Path == "" {
+			rt.Path = "/"
+		}
+		if f := strings.Fields(r.status); len(f) != 0 {
+			rt.Status, _ = strconv.Atoi(f[0])
+		}
+		if json.Valid([]byte(r.response)) {
+			rt.Header = map[string]string{"Content-Type": "application/json"}
+		}
+		routes = append(routes, rt)
+	}
+	return routes
+}
+
+// redactBody returns body with the values of redacted fields hidden if
+// it is JSON, and unchanged otherwise.
+func redactBody(body string) string {
+	var v any
+	if json.Unmarshal([]byte(body), &v) != nil {
+		return body
+	}
+	var redact func(v any) any
+	redact = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, e := range v {
+				if redacted(k) {
+					v[k] = "[redacted]"
+				} else {
+					v[k] = redact(e)
+				}
+			}
+		case []any:
+			for i, e := range v {
+				v[i] = redact(e)
+			}
+		}
+		return v
+	}
+	b, err := json.Marshal(redact(v))
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+// recordResponses replaces the content of the mock pane with routes
+// answering the requests logged by the latest run, so that the run can
+// be repeated offline with the mock server. The replacement can be
+// undone.
+func (m *miko) recordResponses() {
+	reqs := m.captured.requests()
+	if len(reqs) == 0 {
+		m.announce("No requests were recorded")
+		return
+	}
+	b, err := yaml.Marshal(mockRoutes{Routes: recordRoutes(reqs)})
+	if err != nil {
+		m.printError(err)
+		return
+	}
+	header := fmt.Sprintf("# Recorded from %s at %s.\n# Use the mock server and point the program at mock_url to replay.\n",
+		originOf(reqs[0].url), time.Now().Format(time.DateTime))
+	for _, p := range m.panes {
+		if p.text == m.mock && !p.shown {
+			p.shown = true
+			m.layoutPanes()
+			m.sync()
+		}
+	}
+	setText(m.mock, header+string(b))
+	m.announce(fmt.Sprintf("Recorded %d requests to the mock pane", len(reqs)))
+}
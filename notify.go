@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/execabs"
+	. "modernc.org/tk9.0"
+)
+
+// defaultNotifyAfter is how long a run must take for its end to be
+// notified unless configured otherwise.
+const defaultNotifyAfter = 30 * time.Second
+
+// notifyMethods are the ways the end of a long run can be notified.
+var notifyMethods = []string{"desktop", "bell"}
+
+// notifyAfter returns the configured notification threshold. A
+// negative threshold disables notifications.
+func (c *config) notifyAfter() time.Duration {
+	if c.NotifyAfter == 0 {
+		return defaultNotifyAfter
+	}
+	return c.NotifyAfter
+}
+
+// notifyLongRun notifies the end of a run that took d, if it took at
+// least threshold, with the result err of waiting for it using method.
+// It is called from the goroutine waiting for the run; the bell is rung
+// from the ticker.
+func (m *miko) notifyLongRun(d time.Duration, err error, threshold time.Duration, method string) {
+	if threshold < 0 || d < threshold {
+		return
+	}
+	status := "exit status 0"
+	if err != nil {
+		status = err.Error()
+	}
+	msg := fmt.Sprintf("Run finished with %s after %s", status, elapsed(d))
+	if method == "bell" || desktopNotify("miko", msg) != nil {
+		m.bell.Store(true)
+	}
+}
+
+// desktopNotify shows a desktop notification with the system's
+// notifier.
+func desktopNotify(title, msg string) error {
+	var cmd *execabs.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = execabs.Command("osascript", "-e",
+			fmt.Sprintf("display notification %s with title %s", strconv.Quote(msg), strconv.Quote(title)))
+	case "windows":
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	default:
+		cmd = execabs.Command("notify-send", "--app-name=miko", title, msg)
+	}
+	return cmd.Run()
+}
+
+// ringBell rings the bell if a notification has asked for it.
+func (m *miko) ringBell() {
+	if m.bell.Swap(false) {
+		Bell()
+	}
+}
+
+// notifyMethod returns the configured notification method, defaulting
+// to desktop.
+func (m *miko) notifyMethod() string {
+	if slices.Contains(notifyMethods, m.config.Notify) {
+		return m.config.Notify
+	}
+	return "desktop"
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// outputModes are the ways the output display is prepared for a new run.
+// With "clear" the previous output is discarded, with "snapshot" it is
+// kept in a collapsed section above the new output, and with
+// "accumulate" each run is appended below a header.
+var outputModes = []string{"clear", "snapshot", "accumulate"}
+
+// defaultMaxOutputLines is the default limit on the number of lines
+// kept in the output display.
+const defaultMaxOutputLines = 10000
+
+// outputMode returns the configured output mode, defaulting to clear.
+func (m *miko) outputMode() string {
+	if slices.Contains(outputModes, m.config.RunOutput) {
+		return m.config.RunOutput
+	}
+	return "clear"
+}
+
+// configureSnapshot sets up the tags used to show the previous run's
+// output. Clicking the header shows or hides the output.
+func (m *miko) configureSnapshot() {
+	m.display.TagConfigure("snapshot", Elide(true))
+	m.display.TagConfigure("snapshotHeader", Underline(true))
+	var shown bool
+	m.display.TagBind("snapshotHeader", "<Button-1>", func() {
+		shown = !shown
+		m.display.TagConfigure("snapshot", Elide(!shown))
+	})
+}
+
+// lockDisplay makes the output display read-only after a change,
+// unless the user has unlocked it for editing.
+func (m *miko) lockDisplay() {
+	if !m.unlocked {
+		m.display.Configure(State("disabled"))
+	}
+}
+
+// toggleUnlocked makes the output display editable, or read-only again.
+func (m *miko) toggleUnlocked() {
+	if m.unlocked {
+		m.display.Configure(State("normal"))
+		m.announce("Output unlocked for editing")
+	} else {
+		m.lockDisplay()
+		m.announce("Output locked")
+	}
+}
+
+// clearOutput discards the content of the output display.
+func (m *miko) clearOutput() {
+	m.repeats = repeats{}
+	m.unpin()
+	m.display.Configure(State("normal"))
+	m.display.Delete("1.0", "end")
+	m.lockDisplay()
+	m.clearEvents()
+}
+
+// prepareOutput prepares the output display for a new run according to
+// the output mode, locking it if it was unlocked for editing.
+func (m *miko) prepareOutput() {
+	// The display is locked again so that edits do not fight the
+	// run's output.
+	if m.unlocked {
+		m.unlocked = false
+		m.toggleUnlocked()
+		m.sync()
+	}
+	m.repeats = repeats{}
+	m.rememberTrees()
+	switch m.outputMode() {
+	case "clear":
+		m.clearOutput()
+	case "snapshot":
+		m.snapshotOutput()
+	case "accumulate":
+		m.runHeader()
+	}
+}
+
+// runHeader appends a separator and header for a new run to the output
+// display.
+func (m *miko) runHeader() {
+	d := m.display
+	d.Configure(State("normal"))
+	defer m.lockDisplay()
+	m.runCount++
+	header := fmt.Sprintf("── run %d at %s ──\n", m.runCount, time.Now().Format(time.TimeOnly))
+	if d.Index("end-1c") != "1.0" {
+		header = "\n" + header
+	}
+	d.Insert("end", header, "runHeader")
+	m.follow()
+}
+
+// trimOutput removes the oldest lines from the output display when it
+// holds more than the configured maximum. It must be called with the
+// display in the normal state.
+func (m *miko) trimOutput() {
+	limit := m.config.MaxOutputLines
+	if limit <= 0 {
+		limit = defaultMaxOutputLines
+	}
+	lines, _ := position(m.display.Index("end-1c"))
+	switch {
+	case lines <= limit:
+	case m.pinned:
+		m.trimPinned(lines - limit)
+	default:
+		m.display.Delete("1.0", fmt.Sprintf("%d.0", lines-limit+1))
+	}
+	m.pruneEvents()
+}
+
+// snapshotOutput collapses the current output into a section headed by
+// a line that expands it when clicked. Any older snapshot is discarded.
+func (m *miko) snapshotOutput() {
+	m.unpin()
+	d := m.display
+	d.Configure(State("normal"))
+	defer m.lockDisplay()
+	if r := d.TagRanges("snapshot"); len(r) != 0 {
+		d.Delete("1.0", r[len(r)-1])
+		m.pruneEvents()
+	}
+	if d.Index("end-1c") == "1.0" {
+		d.Delete("1.0", "end")
+		return
+	}
+	d.TagAdd("snapshot", "1.0", "end-1c")
+	d.Insert("1.0", "Previous output\n", "snapshotHeader")
+	d.TagConfigure("snapshot", Elide(true))
+}
+
+// repeats tracks the most recent result in the display so that
+// consecutive identical results can be collapsed.
+type repeats struct {
+	last  text
+	count int  // Number of consecutive results equal to last.
+	dirty bool // The count shown in the display needs updating.
+}
+
+// repeat returns the tag to display t with. If repeated results are
+// collapsed and t repeats the previous result, t is hidden and counted,
+// and a marker is inserted before the first repeat.
+func (m *miko) repeat(t text) (tag string, marker bool) {
+	r := &m.repeats
+	if !m.config.CollapseRepeats || t.data != r.last.data || t.tag != r.last.tag {
+		r.last, r.count = t, 1
+		return t.tag, false
+	}
+	r.count++
+	r.dirty = true
+	return t.tag + " repeat", r.count == 2
+}
+
+// repeatMarker is the text of the marker for a run of n identical
+// results.
+func repeatMarker(n int) string {
+	return fmt.Sprintf("(×%d)", n)
+}
+
+// updateRepeats updates the count in the marker of the current run of
+// identical results. It must be called with the display in the normal
+// state.
+func (m *miko) updateRepeats() {
+	r := &m.repeats
+	if !r.dirty {
+		return
+	}
+	r.dirty = false
+	d := m.display
+	rng := strings.Fields(EvalErr(fmt.Sprintf("%s tag prevrange repeatCount end", d)))
+	if len(rng) != 2 {
+		// The marker has been trimmed.
+		return
+	}
+	d.Replace(rng[0], rng[1]+" -1c", repeatMarker(r.count), "repeatCount")
+}
+
+// configureRepeats sets up the tags used to collapse repeated results.
+// Clicking a marker shows or hides the results it stands for.
+func (m *miko) configureRepeats() {
+	d := m.display
+	d.TagConfigure("repeat", Elide(true))
+	// repeatExpanded is created after repeat so that it takes priority.
+	d.TagConfigure("repeatExpanded", Elide(false))
+	d.TagBind("repeatCount", "<Button-1>", func() {
+		r := strings.Fields(EvalErr(fmt.Sprintf("%s tag nextrange repeat {current lineend}", d)))
+		if len(r) != 2 {
+			return
+		}
+		if EvalErr(fmt.Sprintf("%s tag nextrange repeatExpanded %s %s", d, r[0], r[1])) != "" {
+			d.TagRemove("repeatExpanded", r[0], r[1])
+		} else {
+			d.TagAdd("repeatExpanded", r[0], r[1])
+		}
+	})
+}
+
+// outputText returns the content of the output display, with repeated
+// results in full and without their markers.
+func (m *miko) outputText() string {
+	d := m.display
+	r := d.TagRanges("repeatCount")
+	if len(r) == 0 {
+		return d.Text()
+	}
+	var buf strings.Builder
+	from := "1.0"
+	for i := 0; i+1 < len(r); i += 2 {
+		buf.WriteString(d.Get(from, r[i])[0])
+		from = r[i+1]
+	}
+	buf.WriteString(d.Get(from, "end-1c")[0])
+	return buf.String()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	. "modernc.org/tk9.0"
+)
+
+// Kinds of transient UI dismissed by Escape, in increasing order of
+// precedence.
+const (
+	overlayDialog = iota // Toplevel dialogs and popup windows.
+	overlayBar           // Bars within the main window, such as find.
+	overlayPopup         // Popups attached to an editor, such as completion.
+)
+
+// overlay is transient UI that is dismissed by Escape.
+type overlay struct {
+	kind  int
+	close func()
+}
+
+// pushOverlay registers transient UI of the given kind that is closed
+// by calling close. The returned function removes the overlay from the
+// stack and must be called when the UI is closed by other means. It is
+// safe to call more than once.
+func (m *miko) pushOverlay(kind int, close func()) (remove func()) {
+	o := &overlay{kind: kind, close: close}
+	m.overlays = append(m.overlays, o)
+	return func() {
+		for i, v := range m.overlays {
+			if v == o {
+				m.overlays = append(m.overlays[:i], m.overlays[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// dialog registers top as an overlay that is destroyed by Escape.
+func (m *miko) dialog(top *ToplevelWidget) {
+	remove := m.pushOverlay(overlayDialog, func() { Destroy(top) })
+	// Destroy events are also delivered to the toplevel for each of
+	// its children, but remove is idempotent.
+	Bind(top, "<Destroy>", Command(remove))
+}
+
+// escape handles the Escape key. It closes the transient UI with the
+// highest precedence: editor popups, then bars, then dialogs. Among UI
+// of the same kind the most recently opened is closed first. Only when
+// no transient UI is open does Escape cancel the current run, so that
+// dismissing a find bar or dialog never cancels a run.
+func (m *miko) escape() {
+	top := -1
+	for i, o := range m.overlays {
+		if top < 0 || o.kind >= m.overlays[top].kind {
+			top = i
+		}
+	}
+	if top < 0 {
+		m.cancel()
+		return
+	}
+	o := m.overlays[top]
+	m.overlays = append(m.overlays[:top], m.overlays[top+1:]...)
+	o.close()
+}
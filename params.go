@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// paramsKey is the field of the data object holding the parameters from
+// the params pane. A program reads a parameter called seed as
+// state.params.seed.
+const paramsKey = "params"
+
+// parseParams parses the content of the params pane. Each non-blank
+// line not starting with # is a name=value pair where the value is
+// JSON, for example
+//
+//	seed=42
+//	now="2024-01-01T00:00:00Z"
+func parseParams(text string) (map[string]json.RawMessage, error) {
+	params := make(map[string]json.RawMessage)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("params line %d: expected name=value", i+1)
+		}
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("params line %d: value of %s is not valid JSON: %s", i+1, name, value)
+		}
+		if _, ok := params[name]; ok {
+			return nil, fmt.Errorf("params line %d: duplicate parameter %s", i+1, name)
+		}
+		params[name] = json.RawMessage(value)
+	}
+	return params, nil
+}
+
+// withParams returns data with the parameters in the params pane text
+// added as the params field. Data must be empty or a JSON object
+// without a params field.
+func withParams(data, text string) (string, error) {
+	params, err := parseParams(text)
+	if err != nil || len(params) == 0 {
+		return data, err
+	}
+	obj := make(map[string]json.RawMessage)
+	if strings.TrimSpace(data) != "" {
+		err = json.Unmarshal([]byte(data), &obj)
+		if err != nil {
+			return "", fmt.Errorf("adding params: data must be a JSON object: %w", err)
+		}
+		if obj == nil {
+			return "", errors.New("adding params: data must be a JSON object")
+		}
+	}
+	if _, ok := obj[paramsKey]; ok {
+		return "", fmt.Errorf("adding params: data already has a %s field", paramsKey)
+	}
+	obj[paramsKey], err = json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(obj)
+	return buf.String(), err
+}
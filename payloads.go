@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	. "modernc.org/tk9.0"
+)
+
+// payload is a named data document saved in the configuration.
+type payload struct {
+	Name string `yaml:"name"`
+	Data string `yaml:"data"`
+}
+
+// payloadBar is the dropdown above the data editor for switching between
+// saved payloads.
+type payloadBar struct {
+	choice *TComboboxWidget
+}
+
+func (m *miko) newPayloadBar(frame *FrameWidget) *payloadBar {
+	bar := frame.TFrame()
+	b := &payloadBar{
+		choice: bar.TCombobox(State("readonly"), Width(16), Textvariable("")),
+	}
+	Bind(b.choice, "<<ComboboxSelected>>", Command(func() {
+		m.loadPayload(b.choice.Textvariable())
+	}))
+	Grid(b.choice, Row(0), Column(0))
+	Grid(bar.TButton(Txt("Save As..."), Command(m.savePayload)), Row(0), Column(1))
+	Grid(bar.TButton(Txt("Delete"), Command(m.deletePayload)), Row(0), Column(2))
+	// The bar shares the row of the pane's title.
+	Grid(bar, Row(0), Column(0), Columnspan(3), Sticky("e"))
+	sync := b.sync(m.config)
+	sync()
+	m.addSync(sync)
+	return b
+}
+
+// sync returns a function that updates the dropdown from the saved
+// payloads.
+func (b *payloadBar) sync(conf *config) func() {
+	return func() {
+		names := make([]string, len(conf.Payloads))
+		for i, p := range conf.Payloads {
+			names[i] = p.Name
+		}
+		b.choice.Configure(Values(names))
+		if !slices.Contains(names, b.choice.Textvariable()) {
+			b.choice.Configure(Textvariable(""))
+		}
+	}
+}
+
+// payloadIndex returns the index of the saved payload called name, or -1.
+func (m *miko) payloadIndex(name string) int {
+	return slices.IndexFunc(m.config.Payloads, func(p payload) bool { return p.Name == name })
+}
+
+// dataSaved reports whether the data editor holds nothing that would be
+// lost by replacing it: it is empty or matches a saved payload.
+func (m *miko) dataSaved() bool {
+	text := m.data.Text()
+	if strings.TrimSpace(text) == "" {
+		return true
+	}
+	return slices.ContainsFunc(m.config.Payloads, func(p payload) bool { return p.Data == text })
+}
+
+// loadPayload replaces the content of the data editor with the saved
+// payload called name, as a single undoable edit. If the current data
+// is not saved as a payload, the user is asked to confirm.
+func (m *miko) loadPayload(name string) {
+	i := m.payloadIndex(name)
+	if i < 0 {
+		return
+	}
+	if !m.dataSaved() {
+		ok := MessageBox(
+			Parent(App),
+			Icon("question"),
+			Type("okcancel"),
+			Title("Load Payload"),
+			Msg(fmt.Sprintf("Replace the data with %q?", name)),
+			Detail("The current data is not saved as a payload. The replacement can be undone."),
+		)
+		if ok != "ok" {
+			return
+		}
+	}
+	setText(m.data, m.config.Payloads[i].Data)
+	m.data.MarkSet("insert", "1.0")
+	m.data.See("insert")
+}
+
+// savePayload asks for a name and saves the content of the data editor
+// as a payload, replacing any payload with the same name.
+func (m *miko) savePayload() {
+	top := App.Toplevel()
+	top.WmTitle("Save Payload")
+	WmTransient(top, App)
+	entry := top.TEntry(Textvariable(m.payloads.choice.Textvariable()), Width(30))
+	save := func() {
+		name := strings.TrimSpace(entry.Textvariable())
+		if name == "" {
+			Bell()
+			return
+		}
+		Destroy(top)
+		p := payload{Name: name, Data: m.data.Text()}
+		if i := m.payloadIndex(name); i >= 0 {
+			m.config.Payloads[i] = p
+		} else {
+			m.config.Payloads = append(m.config.Payloads, p)
+		}
+		m.payloads.choice.Configure(Textvariable(name))
+		m.sync()
+		m.saveConfig()
+	}
+	Grid(top.TLabel(Txt("Name")), Row(0), Column(0), Padx("4"), Pady("4"))
+	Grid(entry, Row(0), Column(1), Padx("4"), Pady("4"))
+	Grid(top.TButton(Txt("Save"), Command(save)), Row(0), Column(2), Padx("4"), Pady("4"))
+	Bind(entry, "<Return>", Command(save))
+	m.dialog(top)
+	Focus(entry)
+}
+
+// deletePayload deletes the payload selected in the dropdown after
+// confirmation. The data editor is not changed.
+func (m *miko) deletePayload() {
+	name := m.payloads.choice.Textvariable()
+	i := m.payloadIndex(name)
+	if i < 0 {
+		return
+	}
+	ok := MessageBox(
+		Parent(App),
+		Icon("question"),
+		Type("okcancel"),
+		Title("Delete Payload"),
+		Msg(fmt.Sprintf("Delete the saved payload %q?", name)),
+	)
+	if ok != "ok" {
+		return
+	}
+	m.config.Payloads = slices.Delete(m.config.Payloads, i, i+1)
+	m.sync()
+	m.saveConfig()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "modernc.org/tk9.0"
+)
+
+// The pinned event is held between the pinStart and pinEnd marks, which
+// move with the text as output is appended and trimmed. While an event
+// is pinned the display does not scroll to new output and trimming
+// keeps the event.
+
+// eventRange returns the lines of the output event containing line: a
+// top-level JSON value starting in the first column and, for objects
+// and arrays, ending with a closing bracket in the first column.
+func (m *miko) eventRange(line int) (first, last int) {
+	d := m.display
+	get := func(n int) string {
+		return d.Get(fmt.Sprintf("%d.0", n), fmt.Sprintf("%d.0 lineend", n))[0]
+	}
+	lines, _ := position(d.Index("end-1c"))
+	first = line
+	for first > 1 {
+		l := get(first)
+		if l != "" && !strings.ContainsAny(l[:1], " \t}]") {
+			break
+		}
+		first--
+	}
+	l := get(first)
+	if !strings.HasSuffix(l, "{") && !strings.HasSuffix(l, "[") {
+		return first, first
+	}
+	for last = first + 1; last < lines; last++ {
+		if l := get(last); strings.HasPrefix(l, "}") || strings.HasPrefix(l, "]") {
+			break
+		}
+	}
+	return first, last
+}
+
+// pin pins the event at the display index.
+func (m *miko) pin(index string) {
+	m.unpin()
+	line, _ := position(m.display.Index(index))
+	first, last := m.eventRange(line)
+	d := m.display
+	d.MarkSet("pinStart", fmt.Sprintf("%d.0", first))
+	d.MarkGravity("pinStart", "left")
+	d.MarkSet("pinEnd", fmt.Sprintf("%d.0 lineend", last))
+	d.TagAdd("pinned", "pinStart", "pinEnd")
+	m.pinned = true
+	d.See("pinStart")
+}
+
+// follow scrolls the display to the end unless an event is pinned.
+func (m *miko) follow() {
+	if !m.pinned {
+		m.display.See(END)
+	}
+}
+
+// unpin releases the pinned event and resumes following new output.
+func (m *miko) unpin() {
+	if !m.pinned {
+		return
+	}
+	m.pinned = false
+	d := m.display
+	d.TagRemove("pinned", "1.0", "end")
+	d.MarkUnset("pinStart", "pinEnd")
+	d.See(END)
+}
+
+// trimPinned removes n lines from the display, taking them from before
+// the pinned event where possible and otherwise from after it.
+func (m *miko) trimPinned(n int) {
+	d := m.display
+	start, _ := position(d.Index("pinStart"))
+	before := min(n, start-1)
+	if before > 0 {
+		d.Delete("1.0", fmt.Sprintf("%d.0", before+1))
+	}
+	if n -= before; n > 0 {
+		end, _ := position(d.Index("pinEnd"))
+		d.Delete(fmt.Sprintf("%d.0", end+1), fmt.Sprintf("%d.0", end+1+n))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// A follow-up expression is run by mito as the program
+//
+//	state.events.as(events, EXPR)
+//
+// with the JSON events in the display as the events field of the data,
+// so that the output of a run can be post-processed without editing the
+// program in the src pane. The result is appended to the display below
+// a header, leaving the output it was computed from in place.
+
+// pipeProgram returns the program evaluating the follow-up expression
+// expr with the displayed events bound to events.
+func pipeProgram(expr string) string {
+	return fmt.Sprintf("state.events.as(events,\n%s\n)\n", expr)
+}
+
+// displayedEvents returns the output events in the display that are
+// JSON values, in display order.
+func (m *miko) displayedEvents() []json.RawMessage {
+	d := m.display
+	var events []json.RawMessage
+	seen := make(map[string]bool)
+	for _, mark := range m.trees.marks {
+		// Marks of trimmed events share the start of the display.
+		index := d.Index(mark)
+		if seen[index] {
+			continue
+		}
+		seen[index] = true
+		line, _ := position(index)
+		first, last := m.eventRange(line)
+		src := strings.Join(d.Get(fmt.Sprintf("%d.0", first), fmt.Sprintf("%d.0 lineend", last)), "")
+		if json.Valid([]byte(src)) {
+			events = append(events, json.RawMessage(src))
+		}
+	}
+	return events
+}
+
+// pipeToCEL shows a dialog to enter a follow-up expression to run over
+// the events in the display.
+func (m *miko) pipeToCEL() {
+	if len(m.displayedEvents()) == 0 {
+		m.announce("No events to pipe")
+		Bell()
+		return
+	}
+	top := App.Toplevel()
+	top.WmTitle("Pipe Output to CEL")
+	WmTransient(top, App)
+	entry := top.TEntry(Textvariable(m.pipeExpr), Width(60), Font(m.editorFont.face))
+	Grid(top.TLabel(Txt("Expression over events")), Row(0), Column(0), Padx("4"), Pady("4"))
+	Grid(entry, Row(0), Column(1), Sticky("ew"), Padx("4"), Pady("4"))
+	GridColumnConfigure(top.Window, 1, Weight(1))
+	Bind(entry, "<Return>", Command(func() {
+		expr := strings.TrimSpace(entry.Textvariable())
+		if expr == "" {
+			Bell()
+			return
+		}
+		m.pipeExpr = expr
+		Destroy(top)
+		m.printError(m.pipe(expr))
+	}))
+	m.dialog(top)
+	Focus(entry)
+	EvalErr(fmt.Sprintf("%s selection range 0 end", entry))
+}
+
+// pipe runs the follow-up expression expr over the events in the
+// display, replacing any current run.
+func (m *miko) pipe(expr string) error {
+	events := m.displayedEvents()
+	if events == nil {
+		// The events are given as an empty list rather than null.
+		events = []json.RawMessage{}
+	}
+	data, err := json.Marshal(map[string]any{"events": events})
+	if err != nil {
+		return err
+	}
+	m.stopBatch()
+	gen, err := m.runs.begin()
+	m.printError(err)
+	// The output is kept as for accumulated runs, with the result
+	// added below it.
+	m.repeats = repeats{}
+	m.rememberTrees()
+	m.dataHeader("pipe to CEL: " + strings.Join(strings.Fields(expr), " "))
+	m.clearErrors()
+	m.srcOffset = 0
+	m.piped = true
+	return m.mito(gen, runOptions{src: pipeProgram(expr), data: string(data)})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/execabs"
+	. "modernc.org/tk9.0"
+)
+
+// profileFlag is the mito flag that writes a CPU profile of the run to
+// the file that follows it, and profileName is the name of the profile
+// in the run's temporary directory.
+const (
+	profileFlag = "-cpuprofile"
+	profileName = "cpu.pprof"
+)
+
+// profileTop is the number of functions listed in a profile summary.
+const profileTop = 5
+
+// configureProfile sets up the link shown after a profiled run for
+// saving a copy of its profile.
+func (m *miko) configureProfile() {
+	d := m.display
+	d.TagConfigure("profileLink", Underline(true))
+	d.TagBind("profileLink", "<Button-1>", m.saveProfile)
+}
+
+// reportProfile adds a summary of the CPU profile at path to the
+// results of the document with id doc, followed by a link to save it.
+// It is called from the goroutine waiting for a profiled run and records
+// the profile as the latest one of runs.
+func (m *miko) reportProfile(runs *runner, doc int, path string) {
+	if _, err := os.Stat(path); err != nil {
+		m.results <- text{data: fmt.Sprintf("no CPU profile was written: %v", err), tag: "error", doc: doc}
+		return
+	}
+	runs.setProfile(path)
+	for _, line := range pprofTop(path, profileTop) {
+		m.results <- text{data: line, tag: "note", doc: doc}
+	}
+	m.results <- text{data: "save CPU profile " + path, tag: "profileLink", doc: doc}
+}
+
+// pprofTop returns the lines of go tool pprof's listing of the n
+// functions taking the most time in the profile at path. Nothing is
+// returned if the go tool is not available or fails.
+func pprofTop(path string, n int) []string {
+	cmd := execabs.Command("go", "tool", "pprof", "-top", fmt.Sprintf("-nodecount=%d", n), path)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if cmd.Run() != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n")
+}
+
+// saveProfile asks where to save a copy of the latest CPU profile.
+func (m *miko) saveProfile() {
+	src := m.runs.profilePath()
+	if src == "" {
+		return
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		m.printError(fmt.Errorf("reading profile: %w", err))
+		return
+	}
+	dst := m.getSaveFile(Title("Save CPU Profile"), Defaultextension(".pprof"), Initialfile(profileName))
+	if dst == "" {
+		return
+	}
+	err = os.WriteFile(dst, b, 0o600)
+	if err != nil {
+		m.printError(fmt.Errorf("saving profile: %w", err))
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// qrFormatL is the format information of ISO/IEC 18004 table C.1 for
+// error correction level L, by mask, most significant bit first.
+var qrFormatL = [8]string{
+	"111011111000100",
+	"111001011110011",
+	"111110110101010",
+	"111100010011101",
+	"110011000101111",
+	"110001100011000",
+	"110110001000001",
+	"110100101110110",
+}
+
+// formatBits returns the two copies of the format information of q,
+// most significant bit first.
+func formatBits(q *qrCode) (first, second string) {
+	bit := func(x, y int) byte {
+		if q.modules[y][x] {
+			return '1'
+		}
+		return '0'
+	}
+	var a, b [15]byte
+	for i := range 15 {
+		// Bit i, counting from the least significant.
+		switch {
+		case i <= 5:
+			a[14-i] = bit(8, i)
+		case i == 6:
+			a[14-i] = bit(8, 7)
+		case i == 7:
+			a[14-i] = bit(8, 8)
+		case i == 8:
+			a[14-i] = bit(7, 8)
+		default:
+			a[14-i] = bit(14-i, 8)
+		}
+		if i < 8 {
+			b[14-i] = bit(q.size-1-i, 8)
+		} else {
+			b[14-i] = bit(8, q.size-15+i)
+		}
+	}
+	return string(a[:]), string(b[:])
+}
+
+func TestQRFormat(t *testing.T) {
+	for mask, want := range qrFormatL {
+		q := newQRCode(1)
+		q.drawFormat(mask)
+		first, second := formatBits(q)
+		if first != want || second != want {
+			t.Errorf("unexpected format information for mask %d: got:%s and %s want:%s", mask, first, second, want)
+		}
+		if !q.modules[q.size-8][8] {
+			t.Errorf("dark module not set for mask %d", mask)
+		}
+	}
+}
+
+// qrEncodeTests are compared with matrices in testdata/qr made by an
+// independent encoder, github.com/skip2/go-qrcode, at level L. Encoders
+// may choose different masks, so the matrix is compared using the mask
+// given in its format information.
+var qrEncodeTests = []struct {
+	name    string
+	data    string
+	version int
+}{
+	{name: "hello", data: "hello", version: 1},
+	{name: "link", data: "https://example.com/share?x=abc", version: 2},
+	{name: "repeated", data: strings.Repeat("a", 100), version: 5},
+	{name: "version7", data: strings.Repeat("miko shares cel programs with links. ", 4), version: 7},
+	{name: "version11", data: strings.Repeat("the quick brown fox jumps over the lazy dog. ", 7), version: 11},
+	{name: "long", data: strings.Repeat("the quick brown fox jumps over the lazy dog. ", 26), version: 24},
+}
+
+func TestQREncode(t *testing.T) {
+	for _, test := range qrEncodeTests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", "qr", test.name+".txt"))
+			if err != nil {
+				t.Fatalf("unexpected error reading matrix: %v", err)
+			}
+			want := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+
+			q, err := qrEncode([]byte(test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := (q.size - 17) / 4; got != test.version || q.size != len(want) {
+				t.Fatalf("unexpected version: got:%d (%d modules) want:%d (%d modules)", got, q.size, test.version, len(want))
+			}
+
+			// The mask chosen must have the lowest penalty.
+			best, chosen := q.mask, q.penalty()
+			for mask := range 8 {
+				q.applyMask(q.mask)
+				q.applyMask(mask)
+				q.drawFormat(mask)
+				q.mask = mask
+				if p := q.penalty(); p < chosen {
+					t.Errorf("mask %d has lower penalty than chosen mask %d: %d < %d", mask, best, p, chosen)
+				}
+			}
+
+			// Compare with the reference using its mask.
+			ref := &qrCode{size: len(want), modules: matrix(want)}
+			first, _ := formatBits(ref)
+			mask := slices.Index(qrFormatL[:], first)
+			if mask < 0 {
+				t.Fatal("no valid format information in reference matrix")
+			}
+			q.applyMask(q.mask)
+			q.applyMask(mask)
+			q.drawFormat(mask)
+			for y, row := range ref.modules {
+				for x, dark := range row {
+					if q.modules[y][x] != dark {
+						t.Fatalf("module %d,%d differs from the reference with mask %d", x, y, mask)
+					}
+				}
+			}
+		})
+	}
+}
+
+// matrix returns the modules of rows drawn with '#' for dark modules.
+func matrix(rows []string) [][]bool {
+	m := make([][]bool, len(rows))
+	for y, row := range rows {
+		m[y] = make([]bool, len(row))
+		for x, c := range row {
+			m[y][x] = c == '#'
+		}
+	}
+	return m
+}
+
+var qrPenaltyTests = []struct {
+	name string
+	dark func(y, x int) bool
+	want int
+}{
+	{
+		// 42 runs of 21 modules score 19 each, the 400 2×2 blocks
+		// score 3 each and no modules being dark scores 90.
+		name: "light",
+		dark: func(y, x int) bool { return false },
+		want: 42*19 + 400*3 + 90,
+	},
+	{
+		name: "checkerboard",
+		dark: func(y, x int) bool { return (x+y)%2 == 0 },
+		want: 0,
+	},
+	{
+		// The first row holds a finder-like pattern followed by
+		// light modules, scoring 40, with runs of 14 light modules
+		// after it. The five columns with a dark module at the top
+		// have runs of 20 light modules, and 13 of the 2×2 blocks of
+		// the first two rows are light.
+		name: "finder_like",
+		dark: func(y, x int) bool { return y == 0 && x < 7 && x != 1 && x != 5 },
+		want: 40 + (20*19 + 12) + (16*19 + 5*18) + (380+13)*3 + 90,
+	},
+}
+
+func TestQRPenalty(t *testing.T) {
+	for _, test := range qrPenaltyTests {
+		t.Run(test.name, func(t *testing.T) {
+			q := newQRCode(1)
+			for y := range q.size {
+				for x := range q.size {
+					q.modules[y][x] = test.dark(y, x)
+				}
+			}
+			if got := q.penalty(); got != test.want {
+				t.Errorf("unexpected penalty: got:%d want:%d", got, test.want)
+			}
+		})
+	}
+}
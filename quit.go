@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// modified returns the names of the input panes that have been edited
+// since they were loaded or saved.
+func (m *miko) modified() []string {
+	var names []string
+	for _, p := range m.panes {
+		if EvalErr(fmt.Sprintf("%s edit modified", p.text)) == "1" {
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
+
+// setModified sets the modified flag of all the input panes.
+func (m *miko) setModified(modified bool) {
+	for _, p := range m.panes {
+		EvalErr(fmt.Sprintf("%s edit modified %d", p.text, btoi(modified)))
+	}
+}
+
+// showModified marks the title label of the editor w with an asterisk
+// while its modified flag is set. Tk raises <<Modified>> whenever the
+// flag changes, including when it is cleared on saving or loading and
+// when undo returns the text to its saved state.
+func showModified(w *TextWidget, label *LabelWidget, title string) {
+	Bind(w, "<<Modified>>", Command(func() {
+		if EvalErr(fmt.Sprintf("%s edit modified", w)) == "1" {
+			label.Configure(Txt(title + " *"))
+		} else {
+			label.Configure(Txt(title))
+		}
+	}))
+}
+
+// save writes the inputs to a txtar file chosen by the user, first
+// removing trailing white space if configured to. It reports whether
+// the inputs were saved.
+func (m *miko) save() bool {
+	path := m.getSaveFile(Title("Save"), Defaultextension(".txtar"))
+	if path == "" {
+		return false
+	}
+	if m.config.TrimOnSave {
+		m.trimSaved()
+	}
+	err := os.WriteFile(path, txtar.Format(m.archive(false)), 0o600)
+	if err != nil {
+		m.printError(fmt.Errorf("saving inputs: %w", err))
+		return false
+	}
+	m.setModified(false)
+	return true
+}
+
+// quit closes miko, first asking for confirmation if a run is in
+// progress or the inputs have unsaved changes, unless the user has
+// asked not to be asked again.
+func (m *miko) quit() {
+	running := slices.ContainsFunc(m.runners(), (*runner).running)
+	modified := m.modified()
+	if m.noQuit || (!running && len(modified) == 0) {
+		m.exit()
+		return
+	}
+
+	var msg []string
+	if running {
+		msg = append(msg, "A run is in progress and will be cancelled.")
+	}
+	if len(modified) != 0 {
+		msg = append(msg, fmt.Sprintf("Unsaved changes in %s will be lost.", strings.Join(modified, ", ")))
+	}
+
+	top := App.Toplevel()
+	top.WmTitle("Quit miko?")
+	WmTransient(top, App)
+	Grid(top.TLabel(Txt(strings.Join(msg, "\n")), Justify("left")),
+		Row(0), Column(0), Sticky("w"), Padx("8"), Pady("8"))
+	dontAsk := top.TCheckbutton(Txt("Don't ask again this session"), Variable(0))
+	Grid(dontAsk, Row(1), Column(0), Sticky("w"), Padx("8"))
+	remember := func() { m.noQuit = dontAsk.Variable() == "1" }
+
+	buttons := top.TFrame()
+	Grid(buttons, Row(2), Column(0), Sticky("e"), Padx("8"), Pady("8"))
+	save := buttons.TButton(Txt("Save..."), Command(func() {
+		if m.save() {
+			remember()
+			m.exit()
+		}
+	}))
+	if len(modified) == 0 {
+		save.Configure(State("disabled"))
+	}
+	snarf := buttons.TButton(Txt("Snarf"), Command(m.snarf))
+	quit := buttons.TButton(Txt("Quit"), Command(func() {
+		remember()
+		m.exit()
+	}))
+	cancel := buttons.TButton(Txt("Cancel"), Command(func() {
+		remember()
+		Destroy(top)
+	}))
+	Grid(save, snarf, quit, cancel, Row(0), Padx("2"))
+	m.dialog(top)
+	Focus(cancel)
+	GrabSet(top)
+}
+
+// exit cancels any run in progress and closes the main window.
+func (m *miko) exit() {
+	m.cancel()
+	m.saveTabs()
+	Destroy(App)
+}
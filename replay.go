@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	. "modernc.org/tk9.0"
+)
+
+// capturedRequest is an HTTP request, and the response it received,
+// taken from a record in mito's request log.
+type capturedRequest struct {
+	method string
+	url    string
+	header http.Header
+	body   string
+
+	status   string // Logged status code of the response.
+	response string // Logged body of the response.
+}
+
+// Log fields holding the parts of a request and response that are not
+// also used for timing.
+var (
+	headerKeys       = []string{"http.request.header", "headers", "header"}
+	requestBodyKeys  = []string{"http.request.body.content", "body"}
+	responseBodyKeys = []string{"http.response.body.content", "response"}
+)
+
+// redactedHeaders are request headers whose values are not shown in
+// miko's windows. The names are canonical.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// redacted reports whether the value of the request header name is
+// hidden when shown.
+func redacted(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	return slices.Contains(redactedHeaders, name) || strings.Contains(strings.ToLower(name), "token")
+}
+
+// parseRequest returns the request logged by fields. Records without a
+// method and URL are not requests.
+func parseRequest(fields []logField) (capturedRequest, bool) {
+	get := func(keys []string) string {
+		for _, k := range keys {
+			for _, f := range fields {
+				if f.key == k {
+					return f.value
+				}
+			}
+		}
+		return ""
+	}
+	r := capturedRequest{
+		method:   get(methodKeys),
+		url:      get(urlKeys),
+		body:     get(requestBodyKeys),
+		status:   get(statusKeys),
+		response: get(responseBodyKeys),
+	}
+	if r.method == "" || r.url == "" {
+		return capturedRequest{}, false
+	}
+	// Headers are logged as an object of names to values or lists of
+	// values. Headers that cannot be decoded are not sent.
+	var header map[string]any
+	if json.Unmarshal([]byte(get(headerKeys)), &header) == nil {
+		r.header = make(http.Header)
+		for k, v := range header {
+			switch v := v.(type) {
+			case string:
+				r.header.Add(k, v)
+			case []any:
+				for _, v := range v {
+					if s, ok := v.(string); ok {
+						r.header.Add(k, s)
+					}
+				}
+			}
+		}
+	}
+	return r, true
+}
+
+// rebase returns u with its scheme and host replaced by those of base
+// and the path of base prepended to its path.
+func rebase(u string, base *url.URL) (string, error) {
+	orig, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	orig.Scheme = base.Scheme
+	orig.Host = base.Host
+	orig.User = base.User
+	orig.Path = strings.TrimSuffix(base.Path, "/") + orig.Path
+	orig.RawPath = ""
+	return orig.String(), nil
+}
+
+// captured holds the requests logged by the latest run. Requests are
+// added from the goroutine reading mito's stderr.
+type captured struct {
+	mu   sync.Mutex
+	reqs []capturedRequest
+}
+
+func (c *captured) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reqs = nil
+}
+
+func (c *captured) add(r capturedRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reqs = append(c.reqs, r)
+}
+
+func (c *captured) requests() []capturedRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.reqs)
+}
+
+// replayTimeout is the time allowed for each replayed request.
+const replayTimeout = 30 * time.Second
+
+// replayed is the outcome of re-issuing a captured request.
+type replayed struct {
+	i    int // Index of the request among those replayed.
+	url  string
+	resp string // Status and body of the response, or the error.
+}
+
+// showReplay opens a window that re-issues the requests logged by the
+// latest run against a base URL, showing the logged and new responses
+// side by side. Requests are only captured when Log Requests is on.
+func (m *miko) showReplay() {
+	reqs := m.captured.requests()
+	if len(reqs) == 0 {
+		MessageBox(
+			Parent(App),
+			Icon("info"),
+			Title("Replay Requests"),
+			Msg("No requests were logged by the latest run."),
+			Detail("Turn on Log Requests and run again to capture the requests to replay."),
+		)
+		return
+	}
+	top := App.Toplevel()
+	top.WmTitle("Replay Requests")
+	GridRowConfigure(top.Window, 1, Weight(1))
+	GridColumnConfigure(top.Window, 0, Weight(1))
+
+	bar := top.TFrame()
+	Grid(bar, Row(0), Column(0), Sticky("ew"), Padx("4"), Pady("4"))
+	base := bar.TEntry(Textvariable(originOf(reqs[0].url)), Width(40))
+	Grid(bar.TLabel(Txt("Base URL")), Row(0), Column(0), Padx("4"))
+	Grid(base, Row(0), Column(1), Sticky("ew"))
+	GridColumnConfigure(bar.Window, 1, Weight(1))
+
+	panes := top.TPanedwindow(Orient("horizontal"))
+	Grid(panes, Row(1), Column(0), Sticky("news"))
+	var logged, replies *TextWidget
+	for _, side := range []struct {
+		title string
+		dst   **TextWidget
+	}{
+		{"Logged responses", &logged},
+		{"Replayed responses", &replies},
+	} {
+		frame := panes.Frame()
+		textWidget(side.dst, frame, side.title, m.displayFont.face, m.displayFont.tabWidth, false)
+		panes.Add(frame.Window, Weight(1))
+	}
+	for i, r := range reqs {
+		logged.Insert("end", fmt.Sprintf("%d. %s %s\n%s%s\n%s\n\n", i+1, r.method, r.url, showHeader(r.header), r.status, r.response))
+	}
+	logged.Configure(State("disabled"))
+	replies.Configure(State("disabled"))
+
+	rw := &replayWindow{reqs: reqs, text: replies}
+	m.replay = rw
+	replay := bar.TButton(Txt("Replay"), Command(func() {
+		u, err := url.Parse(strings.TrimSpace(base.Textvariable()))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			MessageBox(Parent(top), Icon("error"), Title("Replay Requests"), Msg(fmt.Sprintf("invalid base URL %q", base.Textvariable())))
+			return
+		}
+		rw.stop()
+		var ctx context.Context
+		ctx, rw.cancel = context.WithCancel(context.Background())
+		replies.Configure(State("normal"))
+		replies.Delete("1.0", "end")
+		replies.Configure(State("disabled"))
+		rw.results = make(chan replayed, len(reqs))
+		go replayRequests(ctx, reqs, u, rw.results)
+	}))
+	Grid(replay, Row(0), Column(2), Padx("4"))
+	remove := m.pushOverlay(overlayDialog, func() { Destroy(top) })
+	Bind(top, "<Destroy>", Command(func(e *Event) {
+		remove()
+		if e.EventWindow != nil && e.EventWindow.String() == top.String() {
+			rw.stop()
+			if m.replay == rw {
+				m.replay = nil
+			}
+		}
+	}))
+}
+
+// replayWindow is the state of an open Replay Requests window.
+type replayWindow struct {
+	reqs    []capturedRequest
+	text    *TextWidget // The replayed responses.
+	results chan replayed
+	cancel  context.CancelFunc
+}
+
+// stop cancels the replay in progress, if any.
+func (rw *replayWindow) stop() {
+	if rw.cancel != nil {
+		rw.cancel()
+		rw.cancel = nil
+	}
+	rw.results = nil
+}
+
+// updateReplay adds the responses that have arrived to the Replay
+// Requests window.
+func (m *miko) updateReplay() {
+	rw := m.replay
+	if rw == nil || rw.results == nil {
+		return
+	}
+	for {
+		select {
+		case r, ok := <-rw.results:
+			if !ok {
+				rw.results = nil
+				return
+			}
+			rw.text.Configure(State("normal"))
+			rw.text.Insert("end", fmt.Sprintf("%d. %s %s\n%s\n\n", r.i+1, rw.reqs[r.i].method, r.url, r.resp))
+			rw.text.Configure(State("disabled"))
+		default:
+			return
+		}
+	}
+}
+
+// replayRequests re-issues reqs with their URLs rebased on base,
+// sending the outcome of each to results in turn. The results channel
+// is closed when all have been sent or ctx is cancelled.
+func replayRequests(ctx context.Context, reqs []capturedRequest, base *url.URL, results chan<- replayed) {
+	defer close(results)
+	for i, r := range reqs {
+		u, err := rebase(r.url, base)
+		if err != nil {
+			results <- replayed{i: i, url: r.url, resp: err.Error()}
+			continue
+		}
+		resp, err := replayRequest(ctx, r, u)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			resp = err.Error()
+		}
+		results <- replayed{i: i, url: u, resp: resp}
+	}
+}
+
+// replayRequest sends r to u and returns the status and body of the
+// response.
+func replayRequest(ctx context.Context, r capturedRequest, u string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.method, u, strings.NewReader(r.body))
+	if err != nil {
+		return "", err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if json.Indent(&buf, body, "", "\t") != nil {
+		buf.Reset()
+		buf.Write(body)
+	}
+	return fmt.Sprintf("%d\n%s", resp.StatusCode, &buf), nil
+}
+
+// showHeader formats h for display with the values of redacted headers
+// hidden.
+func showHeader(h http.Header) string {
+	var buf strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(h)) {
+		v := strings.Join(h[k], ", ")
+		if redacted(k) {
+			v = "[redacted]"
+		}
+		fmt.Fprintf(&buf, "%s: %s\n", k, v)
+	}
+	return buf.String()
+}
+
+// originOf returns the scheme and host of u.
+func originOf(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return (&url.URL{Scheme: p.Scheme, Host: p.Host}).String()
+}
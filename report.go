@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	. "modernc.org/tk9.0"
+)
+
+// reportFormats are the formats of run history reports.
+var reportFormats = []string{"Markdown", "JSON"}
+
+// reportRun is a run in a JSON history report.
+type reportRun struct {
+	Run      int               `json:"run"`
+	Start    time.Time         `json:"start"`
+	Duration string            `json:"duration,omitempty"`
+	Status   string            `json:"status"`
+	Note     string            `json:"note,omitempty"`
+	Inputs   map[string]string `json:"inputs,omitempty"`
+}
+
+// historyReport returns a report of recs in format, with the inputs of
+// each run if inputs is true. Values of redacted fields in the data and
+// cfg are hidden.
+func historyReport(recs []runRecord, format string, inputs bool) ([]byte, error) {
+	runs := make([]reportRun, len(recs))
+	for i, r := range recs {
+		runs[i] = reportRun{Run: r.number, Start: r.start, Status: r.status, Note: r.note}
+		if r.status == "" {
+			runs[i].Status = "running"
+		} else {
+			runs[i].Duration = elapsed(r.duration)
+		}
+		if inputs && r.inputs != nil {
+			runs[i].Inputs = make(map[string]string)
+			for _, f := range r.inputs.Files {
+				runs[i].Inputs[f.Name] = redactInput(f.Name, string(f.Data))
+			}
+		}
+	}
+	if format == "JSON" {
+		b, err := json.MarshalIndent(runs, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Run history\n")
+	for i, r := range runs {
+		fmt.Fprintf(&buf, "\n## Run %d", r.Run)
+		if r.Note != "" {
+			fmt.Fprintf(&buf, ": %s", r.Note)
+		}
+		fmt.Fprintf(&buf, "\n\n- Started: %s\n", r.Start.Format(time.DateTime))
+		if r.Duration != "" {
+			fmt.Fprintf(&buf, "- Duration: %s\n", r.Duration)
+		}
+		fmt.Fprintf(&buf, "- Status: %s\n", r.Status)
+		if r.Inputs == nil {
+			continue
+		}
+		// Inputs are given in the order of the archive.
+		for _, f := range recs[i].inputs.Files {
+			text := r.Inputs[f.Name]
+			fence := "```"
+			for strings.Contains(text, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&buf, "\n%s:\n\n%s%s\n%s\n%s\n", f.Name, fence, fenceLanguage(f.Name), strings.TrimSuffix(text, "\n"), fence)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// fenceLanguage returns the language of a Markdown code block holding
+// the input file name.
+func fenceLanguage(name string) string {
+	switch path.Ext(name) {
+	case ".json":
+		return "json"
+	case ".yaml":
+		return "yaml"
+	}
+	return ""
+}
+
+// redactInput returns the content of the input file name with the values
+// of redacted fields hidden if it is the data or the cfg.
+func redactInput(name, text string) string {
+	switch name {
+	case "data.json":
+		return redactBody(text)
+	case "cfg.yaml":
+		return redactYAML(text)
+	}
+	return text
+}
+
+// redactYAML returns the YAML document text with the values of redacted
+// fields, and of fields holding passwords or secrets, hidden. Text that
+// is not valid YAML is returned unchanged.
+func redactYAML(text string) string {
+	var doc yaml.Node
+	if yaml.Unmarshal([]byte(text), &doc) != nil {
+		return text
+	}
+	var redact func(n *yaml.Node)
+	redact = func(n *yaml.Node) {
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k, v := n.Content[i], n.Content[i+1]
+				key := strings.ToLower(k.Value)
+				if v.Kind == yaml.ScalarNode && (redacted(k.Value) || strings.Contains(key, "password") || strings.Contains(key, "secret")) {
+					v.SetString("[redacted]")
+				}
+			}
+		}
+		for _, c := range n.Content {
+			redact(c)
+		}
+	}
+	redact(&doc)
+	b, err := yaml.Marshal(&doc)
+	if err != nil {
+		return text
+	}
+	return string(b)
+}
+
+// exportHistory shows a dialog to export the run history as a report.
+func (m *miko) exportHistory() {
+	recs, _ := m.history.records()
+	if len(recs) == 0 {
+		MessageBox(Parent(App), Icon("info"), Title("Export Run History"), Msg("There are no runs to export."))
+		return
+	}
+	top := App.Toplevel()
+	top.WmTitle("Export Run History")
+	WmTransient(top, App)
+	format := top.TCombobox(Values(reportFormats), State("readonly"), Textvariable(reportFormats[0]), Width(10))
+	inputs := top.TCheckbutton(Txt("Include the inputs of each run"), Variable(0))
+	Grid(top.TLabel(Txt("Format")), Row(0), Column(0), Sticky("w"), Padx("4"), Pady("4"))
+	Grid(format, Row(0), Column(1), Sticky("w"), Padx("4"), Pady("4"))
+	Grid(inputs, Row(1), Column(0), Columnspan(2), Sticky("w"), Padx("4"))
+	export := func() {
+		// The choices are read before their widgets are destroyed.
+		f, withInputs := format.Textvariable(), inputs.Variable() == "1"
+		ext := ".md"
+		if f == "JSON" {
+			ext = ".json"
+		}
+		Destroy(top)
+		path := m.getSaveFile(Title("Export Run History"), Defaultextension(ext))
+		if path == "" {
+			return
+		}
+		recs, _ := m.history.records()
+		b, err := historyReport(recs, f, withInputs)
+		if err == nil {
+			err = os.WriteFile(path, b, 0o600)
+		}
+		if err != nil {
+			m.printError(fmt.Errorf("exporting run history: %w", err))
+		}
+	}
+	buttons := top.TFrame()
+	Grid(buttons, Row(2), Column(0), Columnspan(2), Sticky("e"), Padx("4"), Pady("4"))
+	Grid(buttons.TButton(Txt("Export..."), Command(export)), buttons.TButton(Txt("Cancel"), Command(func() { Destroy(top) })), Row(0), Padx("2"))
+	m.dialog(top)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// resyncReader is a reader that allows a JSON decoder reading from it to
+// be restarted at the next line after invalid input.
+type resyncReader struct {
+	buf []byte // Input to be read before r.
+	r   io.Reader
+}
+
+func (r *resyncReader) Read(p []byte) (int, error) {
+	if len(r.buf) != 0 {
+		n := copy(p, r.buf)
+		r.buf = r.buf[n:]
+		return n, nil
+	}
+	return r.r.Read(p)
+}
+
+// skipLine discards the line starting at the first non-white space in
+// buffered, the input held by a failed decoder, followed by the input
+// remaining in r. It returns the discarded line without its line ending.
+// Reading resumes at the start of the next line.
+func (r *resyncReader) skipLine(buffered []byte) ([]byte, error) {
+	rest := append(bytes.TrimLeft(buffered, " \t\r\n"), r.buf...)
+	r.buf = nil
+	var p [4096]byte
+	for {
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			r.buf = rest[i+1:]
+			return bytes.TrimRight(rest[:i], "\r"), nil
+		}
+		n, err := r.r.Read(p[:])
+		rest = append(rest, p[:n]...)
+		if err != nil {
+			return rest, err
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var resyncTests = []struct {
+	name        string
+	in          string
+	wantValues  []string
+	wantSkipped []string
+}{
+	{
+		name:        "line_between",
+		in:          "{\"a\":1}\ngarbage\n{\"b\":2}\n",
+		wantValues:  []string{`{"a":1}`, `{"b":2}`},
+		wantSkipped: []string{"garbage"},
+	},
+	{
+		name:        "after_value",
+		in:          "{\"a\":1} garbage\n{\"b\":2}\n",
+		wantValues:  []string{`{"a":1}`, `{"b":2}`},
+		wantSkipped: []string{"garbage"},
+	},
+	{
+		name:        "truncated_value",
+		in:          "{\"a\":1}\n{\"a\":\n{\"b\":2}\r\n",
+		wantValues:  []string{`{"a":1}`, `{"b":2}`},
+		wantSkipped: []string{`{"a":`},
+	},
+	{
+		name:        "unterminated",
+		in:          "{\"a\":1}\r\ngarbage",
+		wantValues:  []string{`{"a":1}`},
+		wantSkipped: []string{"garbage"},
+	},
+}
+
+// TestResyncReader checks that decoding resumes at the line after
+// invalid input, as it does for mito's stdout, and that the skipped
+// input is returned.
+func TestResyncReader(t *testing.T) {
+	for _, test := range resyncTests {
+		t.Run(test.name, func(t *testing.T) {
+			in := &resyncReader{r: strings.NewReader(test.in)}
+			dec := json.NewDecoder(in)
+			var values, skipped []string
+			for {
+				var v json.RawMessage
+				err := dec.Decode(&v)
+				var se *json.SyntaxError
+				switch {
+				case err == nil:
+					values = append(values, string(v))
+					continue
+				case err == io.EOF:
+				case errors.As(err, &se), err == io.ErrUnexpectedEOF:
+					buffered, _ := io.ReadAll(dec.Buffered())
+					line, err := in.skipLine(buffered)
+					skipped = append(skipped, string(line))
+					if err == nil {
+						dec = json.NewDecoder(in)
+						continue
+					}
+				default:
+					t.Fatalf("unexpected error: %v", err)
+				}
+				break
+			}
+			if !slices.Equal(values, test.wantValues) {
+				t.Errorf("unexpected values: got:%q want:%q", values, test.wantValues)
+			}
+			if !slices.Equal(skipped, test.wantSkipped) {
+				t.Errorf("unexpected skipped input: got:%q want:%q", skipped, test.wantSkipped)
+			}
+		})
+	}
+}
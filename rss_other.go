@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// peakRSS reports that the peak resident set size is not available on
+// this platform.
+func peakRSS(state *os.ProcessState) (int64, bool) {
+	return 0, false
+}
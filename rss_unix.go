@@ -0,0 +1,26 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakRSS returns the peak resident set size in bytes of the exited
+// process described by state.
+func peakRSS(state *os.ProcessState) (int64, bool) {
+	if state == nil {
+		return 0, false
+	}
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || ru.Maxrss == 0 {
+		return 0, false
+	}
+	// Darwin reports the size in bytes, other systems in kilobytes.
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(ru.Maxrss), true
+	}
+	return int64(ru.Maxrss) * 1024, true
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// staleTimeout is how long after a run is killed its temporary
+// directory is removed, if the run's own cleanup has not done so.
+const staleTimeout = 10 * time.Second
+
+// runner tracks the current mito process and the temporary directories
+// created for runs. Each run is given a new generation so that starting
+// a run reliably supersedes the previous one, and the completion of a
+// superseded run cannot clear the record of its successor.
+type runner struct {
+	mu   sync.Mutex
+	gen  uint64
+	ps   *os.Process
+	dir  string          // Temporary directory of the current run.
+	dirs map[string]bool // Temporary directories not yet removed.
+
+	// start and end are when the process of the latest run started
+	// and exited. The end is zero while the process is running.
+	start, end time.Time
+	exit       error  // The result of waiting for the latest process.
+	profile    string // Path of the latest CPU profile, if any.
+	// rss is the peak resident set size of the latest process in
+	// bytes, or zero if it is not known.
+	rss int64
+	// cancelled records that the latest process was killed by a
+	// cancel or by a new run.
+	cancelled bool
+}
+
+// begin kills any current process and returns the generation for a
+// new run. The returned error is from killing the previous process.
+func (r *runner) begin() (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	if r.ps != nil {
+		err = r.kill()
+		r.ps = nil
+		r.cancelled = true
+	}
+	r.gen++
+	return r.gen, err
+}
+
+// kill kills the current process and schedules the removal of its
+// temporary directory in case the run's cleanup stalls. It must be
+// called with r.mu held.
+func (r *runner) kill() error {
+	err := r.ps.Kill()
+	if dir := r.dir; dir != "" {
+		time.AfterFunc(staleTimeout, func() { r.removeDir(dir) })
+	}
+	return err
+}
+
+// started records ps as the process for run generation gen, using the
+// temporary directory dir. If the run has already been superseded, ps
+// is killed.
+func (r *runner) started(gen uint64, ps *os.Process, dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if gen != r.gen {
+		ps.Kill()
+		time.AfterFunc(staleTimeout, func() { r.removeDir(dir) })
+		return
+	}
+	r.ps = ps
+	r.dir = dir
+	r.start = time.Now()
+	r.end = time.Time{}
+	r.exit = nil
+	r.rss = 0
+	r.cancelled = false
+}
+
+// finished records that the process for run generation gen has exited
+// with the result err of waiting for it and the final state. It returns
+// how long the process ran and whether it ended by itself, rather than
+// being cancelled or superseded.
+func (r *runner) finished(gen uint64, err error, state *os.ProcessState) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if gen != r.gen {
+		return 0, false
+	}
+	r.ps = nil
+	r.end = time.Now()
+	r.exit = err
+	r.rss, _ = peakRSS(state)
+	return r.end.Sub(r.start), !r.cancelled
+}
+
+// peakRSS returns the peak resident set size in bytes of the latest
+// process, or zero if it is not known.
+func (r *runner) peakRSS() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rss
+}
+
+// setProfile records path as the latest CPU profile.
+func (r *runner) setProfile(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profile = path
+}
+
+// profilePath returns the path of the latest CPU profile, or the empty
+// string if no run has been profiled.
+func (r *runner) profilePath() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.profile
+}
+
+// exitStatus describes how the process of the latest run exited.
+func (r *runner) exitStatus() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.exit == nil {
+		return "exit status 0"
+	}
+	return r.exit.Error()
+}
+
+// elapsed returns how long the process of the latest run has been
+// running, or ran for if it has exited. It returns zero if no process
+// has been started.
+func (r *runner) elapsed() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case r.start.IsZero():
+		return 0
+	case r.end.IsZero():
+		return time.Since(r.start)
+	default:
+		return r.end.Sub(r.start)
+	}
+}
+
+// cancel kills the current process, if any.
+func (r *runner) cancel() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ps == nil {
+		return nil
+	}
+	r.cancelled = true
+	return r.kill()
+}
+
+// current reports whether gen is the generation of the latest run.
+func (r *runner) current(gen uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return gen == r.gen
+}
+
+// running reports whether a process is running.
+func (r *runner) running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ps != nil
+}
+
+// tempDir creates and records a temporary directory for a run.
+func (r *runner) tempDir() (string, error) {
+	dir, err := os.MkdirTemp("", "miko-*")
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dirs == nil {
+		r.dirs = make(map[string]bool)
+	}
+	r.dirs[dir] = true
+	return dir, nil
+}
+
+// removeDir removes a temporary directory created by tempDir.
+func (r *runner) removeDir(dir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirs[dir] {
+		return nil
+	}
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	delete(r.dirs, dir)
+	if r.dir == dir {
+		r.dir = ""
+	}
+	return nil
+}
+
+// tempDirs returns the temporary directories that have not been
+// removed, and the directory of the running process if there is one.
+func (r *runner) tempDirs() (dirs []string, current string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for dir := range r.dirs {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+	if r.ps != nil {
+		current = r.dir
+	}
+	return dirs, current
+}
+
+// removeStale removes the temporary directories that do not belong to
+// the running process. If all is true, the running process's directory
+// is also removed.
+func (r *runner) removeStale(all bool) error {
+	dirs, current := r.tempDirs()
+	var errs []error
+	for _, dir := range dirs {
+		if dir == current && !all {
+			continue
+		}
+		errs = append(errs, r.removeDir(dir))
+	}
+	return errors.Join(errs...)
+}
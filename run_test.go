@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+)
+
+// sleeper starts a process that runs until it is killed.
+func sleeper(t *testing.T) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	return cmd
+}
+
+// TestRunnerSuperseded checks that the completion of a superseded run
+// does not clear the record of the run that replaced it.
+func TestRunnerSuperseded(t *testing.T) {
+	var r runner
+	first, _ := r.begin()
+	old := sleeper(t)
+	r.started(first, old.Process, "")
+
+	second, _ := r.begin()
+	cur := sleeper(t)
+	r.started(second, cur.Process, "")
+
+	err := old.Wait()
+	if _, ended := r.finished(first, err, old.ProcessState); ended {
+		t.Error("superseded run reported as ended by itself")
+	}
+	if !r.running() {
+		t.Fatal("superseded run cleared the current process")
+	}
+
+	r.cancel()
+	err = cur.Wait()
+	if _, ended := r.finished(second, err, cur.ProcessState); ended {
+		t.Error("cancelled run reported as ended by itself")
+	}
+	if r.running() {
+		t.Error("finished run still recorded as running")
+	}
+}
+
+// TestRunnerStress starts, supersedes and cancels runs concurrently.
+// It is most useful with -race.
+func TestRunnerStress(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skipf("no sleep: %v", err)
+	}
+	const runs = 20
+	var (
+		r    runner
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		dirs []string // Directories of the runs.
+	)
+	for range runs {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			gen, _ := r.begin()
+			dir, err := r.tempDir()
+			if err != nil {
+				t.Errorf("unexpected error creating directory: %v", err)
+				return
+			}
+			cmd := exec.Command("sleep", "60")
+			if err := cmd.Start(); err != nil {
+				t.Errorf("unexpected error starting process: %v", err)
+				r.removeDir(dir)
+				return
+			}
+			r.started(gen, cmd.Process, dir)
+			// Every run is either superseded, killed when it
+			// starts or cancelled.
+			r.cancel()
+			err = cmd.Wait()
+			r.finished(gen, err, cmd.ProcessState)
+			// The directory is left for removeStale.
+			mu.Lock()
+			dirs = append(dirs, dir)
+			mu.Unlock()
+		}()
+		go func() {
+			defer wg.Done()
+			r.cancel()
+			r.running()
+			r.tempDirs()
+		}()
+	}
+	wg.Wait()
+
+	if r.running() {
+		t.Error("process still recorded as running")
+	}
+	if err := r.removeStale(true); err != nil {
+		t.Errorf("unexpected error removing directories: %v", err)
+	}
+	if tracked, _ := r.tempDirs(); len(tracked) != 0 {
+		t.Errorf("directories still tracked: %q", tracked)
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("directory not removed: %s: %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/sys/execabs"
+	. "modernc.org/tk9.0"
+)
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// version returns the main module version of the Go executable at path,
+// or "unknown".
+func version(path string) string {
+	path, err := execabs.LookPath(path)
+	if err != nil {
+		return "unknown"
+	}
+	bi, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+	return bi.Main.Version
+}
+
+// shellScript returns a POSIX shell script that reproduces a run of the
+// current inputs. The inputs are written to a temporary directory by the
+// script and mito is run with the same flags miko would use.
+func (m *miko) shellScript() (string, error) {
+	src := m.src.Text()
+	if src == "" {
+		return "", errNoProgram
+	}
+	data, err := withParams(m.data.Text(), m.params.Text())
+	if err != nil {
+		return "", err
+	}
+	mikoVersion := "unknown"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		mikoVersion = bi.Main.Version
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "#!/bin/sh\n# Reproduces a miko run.\n# miko %s, mito %s\nset -e\n", mikoVersion, version(m.mitoPath))
+	buf.WriteString("dir=$(mktemp -d)\ntrap 'rm -rf \"$dir\"' EXIT\n")
+	file := func(name, content string) string {
+		fmt.Fprintf(&buf, "printf '%%s' %s > \"$dir/%s\"\n", shellQuote(content), name)
+		return fmt.Sprintf(`"$dir/%s"`, name)
+	}
+	var args []string
+	if data != "" {
+		args = append(args, "-data", file("data.json", data))
+	}
+	if cfg := m.cfg.Text(); cfg != "" {
+		args = append(args, "-cfg", file("cfg.yml", cfg))
+	}
+	for _, a := range m.runFlags(runOptions{}) {
+		args = append(args, shellQuote(a))
+	}
+	args = append(args, file("src.cel", src))
+	if stdin := m.stdin.Text(); stdin != "" {
+		args = append(args, "<", file("stdin.txt", stdin))
+	}
+	if m.config.WorkDir != "" {
+		fmt.Fprintf(&buf, "cd %s\n", shellQuote(m.config.WorkDir))
+	} else {
+		buf.WriteString("cd \"$dir\"\n")
+	}
+	fmt.Fprintf(&buf, "%s %s\n", shellQuote(m.mitoPath), strings.Join(args, " "))
+	return buf.String(), nil
+}
+
+// exportScript saves a shell script reproducing a run of the current
+// inputs to a file chosen by the user.
+func (m *miko) exportScript() {
+	script, err := m.shellScript()
+	if err != nil {
+		m.printError(fmt.Errorf("exporting script: %w", err))
+		return
+	}
+	path := m.getSaveFile(Title("Export Shell Script"), Defaultextension(".sh"))
+	if path == "" {
+		return
+	}
+	err = os.WriteFile(path, []byte(script), 0o755)
+	if err != nil {
+		m.printError(fmt.Errorf("exporting script: %w", err))
+	}
+}
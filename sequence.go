@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// sequencer merges text from several streams in the order in which it
+// was read from the underlying readers. Each read is stamped from a
+// shared counter and text sent on a stream carries the stamp of the
+// stream's most recent read, which is the read that completed it.
+//
+// Text is held until its order is certain: every other stream must
+// either hold later text, be closed, or be blocked in a read, since any
+// text it produces after that will be stamped by a later read.
+type sequencer struct {
+	mu      sync.Mutex
+	stamp   uint64
+	streams []*seqStream
+	emit    func(text)
+}
+
+// seqStream is an io.Reader belonging to a sequencer.
+type seqStream struct {
+	s       *sequencer
+	r       io.Reader
+	last    uint64 // Stamp of the most recent read.
+	reading bool
+	closed  bool
+	pending []stampedText
+}
+
+type stampedText struct {
+	stamp uint64
+	text  text
+}
+
+// newSequencer returns a sequencer that calls emit with text in read
+// order. emit is called from the goroutines using the sequencer's
+// streams.
+func newSequencer(emit func(text)) *sequencer {
+	return &sequencer{emit: emit}
+}
+
+// stream returns a new stream reading from r. All streams must be
+// created before any are read.
+func (s *sequencer) stream(r io.Reader) *seqStream {
+	st := &seqStream{s: s, r: r}
+	s.streams = append(s.streams, st)
+	return st
+}
+
+func (st *seqStream) Read(p []byte) (int, error) {
+	st.s.mu.Lock()
+	st.reading = true
+	st.s.flush()
+	st.s.mu.Unlock()
+
+	n, err := st.r.Read(p)
+
+	st.s.mu.Lock()
+	st.reading = false
+	st.s.stamp++
+	st.last = st.s.stamp
+	st.s.mu.Unlock()
+	return n, err
+}
+
+// send queues t for emission in read order.
+func (st *seqStream) send(t text) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	st.pending = append(st.pending, stampedText{stamp: st.last, text: t})
+	st.s.flush()
+}
+
+// close marks the stream as finished, releasing any text held waiting
+// for it.
+func (st *seqStream) close() {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	st.closed = true
+	st.s.flush()
+}
+
+// flush emits held text whose order is certain. It must be called with
+// s.mu held.
+func (s *sequencer) flush() {
+	for {
+		var next *seqStream
+		for _, st := range s.streams {
+			if len(st.pending) != 0 && (next == nil || st.pending[0].stamp < next.pending[0].stamp) {
+				next = st
+			}
+		}
+		if next == nil {
+			return
+		}
+		for _, st := range s.streams {
+			if st != next && len(st.pending) == 0 && !st.reading && !st.closed {
+				return
+			}
+		}
+		t := next.pending[0].text
+		next.pending = next.pending[1:]
+		s.emit(t)
+	}
+}
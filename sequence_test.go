@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// TestSequencer checks that text read from interleaved writes to two
+// streams is emitted in the order the reads were stamped, even when the
+// stream that read later sends its text first.
+func TestSequencer(t *testing.T) {
+	type write struct {
+		stream int
+		line   string
+	}
+	// Within a batch the writes are read in order, one per stream, and
+	// the text of each read is then sent in the reverse order.
+	batches := [][]write{
+		{{0, "out 1"}, {1, "err 1"}},
+		{{1, "err 2"}, {0, "out 2"}},
+		{{1, "err 3"}},
+		{{0, "out 3"}, {1, "err 4"}},
+		{{0, "out 4"}},
+		{{1, "err 5"}, {0, "out 5"}},
+	}
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	seq := newSequencer(func(t text) {
+		mu.Lock()
+		got = append(got, t.data)
+		mu.Unlock()
+	})
+	var (
+		pipes   [2]*io.PipeWriter
+		streams [2]*seqStream
+		read    [2]chan struct{} // A line has been read.
+		release [2]chan struct{} // The line read may be sent.
+		wg      sync.WaitGroup
+	)
+	// All streams are created before any are read.
+	for i := range pipes {
+		var r *io.PipeReader
+		r, pipes[i] = io.Pipe()
+		streams[i] = seq.stream(r)
+		read[i] = make(chan struct{})
+		release[i] = make(chan struct{})
+	}
+	for i, st := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer st.close()
+			sc := bufio.NewScanner(st)
+			for sc.Scan() {
+				read[i] <- struct{}{}
+				<-release[i]
+				st.send(text{data: sc.Text()})
+			}
+		}()
+	}
+	var want []string
+	for _, b := range batches {
+		for _, w := range b {
+			io.WriteString(pipes[w.stream], w.line+"\n")
+			<-read[w.stream]
+			want = append(want, w.line)
+		}
+		for i := len(b) - 1; i >= 0; i-- {
+			release[b[i].stream] <- struct{}{}
+		}
+	}
+	for _, p := range pipes {
+		p.Close()
+	}
+	wg.Wait()
+
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected order:\ngot:  %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+	. "modernc.org/tk9.0"
+)
+
+// sessionFiles are the names of the files of a session, as used in
+// txtar archives by archive and load.
+var sessionFiles = []string{"src.cel", "data.json", "cfg.yaml", "stdin.txt", "params.txt", "mock.yaml", "out.json"}
+
+// explodeSession writes the files of ar to dir, creating it if needed.
+// Session files that are not in ar are removed from dir so that
+// collapsing it gives ar again.
+func explodeSession(dir string, ar *txtar.Archive) error {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return err
+	}
+	written := make(map[string]bool)
+	for _, f := range ar.Files {
+		err := os.WriteFile(filepath.Join(dir, f.Name), f.Data, 0o600)
+		if err != nil {
+			return err
+		}
+		written[f.Name] = true
+	}
+	for _, name := range sessionFiles {
+		if written[name] {
+			continue
+		}
+		err := os.Remove(filepath.Join(dir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// collapseSession returns an archive of the session files in dir.
+// Missing files are left out, but dir must hold at least one.
+func collapseSession(dir string) (*txtar.Archive, error) {
+	var ar txtar.Archive
+	for _, name := range sessionFiles {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ar.Files = append(ar.Files, txtar.File{Name: name, Data: b})
+	}
+	if len(ar.Files) == 0 {
+		return nil, fmt.Errorf("no session files in %s", dir)
+	}
+	return &ar, nil
+}
+
+// exportDir writes the inputs and output to files in a directory chosen
+// by the user.
+func (m *miko) exportDir() {
+	dir := ChooseDirectory(Title("Export to Directory"), Initialdir(m.config.SaveDir))
+	if dir == "" {
+		return
+	}
+	err := explodeSession(dir, m.archive(true))
+	if err != nil {
+		m.printError(fmt.Errorf("exporting to directory: %w", err))
+		return
+	}
+	m.setModified(false)
+	m.announce("Exported to " + dir)
+}
+
+// importDir replaces the inputs with the files in a directory chosen by
+// the user. The output file of an exported session is not loaded.
+func (m *miko) importDir() {
+	dir := ChooseDirectory(Title("Import from Directory"), Initialdir(m.config.SaveDir), Mustexist(true))
+	if dir == "" {
+		return
+	}
+	ar, err := collapseSession(dir)
+	if err != nil {
+		m.printError(fmt.Errorf("importing from directory: %w", err))
+		return
+	}
+	if modified := m.modified(); len(modified) != 0 {
+		ok := MessageBox(
+			Parent(App),
+			Icon("question"),
+			Type("okcancel"),
+			Title("Import from Directory"),
+			Msg(fmt.Sprintf("Replace unsaved changes in %s?", strings.Join(modified, ", "))),
+		)
+		if ok != "ok" {
+			return
+		}
+	}
+	for _, p := range m.panes {
+		p.text.Delete("1.0", "end")
+	}
+	m.load(ar)
+	m.setModified(false)
+	m.announce("Imported from " + dir)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/execabs"
+	. "modernc.org/tk9.0"
+)
+
+// showSettings shows the Settings dialog. Changes are validated and
+// applied when OK or Apply is pressed, and are then saved to the
+// configuration file.
+func (m *miko) showSettings() {
+	top := App.Toplevel()
+	top.WmTitle("Settings")
+	GridRowConfigure(top.Window, 0, Weight(1))
+	GridColumnConfigure(top.Window, 0, Weight(1))
+	nb := top.TNotebook()
+	Grid(nb, Row(0), Column(0), Sticky("news"), Padx("4"), Pady("4"))
+
+	// row adds a labelled control to the next row of a settings tab.
+	row := func(tab *TFrameWidget, lbl string, w Widget) {
+		r := len(GridSlaves(tab.Window, Column(0)))
+		Grid(tab.TLabel(Txt(lbl)), Row(r), Column(0), Sticky("w"), Padx("4"), Pady("2"))
+		Grid(w, Row(r), Column(1), Sticky("ew"), Padx("4"), Pady("2"))
+		GridColumnConfigure(tab.Window, 1, Weight(1))
+	}
+	check := func(tab *TFrameWidget, lbl string, v bool) *TCheckbuttonWidget {
+		w := tab.TCheckbutton(Txt(lbl), Variable(btoi(v)))
+		r := len(GridSlaves(tab.Window, Column(0)))
+		Grid(w, Row(r), Column(0), Columnspan(2), Sticky("w"), Padx("4"), Pady("2"))
+		return w
+	}
+
+	run := nb.TFrame(Padding("8"))
+	nb.Add(run.Window, Txt("Run"))
+	mito := run.TEntry(Textvariable(m.mitoPath))
+	row(run, "mito", mito)
+	workDir := run.TEntry(Textvariable(m.config.WorkDir))
+	row(run, "Working directory", workDir)
+	dump := run.TCombobox(Values(dumpModes), State("readonly"), Textvariable(m.dumpMode()))
+	row(run, "Dump", dump)
+	insecure := check(run, "Insecure HTTPS", m.config.Insecure)
+	logRequests := check(run, "Log requests", m.config.LogRequests)
+	hexBinary := check(run, "Render binary as hex", m.config.HexBinary)
+	profile := check(run, "Write a CPU profile of each run (slower)", m.config.Profile)
+	largeData := "off"
+	if n := m.config.largeData(); n > 0 {
+		largeData = strconv.Itoa(n)
+	}
+	largeDataEntry := run.TEntry(Textvariable(largeData))
+	row(run, "Large data warning (bytes)", largeDataEntry)
+	notifyAfter := "off"
+	if d := m.config.notifyAfter(); d > 0 {
+		notifyAfter = d.String()
+	}
+	notifyEntry := run.TEntry(Textvariable(notifyAfter))
+	row(run, "Notify when a run takes", notifyEntry)
+	notify := run.TCombobox(Values(notifyMethods), State("readonly"), Textvariable(m.notifyMethod()))
+	row(run, "Notify with", notify)
+
+	editor := nb.TFrame(Padding("8"))
+	nb.Add(editor.Window, Txt("Editor"))
+	celfmt := editor.TEntry(Textvariable(m.celfmtPath))
+	row(editor, "celfmt", celfmt)
+	tabWidth := editor.TEntry(Textvariable(strconv.Itoa(m.editorFont.tw)))
+	row(editor, "Tab width", tabWidth)
+	indentWidth := editor.TEntry(Textvariable(strconv.Itoa(m.indentWidth())))
+	row(editor, "Indent width", indentWidth)
+	expandTabs := check(editor, "Insert spaces for tab", m.config.ExpandTabs)
+	keepSpace := check(editor, "Keep trailing white space on format", m.config.KeepTrailingSpace)
+	trimSave := check(editor, "Remove trailing white space on save", m.config.TrimOnSave)
+	formatSnarf := check(editor, "Offer to format unformatted inputs before Snarf", m.config.FormatBeforeSnarf)
+	interval := "off"
+	if m.draft.interval > 0 {
+		interval = m.draft.interval.String()
+	}
+	autosave := editor.TEntry(Textvariable(interval))
+	row(editor, "Auto-save interval", autosave)
+	hover := "off"
+	if d := m.config.hoverDelay(); d > 0 {
+		hover = d.String()
+	}
+	hoverEntry := editor.TEntry(Textvariable(hover))
+	row(editor, "Function help on hover after", hoverEntry)
+
+	display := nb.TFrame(Padding("8"))
+	nb.Add(display.Window, Txt("Display"))
+	runOutput := display.TCombobox(Values(outputModes), State("readonly"), Textvariable(m.outputMode()))
+	row(display, "Output between runs", runOutput)
+	poll := display.TEntry(Textvariable(m.poll.String()))
+	row(display, "Refresh poll rate", poll)
+	Grid(display.TLabel(Txt("Changes to the poll rate apply after restart.")),
+		Row(2), Column(0), Columnspan(2), Sticky("w"), Padx("4"), Pady("2"))
+	largeTargets := check(display, "Larger buttons and padding", m.config.LargeTargets)
+
+	// The values that may have been given by command line flags are
+	// saved only if they are changed here.
+	was := settings{mito: m.mitoPath, celfmt: m.celfmtPath, tabWidth: m.editorFont.tw, poll: m.poll}
+	apply := func() bool {
+		var s settings
+		var err error
+		s.mito, err = executable("mito", mito.Textvariable())
+		if err == nil {
+			s.celfmt, err = executable("celfmt", celfmt.Textvariable())
+		}
+		if err == nil {
+			s.workDir, err = directory(workDir.Textvariable())
+		}
+		if err == nil {
+			s.tabWidth, err = positive("tab width", tabWidth.Textvariable())
+		}
+		if err == nil {
+			s.indentWidth, err = positive("indent width", indentWidth.Textvariable())
+		}
+		if err == nil {
+			s.poll, err = duration("poll rate", poll.Textvariable())
+		}
+		if err == nil {
+			s.autosave, err = autosaveInterval(autosave.Textvariable())
+		}
+		if err == nil {
+			s.largeData, err = largeDataThreshold(largeDataEntry.Textvariable())
+		}
+		if err == nil {
+			s.hoverDelay, err = hoverDelay(hoverEntry.Textvariable())
+		}
+		if err == nil {
+			s.notifyAfter, err = notifyThreshold(notifyEntry.Textvariable())
+		}
+		if err != nil {
+			MessageBox(Parent(top), Icon("error"), Title("Settings"), Msg(err.Error()))
+			return false
+		}
+		s.dump = dump.Textvariable()
+		s.runOutput = runOutput.Textvariable()
+		s.notify = notify.Textvariable()
+		s.insecure = insecure.Variable() == "1"
+		s.logRequests = logRequests.Variable() == "1"
+		s.hexBinary = hexBinary.Variable() == "1"
+		s.profile = profile.Variable() == "1"
+		s.expandTabs = expandTabs.Variable() == "1"
+		s.keepSpace = keepSpace.Variable() == "1"
+		s.trimSave = trimSave.Variable() == "1"
+		s.formatSnarf = formatSnarf.Variable() == "1"
+		s.largeTargets = largeTargets.Variable() == "1"
+		m.applySettings(s, was)
+		return true
+	}
+
+	buttons := top.TFrame()
+	Grid(buttons, Row(1), Column(0), Sticky("e"), Padx("4"), Pady("4"))
+	ok := buttons.TButton(Txt("OK"), Command(func() {
+		if apply() {
+			Destroy(top)
+		}
+	}))
+	cancel := buttons.TButton(Txt("Cancel"), Command(func() { Destroy(top) }))
+	Grid(ok, buttons.TButton(Txt("Apply"), Command(func() { apply() })), cancel, Row(0), Padx("2"))
+	m.dialog(top)
+}
+
+// settings holds the validated values from the Settings dialog.
+type settings struct {
+	mito, celfmt string
+	workDir      string
+	dump         string
+	runOutput    string
+	poll         time.Duration
+	autosave     time.Duration
+	hoverDelay   time.Duration
+	notifyAfter  time.Duration
+	notify       string
+	tabWidth     int
+	indentWidth  int
+	largeData    int
+
+	insecure, logRequests, hexBinary bool
+	profile                          bool
+	expandTabs, keepSpace, trimSave  bool
+	formatSnarf                      bool
+	largeTargets                     bool
+}
+
+// applySettings applies s to the running application and saves it to
+// the configuration file. The values of s that may have been given by
+// command line flags are saved only where they differ from was.
+func (m *miko) applySettings(s, was settings) {
+	m.mitoPath = s.mito
+	m.celfmtPath = s.celfmt
+	m.config.saveChanged(s, was)
+	m.config.WorkDir = s.workDir
+	m.config.Dump = s.dump
+	m.config.RunOutput = s.runOutput
+	m.config.Insecure = s.insecure
+	m.config.LogRequests = s.logRequests
+	m.config.HexBinary = s.hexBinary
+	m.config.Profile = s.profile
+	m.config.ExpandTabs = s.expandTabs
+	m.config.KeepTrailingSpace = s.keepSpace
+	m.config.TrimOnSave = s.trimSave
+	m.config.FormatBeforeSnarf = s.formatSnarf
+	m.config.IndentWidth = s.indentWidth
+	m.config.Autosave = s.autosave
+	m.config.HoverDelay = s.hoverDelay
+	m.config.NotifyAfter = s.notifyAfter
+	m.config.Notify = s.notify
+	m.config.LargeData = s.largeData
+	if s.largeTargets != m.config.LargeTargets {
+		m.config.LargeTargets = s.largeTargets
+		m.applyTargets()
+	}
+	m.draft.interval = s.autosave
+	if s.tabWidth != m.editorFont.tw {
+		m.setTabWidth(s.tabWidth)
+	}
+	m.sync()
+	m.saveConfig()
+}
+
+// saveChanged sets the mito and celfmt paths, tab width and poll rate of
+// c from s where they differ from was, the values in use when the
+// Settings dialog was opened. Those values may have been given by
+// command line flags for the session, and are not made the saved
+// defaults unless they are changed in the dialog.
+func (c *config) saveChanged(s, was settings) {
+	if s.mito != was.mito {
+		c.Mito = s.mito
+	}
+	if s.celfmt != was.celfmt {
+		c.Celfmt = s.celfmt
+	}
+	if s.tabWidth != was.tabWidth {
+		c.TabWidth = s.tabWidth
+	}
+	if s.poll != was.poll {
+		c.PollRate = s.poll
+	}
+}
+
+// setTabWidth changes the width of tab stops in the editors and the
+// display, and redraws the views that depend on it.
+func (m *miko) setTabWidth(tw int) {
+	m.editorFont.setTabWidth(tw)
+	m.displayFont.setTabWidth(tw)
+	m.minimap.tw = tw
+	m.minimap.dirty = true
+	m.guides.tw = tw
+	for w := range m.guides.dirty {
+		m.guides.dirty[w] = true
+	}
+}
+
+// executable returns the path to the named executable, or an error if
+// it cannot be found.
+func executable(name, path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		path = name
+	}
+	_, err := execabs.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return path, nil
+}
+
+// directory returns path if it is empty or an existing directory.
+func directory(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("working directory: %w", err)
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("working directory: %s is not a directory", path)
+	}
+	return path, nil
+}
+
+// positive parses s as a positive integer.
+func positive(name, s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+// duration parses s as a positive duration.
+func duration(name, s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if d <= 0 {
+		return 0, errors.New(name + " must be positive")
+	}
+	return d, nil
+}
+
+// hoverDelay parses s as a hover help delay. A delay of "off" or zero
+// disables hover help and is returned as a negative duration.
+func hoverDelay(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return -1, nil
+	}
+	return duration("hover delay", s)
+}
+
+// notifyThreshold parses s as the shortest run whose end is notified.
+// A threshold of "off" or zero disables notifications and is returned as
+// a negative duration.
+func notifyThreshold(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return -1, nil
+	}
+	return duration("notification threshold", s)
+}
+
+// autosaveInterval parses s as an auto-save interval. An interval of
+// "off" or zero disables auto-save and is returned as a negative
+// duration.
+func autosaveInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return -1, nil
+	}
+	return duration("auto-save interval", s)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// flagged are the values in use when the Settings dialog is opened,
+// given by command line flags.
+var flagged = settings{mito: "/tmp/mito", celfmt: "/tmp/celfmt", tabWidth: 8, poll: 5 * time.Millisecond}
+
+var saveChangedTests = []struct {
+	name string
+	s    func(*settings)
+	want config
+}{
+	{
+		name: "unchanged",
+		s:    func(s *settings) {},
+		want: config{Mito: "mito", Celfmt: "celfmt", TabWidth: 4, PollRate: 10 * time.Millisecond},
+	},
+	{
+		name: "mito",
+		s:    func(s *settings) { s.mito = "/usr/local/bin/mito" },
+		want: config{Mito: "/usr/local/bin/mito", Celfmt: "celfmt", TabWidth: 4, PollRate: 10 * time.Millisecond},
+	},
+	{
+		name: "all",
+		s: func(s *settings) {
+			s.mito, s.celfmt, s.tabWidth, s.poll = "mito2", "celfmt2", 2, time.Second
+		},
+		want: config{Mito: "mito2", Celfmt: "celfmt2", TabWidth: 2, PollRate: time.Second},
+	},
+}
+
+// TestSaveChanged checks that values given by flags for a session are
+// not saved as the defaults unless they are changed in the dialog.
+func TestSaveChanged(t *testing.T) {
+	for _, test := range saveChangedTests {
+		t.Run(test.name, func(t *testing.T) {
+			c := config{Mito: "mito", Celfmt: "celfmt", TabWidth: 4, PollRate: 10 * time.Millisecond}
+			s := flagged
+			test.s(&s)
+			c.saveChanged(s, flagged)
+			if c.Mito != test.want.Mito || c.Celfmt != test.want.Celfmt || c.TabWidth != test.want.TabWidth || c.PollRate != test.want.PollRate {
+				t.Errorf("unexpected configuration:\ngot:  mito=%q celfmt=%q tw=%d poll=%v\nwant: mito=%q celfmt=%q tw=%d poll=%v",
+					c.Mito, c.Celfmt, c.TabWidth, c.PollRate,
+					test.want.Mito, test.want.Celfmt, test.want.TabWidth, test.want.PollRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// shareScheme is the prefix of share links.
+const shareScheme = "miko://"
+
+// maxShareLink is the length above which a share link is refused as too
+// long to be practical to paste into chat or scan as a QR code. The
+// largest QR code holds 2953 bytes.
+const maxShareLink = 2000
+
+// maxShareArchive limits the size of an imported archive so that a
+// crafted link cannot exhaust memory when decompressed.
+const maxShareArchive = 1 << 20
+
+// shareLink returns ar compressed and encoded as a miko:// link.
+func shareLink(ar *txtar.Archive) (string, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	_, err = w.Write(txtar.Format(ar))
+	if err != nil {
+		return "", err
+	}
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+	return shareScheme + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// parseShareLink returns the archive encoded in a miko:// link.
+func parseShareLink(link string) (*txtar.Archive, error) {
+	payload, ok := strings.CutPrefix(strings.TrimSpace(link), shareScheme)
+	if !ok {
+		return nil, fmt.Errorf("not a %s link", shareScheme)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link: %w", err)
+	}
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	data, err = io.ReadAll(io.LimitReader(r, maxShareArchive+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid link: %w", err)
+	}
+	if len(data) > maxShareArchive {
+		return nil, errors.New("invalid link: archive too large")
+	}
+	return txtar.Parse(data), nil
+}
+
+// share shows a link and QR code holding the inputs, which can be
+// opened by another miko with Import from Link. The output is not
+// included since it can be regenerated by running the inputs.
+func (m *miko) share() {
+	link, err := shareLink(m.archive(false))
+	if err != nil {
+		m.printError(fmt.Errorf("creating share link: %w", err))
+		return
+	}
+	if len(link) > maxShareLink {
+		MessageBox(
+			Parent(App),
+			Icon("warning"),
+			Title("Share Link"),
+			Msg("The inputs are too large to share as a link."),
+			Detail(fmt.Sprintf("The link would be %d characters long; the limit is %d. Use Snarf or Save instead.", len(link), maxShareLink)),
+		)
+		return
+	}
+
+	top := App.Toplevel()
+	top.WmTitle("Share Link")
+	WmTransient(top, App)
+	GridColumnConfigure(top.Window, 0, Weight(1))
+	entry := top.TEntry(Textvariable(link), Width(60))
+	entry.Configure(State("readonly"))
+	Grid(entry, Row(0), Column(0), Sticky("ew"), Padx("4"), Pady("4"))
+	Grid(top.TButton(Txt("Copy"), Command(func() {
+		ClipboardClear()
+		ClipboardAppend(link)
+	})), Row(0), Column(1), Padx("4"), Pady("4"))
+	if q, err := qrEncode([]byte(link)); err == nil {
+		var buf bytes.Buffer
+		err = png.Encode(&buf, q.image(max(2, 400/q.size)))
+		if err == nil {
+			Grid(top.TLabel(Image(NewPhoto(Data(buf.Bytes())))), Row(1), Column(0), Columnspan(2), Pady("4"))
+		}
+	}
+	m.dialog(top)
+	Focus(entry)
+}
+
+// snarf copies the inputs and the output to the clipboard as a txtar
+// archive. If configured, the user is first offered to format a program
+// or data that is not formatted.
+func (m *miko) snarf() {
+	if m.config.FormatBeforeSnarf {
+		if names := m.unformatted(); len(names) != 0 {
+			switch MessageBox(
+				Parent(App),
+				Icon("question"),
+				Type("yesnocancel"),
+				Title("Snarf"),
+				Msg("Format before copying?"),
+				Detail(fmt.Sprintf("The %s pane is not formatted. Formatting can be undone.", strings.Join(names, " and "))),
+			) {
+			case "yes":
+				m.format()
+			case "cancel":
+				return
+			}
+		}
+	}
+	ClipboardClear()
+	ClipboardAppend(string(txtar.Format(m.archive(true))))
+}
+
+// importLink shows a dialog to replace the inputs with those from a
+// miko:// link, starting with the clipboard if it holds one.
+func (m *miko) importLink() {
+	var initial string
+	if clip, err := Eval("clipboard get"); err == nil && strings.HasPrefix(strings.TrimSpace(clip), shareScheme) {
+		initial = strings.TrimSpace(clip)
+	}
+
+	top := App.Toplevel()
+	top.WmTitle("Import from Link")
+	WmTransient(top, App)
+	GridColumnConfigure(top.Window, 0, Weight(1))
+	entry := top.TEntry(Textvariable(initial), Width(60))
+	message := top.TLabel()
+	imp := func() {
+		ar, err := parseShareLink(entry.Textvariable())
+		if err != nil {
+			message.Configure(Txt(err.Error()))
+			return
+		}
+		if modified := m.modified(); len(modified) != 0 {
+			ok := MessageBox(
+				Parent(top),
+				Icon("question"),
+				Type("okcancel"),
+				Title("Import from Link"),
+				Msg(fmt.Sprintf("Replace unsaved changes in %s?", strings.Join(modified, ", "))),
+			)
+			if ok != "ok" {
+				return
+			}
+		}
+		Destroy(top)
+		for _, p := range m.panes {
+			p.text.Delete("1.0", "end")
+		}
+		m.load(ar)
+	}
+	Grid(entry, Row(0), Column(0), Sticky("ew"), Padx("4"), Pady("4"))
+	Grid(top.TButton(Txt("Import"), Command(imp)), Row(0), Column(1), Padx("4"), Pady("4"))
+	Grid(message, Row(1), Column(0), Columnspan(2), Sticky("w"), Padx("4"))
+	Bind(entry, "<Return>", Command(imp))
+	m.dialog(top)
+	Focus(entry)
+}
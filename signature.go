@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// callAt returns the name of the innermost call that is open at the end
+// of src, the offset in src of its opening parenthesis, and the index of
+// the argument being written. Brackets and commas in strings and
+// comments are ignored.
+func callAt(src string) (name string, open, arg int, ok bool) {
+	type frame struct {
+		c      byte // The opening bracket.
+		at     int  // Offset of the bracket.
+		commas int
+	}
+	var stack []frame
+	for i := 0; i < len(src); {
+		c := src[i]
+		n := 1
+		switch c {
+		case '/':
+			if strings.HasPrefix(src[i:], "//") {
+				n = strings.IndexByte(src[i:], '\n')
+				if n < 0 {
+					n = len(src) - i
+				}
+			}
+		case '"', '\'':
+			raw := i > 0 && (src[i-1] == 'r' || src[i-1] == 'R')
+			quote := string(c)
+			if strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			j := i + len(quote)
+			for j < len(src) && !strings.HasPrefix(src[j:], quote) {
+				if src[j] == '\\' && !raw {
+					j++
+				}
+				j++
+			}
+			n = min(j+len(quote), len(src)) - i
+		case '{', '[', '(':
+			stack = append(stack, frame{c: c, at: i})
+		case '}', ']', ')':
+			if len(stack) != 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if len(stack) != 0 {
+				stack[len(stack)-1].commas++
+			}
+		default:
+			_, n = utf8.DecodeRuneInString(src[i:])
+		}
+		i += n
+	}
+	// Arguments that are lists or maps belong to the call around them.
+	for i := len(stack) - 1; i >= 0; i-- {
+		f := stack[i]
+		if f.c != '(' {
+			continue
+		}
+		before := strings.TrimRight(src[:f.at], " \t\n")
+		start := len(before)
+		for start > 0 && isIdent("a"+before[start-1:start]) {
+			start--
+		}
+		if start == len(before) || !isIdent(before[start:]) {
+			return "", 0, 0, false
+		}
+		return before[start:], f.at, f.commas, true
+	}
+	return "", 0, 0, false
+}
+
+// params returns the offsets in sig of the parameters of a signature
+// of the form "recv.name(a, b) -> r".
+func params(sig string) [][2]int {
+	open := strings.IndexByte(sig, '(')
+	close := strings.LastIndex(sig, ")")
+	if open < 0 || close <= open+1 {
+		return nil
+	}
+	var offsets [][2]int
+	start := open + 1
+	depth := 0
+	for i := start; i < close; i++ {
+		switch sig[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				offsets = append(offsets, [2]int{start, i})
+				start = i + 2
+			}
+		}
+	}
+	return append(offsets, [2]int{start, close})
+}
+
+// signature shows the parameters of the mito function whose call is
+// being written in the src pane, with the current argument highlighted.
+type signature struct {
+	tip       *tip
+	index     string // Insertion cursor at the last update.
+	dirty     bool   // The src pane has changed since the last update.
+	dismissed int    // Offset of the call dismissed with Escape, or -1.
+	shown     int    // Offset of the call shown, or -1.
+	remove    func() // Removes the tip from the overlays.
+}
+
+// trackSignature follows edits and cursor movement in the src pane for
+// signature help.
+func (m *miko) trackSignature() {
+	s := &signature{tip: m.newTip(), dismissed: -1, shown: -1, dirty: true}
+	s.tip.text.TagConfigure("currentArg", Underline(true))
+	m.signature = s
+	m.onChange(m.src, func() { s.dirty = true })
+}
+
+// hideSignature hides the signature help.
+func (m *miko) hideSignature() {
+	s := m.signature
+	if s.remove != nil {
+		s.remove()
+		s.remove = nil
+	}
+	s.shown = -1
+	s.tip.hide()
+}
+
+// updateSignature shows or hides the signature help after the src pane
+// is edited or its insertion cursor moves.
+func (m *miko) updateSignature() {
+	s := m.signature
+	if EvalErr("focus") != m.src.String() {
+		if s.shown >= 0 {
+			m.hideSignature()
+		}
+		return
+	}
+	index := m.src.Index("insert")
+	if !s.dirty && index == s.index {
+		return
+	}
+	s.dirty = false
+	s.index = index
+	name, open, arg, ok := callAt(strings.Join(m.src.Get("1.0", "insert"), ""))
+	if !ok || open != s.dismissed {
+		s.dismissed = -1
+	}
+	var funcs []mitoFunc
+	if ok {
+		funcs = lookupFunc(name)
+	}
+	if len(funcs) == 0 || open == s.dismissed {
+		m.hideSignature()
+		return
+	}
+	var lines []string
+	for _, f := range funcs {
+		if arg >= len(params(f.sig)) && len(funcs) > 1 {
+			// Overloads with too few parameters are not candidates.
+			continue
+		}
+		lines = append(lines, f.sig)
+	}
+	if len(lines) == 0 {
+		m.hideSignature()
+		return
+	}
+	bbox := strings.Fields(EvalErr(fmt.Sprintf("%s bbox insert", m.src)))
+	if len(bbox) != 4 {
+		m.hideSignature()
+		return
+	}
+	x, _ := strconv.Atoi(bbox[0])
+	y, _ := strconv.Atoi(bbox[1])
+	h, _ := strconv.Atoi(bbox[3])
+	rootX, _ := strconv.Atoi(EvalErr("winfo rootx " + m.src.String()))
+	rootY, _ := strconv.Atoi(EvalErr("winfo rooty " + m.src.String()))
+	text := s.tip.show(rootX+x, rootY+y+h+2, strings.Join(lines, "\n"))
+	for i, line := range lines {
+		ps := params(line)
+		if arg < len(ps) {
+			p := ps[arg]
+			text.TagAdd("currentArg",
+				fmt.Sprintf("%d.%d", i+1, utf8.RuneCountInString(line[:p[0]])),
+				fmt.Sprintf("%d.%d", i+1, utf8.RuneCountInString(line[:p[1]])))
+		}
+	}
+	if s.remove == nil {
+		s.remove = m.pushOverlay(overlayPopup, func() {
+			s.remove = nil
+			s.dismissed = s.shown
+			s.shown = -1
+			s.tip.hide()
+		})
+	}
+	s.shown = open
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	. "modernc.org/tk9.0"
+)
+
+// status is the status bar at the bottom of the main window.
+type status struct {
+	frame  *TFrameWidget
+	cursor *TLabelWidget // Cursor position and size of the focused editor.
+	dirty  bool          // The cursor readout needs updating.
+	bytes  map[*TextWidget]int
+
+	// activity shows that output is arriving from a run.
+	activity  *TLabelWidget
+	events    int       // Results displayed during the current run.
+	errors    int       // Error lines among events.
+	shown     int       // Value of events at the last update.
+	lastEvent time.Time // When events last changed.
+	label     string    // Current text of activity.
+
+	// announce holds the latest message of announce, and failed
+	// records that an error in the current run has been announced.
+	announce *TLabelWidget
+	failed   bool
+}
+
+func (m *miko) newStatus(parent *Window) *status {
+	frame := parent.TFrame()
+	cursor := frame.TLabel(Anchor("e"))
+	Grid(cursor, Row(0), Column(2), Sticky("e"), Padx("4"))
+	// Clicking the readout opens the go to line dialog.
+	Bind(cursor, "<Button-1>", Command(m.goToLine))
+	activity := frame.TLabel(Anchor("w"))
+	Grid(activity, Row(0), Column(0), Sticky("w"), Padx("4"))
+	GridColumnConfigure(frame.Window, 0, Weight(1))
+	announce := frame.TLabel(Anchor("e"))
+	Grid(announce, Row(0), Column(1), Sticky("e"), Padx("4"))
+	return &status{
+		frame:    frame,
+		cursor:   cursor,
+		bytes:    make(map[*TextWidget]int),
+		activity: activity,
+		announce: announce,
+	}
+}
+
+// activityPulse is the period of the activity indicator's pulse, and
+// activityIdle is how long after the last result the pulse stops.
+const (
+	activityPulse = 250 * time.Millisecond
+	activityIdle  = 2 * activityPulse
+)
+
+// updateActivity updates the activity indicator, which counts the
+// results displayed during a run and pulses while they are arriving,
+// and shows the time the run has taken. When the run ends the final
+// count and duration are left without the pulse, with the exit status
+// and, where the system reports it, the peak memory use. The bytes of
+// output copied to a tee file are shown with the count. The first error
+// of a run is announced.
+func (m *miko) updateActivity() {
+	s := m.status
+	running := m.runs.running()
+	if running && s.errors != 0 && !s.failed {
+		s.failed = true
+		m.announce("Run reported an error")
+	}
+	now := time.Now()
+	if s.events != s.shown {
+		s.shown = s.events
+		s.lastEvent = now
+	}
+	count := fmt.Sprintf("%d events", s.events)
+	if s.errors != 0 {
+		count += fmt.Sprintf(", %d errors (F8)", s.errors)
+	}
+	count += m.lintStatus()
+	count += m.teeStatus()
+	var label string
+	switch {
+	case running:
+		dot := "○"
+		if now.Sub(s.lastEvent) < activityIdle && now.UnixMilli()/activityPulse.Milliseconds()%2 == 0 {
+			dot = "●"
+		}
+		label = fmt.Sprintf("%s %s  %s", dot, count, elapsed(m.runs.elapsed()))
+	case m.runs.elapsed() != 0:
+		label = fmt.Sprintf("%s in %s, %s", count, elapsed(m.runs.elapsed()), m.runs.exitStatus())
+		if rss := m.runs.peakRSS(); rss != 0 {
+			label += fmt.Sprintf(", peak RSS %s", byteSize(int(rss)))
+		}
+	}
+	if label != s.label {
+		s.label = label
+		s.activity.Configure(Txt(label))
+	}
+}
+
+// runStarted resets the count of results for a run that has just been
+// started and announces it.
+func (m *miko) runStarted() {
+	m.status.reset()
+	m.announce("Run started")
+}
+
+// reset clears the count of results and errors of the current run.
+func (s *status) reset() {
+	s.events, s.shown, s.errors = 0, 0, 0
+	s.failed = false
+}
+
+// runFinished announces the end of the current run with its exit status
+// and the count of its results.
+func (m *miko) runFinished() {
+	s := m.status
+	m.announce(fmt.Sprintf("Run finished with %s: %d events, %d errors", m.runs.exitStatus(), s.events, s.errors))
+}
+
+// track updates the cursor readout for the editor w on cursor movement
+// and edits. Changes of focus are handled by the editor's <FocusIn>
+// binding.
+func (m *miko) track(w *TextWidget) {
+	mark := func() { m.status.dirty = true }
+	for _, ev := range []string{"<KeyRelease>", "<ButtonRelease-1>"} {
+		Bind(w, ev, Command(mark))
+	}
+	m.onChange(w, func() {
+		delete(m.status.bytes, w)
+		m.status.dirty = true
+	})
+}
+
+// updateStatus redraws the cursor readout if it has changed.
+func (m *miko) updateStatus() {
+	s := m.status
+	if !s.dirty || m.editor == nil {
+		return
+	}
+	s.dirty = false
+	w := m.editor
+	line, col := position(w.Index("insert"))
+	lines, _ := position(w.Index("end-1c"))
+	n, ok := s.bytes[w]
+	if !ok {
+		// Only measure the content after it has changed, since this
+		// requires a copy of the text.
+		n = len(w.Text())
+		s.bytes[w] = n
+	}
+	var name string
+	for _, p := range m.panes {
+		if p.text == w {
+			name = p.name
+		}
+	}
+	s.cursor.Configure(Txt(fmt.Sprintf("%s  Ln %d, Col %d  %d lines, %d bytes", name, line, col+1, lines, n)))
+}
+
+// elapsed formats a run duration to a tenth of a second.
+func elapsed(d time.Duration) string {
+	return d.Truncate(100 * time.Millisecond).String()
+}
+
+// position returns the line and column of a text index of the form
+// line.char.
+func position(index string) (line, col int) {
+	l, c, _ := strings.Cut(index, ".")
+	line, _ = strconv.Atoi(l)
+	col, _ = strconv.Atoi(c)
+	return line, col
+}
+
+// goToLine shows a dialog to move the cursor of the focused editor to a
+// line.
+func (m *miko) goToLine() {
+	w := m.editor
+	if w == nil {
+		return
+	}
+	top := App.Toplevel()
+	top.WmTitle("Go to Line")
+	WmTransient(top, App)
+	line, _ := position(w.Index("insert"))
+	entry := top.TEntry(Textvariable(strconv.Itoa(line)), Width(10))
+	Grid(top.TLabel(Txt("Line")), Row(0), Column(0), Padx("4"), Pady("4"))
+	Grid(entry, Row(0), Column(1), Padx("4"), Pady("4"))
+	Bind(entry, "<Return>", Command(func() {
+		n, err := strconv.Atoi(strings.TrimSpace(entry.Textvariable()))
+		if err != nil {
+			Bell()
+			return
+		}
+		Destroy(top)
+		w.MarkSet("insert", fmt.Sprintf("%d.0", n))
+		w.See("insert")
+		Focus(w)
+		m.status.dirty = true
+	}))
+	m.dialog(top)
+	Focus(entry)
+}
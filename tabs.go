@@ -0,0 +1,391 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// restoreDump is a Tcl procedure that appends the content of a text
+// widget saved with "dump -text -tag" to the widget w, with its tags.
+const restoreDump = `
+proc miko_restoreDump {w dump} {
+	set tags {}
+	foreach {key value index} $dump {
+		switch -- $key {
+			tagon {
+				if {$value ne "sel"} { lappend tags $value }
+			}
+			tagoff {
+				set i [lsearch -exact $tags $value]
+				if {$i >= 0} { set tags [lreplace $tags $i $i] }
+			}
+			text { $w insert end $value $tags }
+		}
+	}
+}
+`
+
+// document is a set of inputs and their output shown in a tab. While a
+// document is not the current tab its inputs are held as an archive and
+// its output as a Tcl dump of the display kept in the mikoTabs array.
+// Each document has its own runs, which carry on while other tabs are
+// shown; their results are held until the document is shown again. The
+// request timings, captured requests, run history and data documents
+// are also the document's own.
+type document struct {
+	id        int
+	name      string
+	inputs    *txtar.Archive
+	modified  []string        // Names of panes with unsaved edits.
+	views     map[string]view // Scroll positions of the panes by name.
+	runs      *runner
+	held      []text // Results of runs received while not current.
+	events    int    // Results counted by the status bar.
+	errors    int
+	waterfall *waterfall
+	captured  *captured
+	history   *runHistory
+	dataDocs  *dataDocs
+}
+
+// maxHeld is the number of results held for a document that is not
+// current. Older results are dropped.
+const maxHeld = defaultMaxOutputLines
+
+// currentID returns the id of the current document.
+func (t *tabs) currentID() int {
+	return t.docs[t.current].id
+}
+
+// hold keeps the result r for its document until the document is
+// shown. Results of closed documents are dropped.
+func (t *tabs) hold(r text) {
+	for _, doc := range t.docs {
+		if doc.id == r.doc {
+			doc.held = append(doc.held, r)
+			if len(doc.held) > maxHeld {
+				doc.held = slices.Delete(doc.held, 0, len(doc.held)-maxHeld)
+			}
+			return
+		}
+	}
+}
+
+// runners returns the runs of every document.
+func (m *miko) runners() []*runner {
+	rs := []*runner{m.runs}
+	for i, doc := range m.tabs.docs {
+		if i != m.tabs.current && doc.runs != nil {
+			rs = append(rs, doc.runs)
+		}
+	}
+	return rs
+}
+
+// tabs is the bar above the input panes for switching between
+// documents.
+type tabs struct {
+	frame   *TFrameWidget
+	widgets []Widget // The current content of frame.
+	docs    []*document
+	current int
+	next    int // Number used to name the next document.
+	ids     int // Last document id used.
+}
+
+func (m *miko) newTabs(parent *Window) *tabs {
+	EvalErr(restoreDump)
+	t := &tabs{frame: parent.TFrame()}
+	t.docs = []*document{t.add()}
+	return t
+}
+
+// add returns a new document with the next name.
+func (t *tabs) add() *document {
+	t.next++
+	t.ids++
+	return &document{id: t.ids, name: fmt.Sprintf("Tab %d", t.next)}
+}
+
+// layoutTabs rebuilds the tab bar, with the current document's button
+// shown pressed.
+func (m *miko) layoutTabs() {
+	t := m.tabs
+	for _, w := range t.widgets {
+		Destroy(w)
+	}
+	t.widgets = nil
+	for i, doc := range t.docs {
+		tab := t.frame.TCheckbutton(
+			Style("Toolbutton"),
+			Txt(doc.name),
+			Variable(btoi(i == t.current)),
+			Command(func() { m.selectTab(slices.Index(t.docs, doc)) }),
+		)
+		t.widgets = append(t.widgets, tab)
+		if len(t.docs) > 1 {
+			t.widgets = append(t.widgets, t.frame.TButton(
+				Style("Toolbutton"),
+				Txt("×"),
+				Command(func() { m.closeTab(slices.Index(t.docs, doc)) }),
+			))
+		}
+	}
+	t.widgets = append(t.widgets, t.frame.TButton(Style("Toolbutton"), Txt("+"), Command(m.newTab)))
+	for i, w := range t.widgets {
+		Grid(w, Row(0), Column(i))
+	}
+}
+
+// newTab opens an empty document in a new tab.
+func (m *miko) newTab() {
+	t := m.tabs
+	t.docs = append(t.docs, t.add())
+	m.selectTab(len(t.docs) - 1)
+}
+
+// selectTab makes document i current, swapping the content of the input
+// panes and the output display. Any run of the previous document carries
+// on in the background.
+func (m *miko) selectTab(i int) {
+	t := m.tabs
+	if i == t.current {
+		m.layoutTabs()
+		return
+	}
+	m.stash(t.docs[t.current])
+	t.current = i
+	m.unstash(t.docs[i])
+	m.layoutTabs()
+}
+
+// stash saves the inputs, output and runs to doc. Results that have not
+// yet been displayed are held for doc, and a Run All batch is abandoned
+// since its remaining runs would start in another tab.
+func (m *miko) stash(doc *document) {
+	m.stopBatch()
+	m.drain()
+	doc.held = append(doc.held, m.held...)
+	m.held = nil
+	doc.runs = m.runs
+	doc.events, doc.errors = m.status.events, m.status.errors
+	doc.waterfall, doc.captured, doc.history = m.waterfall, m.captured, m.history
+	doc.dataDocs = m.dataDocs
+	if m.dataDocs != nil && m.dataDocs.bar != nil {
+		GridRemove(m.dataDocs.bar.Window)
+	}
+	doc.inputs = m.archive(false)
+	doc.modified = m.modified()
+	// Trees are not kept in the dump of the display.
+	m.hideAllTrees()
+	doc.views = make(map[string]view)
+	for _, p := range m.panes {
+		doc.views[p.name] = viewOf(p.text)
+	}
+	EvalErr(fmt.Sprintf("set mikoTabs(%d) [%s dump -text -tag 1.0 end-1c]", doc.id, m.display))
+}
+
+// unstash replaces the inputs and output with those saved in doc.
+func (m *miko) unstash(doc *document) {
+	m.clearErrors()
+	for _, p := range m.panes {
+		p.text.Delete("1.0", "end")
+	}
+	if doc.inputs != nil {
+		m.load(doc.inputs)
+	}
+	for _, p := range m.panes {
+		EvalErr(fmt.Sprintf("%s edit reset", p.text))
+		EvalErr(fmt.Sprintf("%s edit modified %d", p.text, btoi(slices.Contains(doc.modified, p.name))))
+		doc.views[p.name].restore(p.text)
+	}
+	m.clearOutput()
+	m.display.Configure(State("normal"))
+	EvalErr(fmt.Sprintf("if {[info exists mikoTabs(%[1]d)]} {miko_restoreDump %[2]s $mikoTabs(%[1]d); unset mikoTabs(%[1]d)}", doc.id, m.display))
+	m.lockDisplay()
+	m.display.See("end")
+	if doc.runs == nil {
+		doc.runs = new(runner)
+	}
+	m.runs = doc.runs
+	if doc.waterfall == nil {
+		doc.waterfall, doc.captured, doc.history = &waterfall{}, &captured{}, &runHistory{}
+	}
+	// Open Request Waterfall and Run History windows show the
+	// document's own runs.
+	m.waterfall.moveWindow(doc.waterfall)
+	m.history.moveWindow(doc.history)
+	m.waterfall, m.captured, m.history = doc.waterfall, doc.captured, doc.history
+	m.dataDocs = doc.dataDocs
+	if m.dataDocs != nil && m.dataDocs.bar != nil {
+		Grid(m.dataDocs.bar)
+	}
+	// The results that arrived while the document was not shown are
+	// displayed before any still to come.
+	m.held, doc.held = doc.held, nil
+	s := m.status
+	s.events, s.errors, s.shown = doc.events, doc.errors, doc.events
+	s.dirty = true
+}
+
+// closeTab closes document i, asking for confirmation if it has unsaved
+// edits. The last tab cannot be closed.
+func (m *miko) closeTab(i int) {
+	t := m.tabs
+	if len(t.docs) == 1 {
+		Bell()
+		return
+	}
+	doc := t.docs[i]
+	modified := doc.modified
+	if i == t.current {
+		modified = m.modified()
+	}
+	if len(modified) != 0 {
+		ok := MessageBox(
+			Parent(App),
+			Icon("question"),
+			Type("okcancel"),
+			Title("Close Tab"),
+			Msg(fmt.Sprintf("Close %s?", doc.name)),
+			Detail(fmt.Sprintf("Unsaved changes in %s will be lost.", strings.Join(modified, ", "))),
+		)
+		if ok != "ok" {
+			m.layoutTabs()
+			return
+		}
+	}
+	if i == t.current {
+		next := i + 1
+		if next == len(t.docs) {
+			next = i - 1
+		}
+		m.selectTab(next)
+	}
+	// The document's run has nowhere left to show its output.
+	if doc.runs != nil {
+		m.printError(doc.runs.cancel())
+	}
+	if doc.dataDocs != nil && doc.dataDocs.bar != nil {
+		Destroy(doc.dataDocs.bar)
+	}
+	EvalErr(fmt.Sprintf("array unset mikoTabs %d", doc.id))
+	t.docs = slices.Delete(t.docs, i, i+1)
+	if t.current > i {
+		t.current--
+	}
+	m.layoutTabs()
+}
+
+// tabsPath returns the path of the file holding the open tabs between
+// sessions, or the empty string if there is no configuration directory.
+func (m *miko) tabsPath() string {
+	if m.config.path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(m.config.path), "tabs.txtar")
+}
+
+// saveTabs saves the inputs of the open tabs so that they are restored
+// by the next session. Each document's files are held in a directory
+// named by its position, and the comment records the current tab. When
+// only one tab is open the file is removed.
+func (m *miko) saveTabs() {
+	name := m.tabsPath()
+	if name == "" {
+		return
+	}
+	t := m.tabs
+	if len(t.docs) == 1 {
+		err := os.Remove(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			m.printError(fmt.Errorf("removing tabs: %w", err))
+		}
+		return
+	}
+	t.docs[t.current].inputs = m.archive(false)
+	ar := txtar.Archive{Comment: []byte(strconv.Itoa(t.current) + "\n")}
+	for i, doc := range t.docs {
+		// Empty documents are kept by a directory entry.
+		ar.Files = append(ar.Files, txtar.File{Name: strconv.Itoa(i) + "/"})
+		for _, f := range doc.inputs.Files {
+			ar.Files = append(ar.Files, txtar.File{Name: path.Join(strconv.Itoa(i), f.Name), Data: f.Data})
+		}
+	}
+	err := os.WriteFile(name, txtar.Format(&ar), 0o600)
+	if err != nil {
+		m.printError(fmt.Errorf("saving tabs: %w", err))
+	}
+}
+
+// restoreTabs reopens the tabs saved by the previous session.
+func (m *miko) restoreTabs() {
+	name := m.tabsPath()
+	if name == "" {
+		return
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			m.printError(fmt.Errorf("reading tabs: %w", err))
+		}
+		return
+	}
+	inputs, current := savedTabs(txtar.Parse(b))
+	if len(inputs) < 2 {
+		return
+	}
+	t := m.tabs
+	docs := make([]*document, len(inputs))
+	for i, ar := range inputs {
+		docs[i] = t.add()
+		docs[i].name = fmt.Sprintf("Tab %d", i+1)
+		docs[i].inputs = ar
+	}
+	t.next = len(docs)
+	t.docs = docs
+	t.current = current
+	m.unstash(docs[current])
+	m.layoutTabs()
+}
+
+// savedTabs returns the inputs of the tabs saved in ar by saveTabs, and
+// the index of the tab that was current. Each saved tab has a directory
+// entry, so files for tabs beyond the number of entries are skipped
+// rather than opening an unbounded number of tabs for a damaged file.
+func savedTabs(ar *txtar.Archive) ([]*txtar.Archive, int) {
+	var n int
+	for _, f := range ar.Files {
+		if strings.Count(f.Name, "/") == 1 && strings.HasSuffix(f.Name, "/") {
+			n++
+		}
+	}
+	inputs := make([]*txtar.Archive, n)
+	for i := range inputs {
+		inputs[i] = &txtar.Archive{}
+	}
+	for _, f := range ar.Files {
+		dir, file, _ := strings.Cut(f.Name, "/")
+		i, err := strconv.Atoi(dir)
+		if err != nil || i < 0 || i >= n || file == "" {
+			continue
+		}
+		inputs[i].Files = append(inputs[i].Files, txtar.File{Name: file, Data: f.Data})
+	}
+	current, err := strconv.Atoi(strings.TrimSpace(string(ar.Comment)))
+	if err != nil || current < 0 || current >= n {
+		current = 0
+	}
+	return inputs, current
+}
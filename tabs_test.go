@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/txtar"
+)
+
+var savedTabsTests = []struct {
+	name        string
+	in          string
+	wantInputs  [][]txtar.File
+	wantCurrent int
+}{
+	{
+		name:        "saved",
+		in:          "1\n-- 0/ --\n-- 0/src.cel --\na\n-- 1/ --\n-- 2/ --\n-- 2/data.json --\n{}\n",
+		wantInputs:  [][]txtar.File{{{Name: "src.cel", Data: []byte("a\n")}}, nil, {{Name: "data.json", Data: []byte("{}\n")}}},
+		wantCurrent: 1,
+	},
+	{
+		name:       "huge_index",
+		in:         "0\n-- 0/ --\n-- 1/ --\n-- 999999999/ --\n-- 999999999/src.cel --\nb\n",
+		wantInputs: [][]txtar.File{nil, nil, nil},
+	},
+	{
+		name:       "bad_entries",
+		in:         "x\n-- 0/ --\n-- 0/src.cel --\na\n-- -1/src.cel --\nb\n-- a/src.cel --\nc\n-- 1/ --\n-- 5/src.cel --\nd\n",
+		wantInputs: [][]txtar.File{{{Name: "src.cel", Data: []byte("a\n")}}, nil},
+	},
+	{
+		name:       "current_out_of_range",
+		in:         "7\n-- 0/ --\n-- 1/ --\n",
+		wantInputs: [][]txtar.File{nil, nil},
+	},
+	{
+		name: "empty",
+		in:   "",
+	},
+}
+
+// TestSavedTabs checks that the tabs saved by a previous session are
+// read back, and that damaged entries are skipped rather than opening
+// tabs that were not saved.
+func TestSavedTabs(t *testing.T) {
+	for _, test := range savedTabsTests {
+		t.Run(test.name, func(t *testing.T) {
+			inputs, current := savedTabs(txtar.Parse([]byte(test.in)))
+			var got [][]txtar.File
+			for _, ar := range inputs {
+				got = append(got, ar.Files)
+			}
+			if !reflect.DeepEqual(got, test.wantInputs) {
+				t.Errorf("unexpected inputs:\ngot:  %q\nwant: %q", got, test.wantInputs)
+			}
+			if current != test.wantCurrent {
+				t.Errorf("unexpected current tab: got:%d want:%d", current, test.wantCurrent)
+			}
+		})
+	}
+}
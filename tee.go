@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	. "modernc.org/tk9.0"
+)
+
+// tee is the file that the standard output of runs is copied to as it
+// arrives, before it is decoded, so that outputs too large to display
+// can be kept.
+type tee struct {
+	on   bool // Runs' output is copied to path.
+	path string
+	hide bool // Output copied to the file is not displayed.
+
+	run     string       // File the latest run's output was copied to, if any.
+	written atomic.Int64 // Bytes of the latest run's output copied.
+}
+
+// toggleTee asks for the file to copy runs' output to when copying is
+// turned on, leaving it off if none is chosen.
+func (m *miko) toggleTee() {
+	if !m.tee.on {
+		return
+	}
+	path := m.getSaveFile(Title("Tee Output to File"), Defaultextension(".ndjson"))
+	if path == "" {
+		m.tee.on = false
+		m.sync()
+		return
+	}
+	m.tee.path = path
+	m.announce("Output of runs will be copied to " + filepath.Base(path))
+}
+
+// teeWriter writes a run's output to a file, counting the bytes written.
+// A failed write is reported once and later writes are dropped, so that
+// the output is still displayed.
+type teeWriter struct {
+	f       *os.File
+	written *atomic.Int64
+	report  func(error)
+	err     error
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	if w.err == nil {
+		n, err := w.f.Write(p)
+		w.written.Add(int64(n))
+		if err != nil {
+			w.err = err
+			w.report(err)
+		}
+	}
+	return len(p), nil
+}
+
+// close closes the file, reporting any error.
+func (w *teeWriter) close() {
+	if err := w.f.Close(); err != nil && w.err == nil {
+		w.report(err)
+	}
+}
+
+// startTee creates the file for the output of a run and returns the
+// writer copying to it, or nil if output is not being copied.
+func (m *miko) startTee() (*teeWriter, error) {
+	m.tee.run = ""
+	m.tee.written.Store(0)
+	if !m.tee.on {
+		return nil, nil
+	}
+	f, err := os.Create(m.tee.path)
+	if err != nil {
+		return nil, fmt.Errorf("tee: %w", err)
+	}
+	m.tee.run = m.tee.path
+	return &teeWriter{
+		f:       f,
+		written: &m.tee.written,
+		report: func(err error) {
+			m.send(text{data: fmt.Sprintf("tee: %v", err), tag: "error"})
+		},
+	}, nil
+}
+
+// teeStatus returns the status bar note of the bytes of the latest
+// run's output copied to a file, or "" if it was not copied.
+func (m *miko) teeStatus() string {
+	if m.tee.run == "" {
+		return ""
+	}
+	return fmt.Sprintf(", %s to %s", byteSize(int(m.tee.written.Load())), filepath.Base(m.tee.run))
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// theme maps the colour roles of miko's editors and display to colours.
+type theme map[string]string
+
+// themePresets are the built-in themes. Every role is defined by each
+// preset, and the light preset is used for roles missing from a theme
+// file without a base.
+var themePresets = map[string]theme{
+	"light": {
+		"background":          "white",
+		"foreground":          "black",
+		"cursor":              "black",
+		"selection":           "#c3c3c3",
+		"selected_text":       "black",
+		"output":              "black",
+		"error":               "red",
+		"runtime_error":       "DarkOrange3",
+		"note":                "gray50",
+		"raw":                 "navy",
+		"log":                 "DarkGreen",
+		"status_ok":           "green4",
+		"status_client_error": "DarkOrange3",
+		"status_server_error": "red3",
+		"slow_request":        "LightGoldenrod1",
+		"link":                "blue",
+		"error_line":          "MistyRose",
+		"runtime_error_line":  "PeachPuff",
+		"pinned":              "LightYellow",
+		"find_match":          "khaki",
+		"find_current":        "orange",
+		"guide0":              "#f4f4f4",
+		"guide1":              "#e8e8e8",
+		"bracket0":            "blue3",
+		"bracket1":            "DarkOrange3",
+		"bracket2":            "green4",
+		"bracket3":            "magenta3",
+		"bracket4":            "DarkGoldenrod3",
+		"bracket5":            "turquoise4",
+	},
+	"dark": {
+		"background":          "#1e1e1e",
+		"foreground":          "#d4d4d4",
+		"cursor":              "#aeafad",
+		"selection":           "#264f78",
+		"selected_text":       "#d4d4d4",
+		"output":              "#d4d4d4",
+		"error":               "#f48771",
+		"runtime_error":       "#ce9178",
+		"note":                "gray60",
+		"raw":                 "#9cdcfe",
+		"log":                 "#6a9955",
+		"status_ok":           "#73c991",
+		"status_client_error": "#e5a550",
+		"status_server_error": "#f14c4c",
+		"slow_request":        "#4a3a10",
+		"link":                "#3794ff",
+		"error_line":          "#5a1d1d",
+		"runtime_error_line":  "#5a3a1d",
+		"pinned":              "#3a3a1d",
+		"find_match":          "#613214",
+		"find_current":        "#9e6a03",
+		"guide0":              "#232323",
+		"guide1":              "#292929",
+		"bracket0":            "#ffd700",
+		"bracket1":            "#da70d6",
+		"bracket2":            "#179fff",
+		"bracket3":            "#4ec9b0",
+		"bracket4":            "#f48771",
+		"bracket5":            "#c586c0",
+	},
+	// The high contrast theme keeps text at a contrast ratio of at
+	// least 7:1 against every background it can appear on.
+	"high-contrast": {
+		"background":          "black",
+		"foreground":          "white",
+		"cursor":              "yellow",
+		"selection":           "yellow",
+		"selected_text":       "black",
+		"output":              "white",
+		"error":               "#ff8080",
+		"runtime_error":       "#ffc040",
+		"note":                "#d0d0d0",
+		"raw":                 "#80ffff",
+		"log":                 "#80ff80",
+		"status_ok":           "#80ff80",
+		"status_client_error": "#ffc040",
+		"status_server_error": "#ff8080",
+		"slow_request":        "#403000",
+		"link":                "#a0c0ff",
+		"error_line":          "#400000",
+		"runtime_error_line":  "#402000",
+		"pinned":              "#303000",
+		"find_match":          "#003060",
+		"find_current":        "#0050a0",
+		"guide0":              "#101010",
+		"guide1":              "#1c1c1c",
+		"bracket0":            "yellow",
+		"bracket1":            "cyan",
+		"bracket2":            "#ff80ff",
+		"bracket3":            "#80ff80",
+		"bracket4":            "#ffc040",
+		"bracket5":            "white",
+	},
+}
+
+// themeNames are the names of the presets in the order they are offered.
+var themeNames = []string{"light", "dark", "high-contrast"}
+
+// themeFile is the format of a theme file. Colours are given by role,
+// and roles that are not given are taken from the base preset.
+type themeFile struct {
+	Base   string            `yaml:"base"`
+	Colors map[string]string `yaml:"colors"`
+}
+
+// loadTheme returns the preset called name or, if there is none, the
+// theme held in the file at the path name. Problems with a theme file
+// are returned alongside the theme, which uses the base preset for any
+// role that is missing or has an invalid colour.
+func loadTheme(name string) (theme, []error) {
+	if t, ok := themePresets[name]; ok {
+		return t, nil
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return themePresets["light"], []error{fmt.Errorf("theme: %w", err)}
+	}
+	var f themeFile
+	err = yaml.Unmarshal(b, &f)
+	if err != nil {
+		return themePresets["light"], []error{fmt.Errorf("theme %s: %w", name, err)}
+	}
+	if f.Base == "" {
+		f.Base = "light"
+	}
+	base, ok := themePresets[f.Base]
+	if !ok {
+		return themePresets["light"], []error{fmt.Errorf("theme %s: unknown base %q", name, f.Base)}
+	}
+	t := maps.Clone(base)
+	var errs []error
+	for _, role := range slices.Sorted(maps.Keys(f.Colors)) {
+		color := f.Colors[role]
+		if _, ok := base[role]; !ok {
+			errs = append(errs, fmt.Errorf("theme %s: unknown role %q", name, role))
+			continue
+		}
+		if !validColor(color) {
+			errs = append(errs, fmt.Errorf("theme %s: invalid colour %q for %s", name, color, role))
+			continue
+		}
+		t[role] = color
+	}
+	return t, errs
+}
+
+// validColor reports whether Tk recognises color.
+func validColor(color string) bool {
+	if color == "" || strings.ContainsAny(color, "{}[]$\\\"; \t\n") {
+		return false
+	}
+	_, err := Eval("winfo rgb . " + color)
+	return err == nil
+}
+
+// themeTag is a tag coloured by a theme role.
+type themeTag struct {
+	tag        string
+	role       string
+	background bool // The role colours the background, not the text.
+}
+
+// displayTags are the themed tags of the output display, in order of
+// increasing priority.
+var displayTags = []themeTag{
+	{tag: "output", role: "output"},
+	{tag: "error", role: "error"},
+	{tag: "note", role: "note"},
+	{tag: "raw", role: "raw"},
+	{tag: "runHeader", role: "note"},
+	{tag: "snapshotHeader", role: "note"},
+	{tag: "repeatCount", role: "note"},
+	{tag: "logSummary", role: "log"},
+	{tag: "logBody", role: "log"},
+	{tag: "status2xx", role: "status_ok"},
+	{tag: "status4xx", role: "status_client_error"},
+	{tag: "status5xx", role: "status_server_error"},
+	{tag: "slowRequest", role: "slow_request", background: true},
+	{tag: "compileError", role: "error"},
+	{tag: "runtimeError", role: "runtime_error"},
+	{tag: "dataFileLink", role: "link"},
+	{tag: "profileLink", role: "link"},
+	{tag: "keyName", role: "find_match", background: true},
+	{tag: "keyValue", role: "find_match", background: true},
+	{tag: "keyCurrent", role: "find_current", background: true},
+	{tag: "errorCurrent", role: "error_line", background: true},
+	{tag: "pinned", role: "pinned", background: true},
+}
+
+// editorTags are the themed tags of the input panes.
+var editorTags = []themeTag{
+	{tag: "findMatch", role: "find_match", background: true},
+	{tag: "findCurrent", role: "find_current", background: true},
+	{tag: "compileErrorLine", role: "error_line", background: true},
+	{tag: "runtimeErrorLine", role: "runtime_error_line", background: true},
+	{tag: "cfgWarning", role: "runtime_error_line", background: true},
+	{tag: "lintWarning", role: "runtime_error_line", background: true},
+}
+
+// configure sets the colour of tt in w from t.
+func (t theme) configure(w *TextWidget, tt themeTag) {
+	if tt.background {
+		w.TagConfigure(tt.tag, Background(t[tt.role]))
+	} else {
+		w.TagConfigure(tt.tag, Foreground(t[tt.role]))
+	}
+}
+
+// applyTheme colours the editors, the display and their decorations
+// from t.
+func (m *miko) applyTheme(t theme) {
+	m.theme = t
+	texts := []*TextWidget{m.display}
+	for _, p := range m.panes {
+		texts = append(texts, p.text)
+	}
+	for _, w := range texts {
+		w.Configure(
+			Background(t["background"]),
+			Foreground(t["foreground"]),
+			Insertbackground(t["cursor"]),
+			Selectbackground(t["selection"]),
+			Selectforeground(t["selected_text"]),
+			Inactiveselectbackground(t["selection"]),
+		)
+	}
+	for _, tt := range displayTags {
+		t.configure(m.display, tt)
+	}
+	for _, p := range m.panes {
+		for _, tt := range editorTags {
+			t.configure(p.text, tt)
+		}
+	}
+	m.guides.setColors([2]string{t["guide0"], t["guide1"]})
+	var brackets [len(rainbowTags)]string
+	for i := range brackets {
+		brackets[i] = t[fmt.Sprintf("bracket%d", i)]
+	}
+	m.rainbow.setColors(brackets)
+	m.minimap.canvas.Configure(Background(t["background"]))
+	m.minimap.dirty = true
+	m.folds.canvas.Configure(Background(t["background"]))
+	for _, tp := range m.tips {
+		tp.setColors(t)
+	}
+}
+
+// setTheme loads and applies the theme called name, a preset or a
+// theme file, and saves the choice.
+func (m *miko) setTheme(name string) {
+	t, errs := loadTheme(name)
+	for _, err := range errs {
+		m.printError(err)
+	}
+	m.applyTheme(t)
+	m.config.Theme = name
+	m.saveConfig()
+}
+
+// loadThemeFile asks for a theme file and applies it.
+func (m *miko) loadThemeFile() {
+	paths := GetOpenFile(Title("Load Theme"), Filetypes([]FileType{{TypeName: "Theme", Extensions: []string{".yaml", ".yml"}}}))
+	if len(paths) == 0 || paths[0] == "" {
+		return
+	}
+	m.setTheme(paths[0])
+	m.sync()
+}
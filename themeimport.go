@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	. "modernc.org/tk9.0"
+)
+
+// vscodeColors maps VS Code workbench colours to miko's roles. Where
+// several colours map to a role the first one present is used.
+var vscodeColors = []struct{ key, role string }{
+	{"editor.background", "background"},
+	{"editor.foreground", "foreground"},
+	{"editor.foreground", "output"},
+	{"editorCursor.foreground", "cursor"},
+	{"editor.selectionBackground", "selection"},
+	{"errorForeground", "error"},
+	{"editorError.foreground", "error"},
+	{"editorWarning.foreground", "runtime_error"},
+	{"descriptionForeground", "note"},
+	{"editorLineNumber.foreground", "note"},
+	{"textLink.foreground", "link"},
+	{"diffEditor.removedLineBackground", "error_line"},
+	{"editorOverviewRuler.warningForeground", "runtime_error_line"},
+	{"editor.lineHighlightBackground", "pinned"},
+	{"editor.findMatchHighlightBackground", "find_match"},
+	{"editor.findMatchBackground", "find_current"},
+	{"editorIndentGuide.background", "guide1"},
+	{"editorIndentGuide.background1", "guide1"},
+	{"editorBracketHighlight.foreground1", "bracket0"},
+	{"editorBracketHighlight.foreground2", "bracket1"},
+	{"editorBracketHighlight.foreground3", "bracket2"},
+	{"editorBracketHighlight.foreground4", "bracket3"},
+	{"editorBracketHighlight.foreground5", "bracket4"},
+	{"editorBracketHighlight.foreground6", "bracket5"},
+}
+
+// textMateSettings maps the global settings of a TextMate theme to
+// miko's roles.
+var textMateSettings = []struct{ key, role string }{
+	{"background", "background"},
+	{"foreground", "foreground"},
+	{"foreground", "output"},
+	{"caret", "cursor"},
+	{"selection", "selection"},
+	{"lineHighlight", "pinned"},
+	{"findHighlight", "find_current"},
+	{"invisibles", "note"},
+	{"guide", "guide1"},
+}
+
+// scopeRoles maps token scopes of either kind of theme to miko's roles.
+// A scope matches if it is equal to or a prefix of the given scope.
+var scopeRoles = []struct{ scope, role string }{
+	{"invalid", "error"},
+	{"comment", "note"},
+	{"string", "raw"},
+	{"markup.inserted", "log"},
+	{"markup.underline.link", "link"},
+}
+
+// requiredRoles are the roles that an imported theme is expected to
+// give. They are reported if they are taken from the base theme.
+var requiredRoles = []string{"background", "foreground", "selection", "output", "error"}
+
+// importTheme converts the VS Code JSON theme or TextMate plist theme
+// held in b to a miko theme file. The base of the theme is chosen from
+// the brightness of its background. It also returns the required roles
+// that the theme did not give.
+func importTheme(name string, b []byte) (*themeFile, []string, error) {
+	var (
+		colors map[string]string
+		err    error
+	)
+	if strings.EqualFold(filepath.Ext(name), ".tmTheme") || strings.HasPrefix(strings.TrimSpace(string(b)), "<") {
+		colors, err = importTextMate(b)
+	} else {
+		colors, err = importVSCode(b)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &themeFile{Base: "light", Colors: make(map[string]string)}
+	if dark(colors["background"]) {
+		f.Base = "dark"
+	}
+	for role, c := range colors {
+		if c, ok := tkColor(c); ok {
+			f.Colors[role] = c
+		}
+	}
+	var missing []string
+	for _, role := range requiredRoles {
+		if _, ok := f.Colors[role]; !ok {
+			missing = append(missing, role)
+		}
+	}
+	return f, missing, nil
+}
+
+// vscodeTheme is the part of a VS Code colour theme used by miko.
+type vscodeTheme struct {
+	Colors      map[string]string `json:"colors"`
+	TokenColors []struct {
+		Scope    any `json:"scope"` // A string or a list of strings.
+		Settings struct {
+			Foreground string `json:"foreground"`
+		} `json:"settings"`
+	} `json:"tokenColors"`
+}
+
+func importVSCode(b []byte) (map[string]string, error) {
+	var t vscodeTheme
+	err := json.Unmarshal(jsonComments.ReplaceAll(b, nil), &t)
+	if err != nil {
+		return nil, err
+	}
+	colors := make(map[string]string)
+	for _, c := range vscodeColors {
+		if v, ok := t.Colors[c.key]; ok && colors[c.role] == "" {
+			colors[c.role] = v
+		}
+	}
+	for _, tc := range t.TokenColors {
+		var scopes []string
+		switch s := tc.Scope.(type) {
+		case string:
+			scopes = strings.Split(s, ",")
+		case []any:
+			for _, v := range s {
+				if v, ok := v.(string); ok {
+					scopes = append(scopes, v)
+				}
+			}
+		}
+		setScopes(colors, scopes, tc.Settings.Foreground)
+	}
+	return colors, nil
+}
+
+// jsonComments matches the line comments allowed in VS Code's JSON
+// files. Comments after other content on a line are not removed so
+// that URLs in strings are left intact.
+var jsonComments = regexp.MustCompile(`(?m)^\s*//.*$`)
+
+// setScopes sets the roles matching scopes to color if they are not
+// already set.
+func setScopes(colors map[string]string, scopes []string, color string) {
+	if color == "" {
+		return
+	}
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		for _, sr := range scopeRoles {
+			if (s == sr.scope || strings.HasPrefix(s, sr.scope+".")) && colors[sr.role] == "" {
+				colors[sr.role] = color
+			}
+		}
+	}
+}
+
+func importTextMate(b []byte) (map[string]string, error) {
+	v, err := parsePlist(b)
+	if err != nil {
+		return nil, err
+	}
+	root, _ := v.(map[string]any)
+	settings, _ := root["settings"].([]any)
+	if len(settings) == 0 {
+		return nil, errors.New("no settings in TextMate theme")
+	}
+	colors := make(map[string]string)
+	for i, s := range settings {
+		item, _ := s.(map[string]any)
+		values, _ := item["settings"].(map[string]any)
+		if i == 0 {
+			// The first item holds the global settings.
+			for _, c := range textMateSettings {
+				if v, ok := values[c.key].(string); ok && colors[c.role] == "" {
+					colors[c.role] = v
+				}
+			}
+			continue
+		}
+		scope, _ := item["scope"].(string)
+		fg, _ := values["foreground"].(string)
+		setScopes(colors, strings.Split(scope, ","), fg)
+	}
+	return colors, nil
+}
+
+// parsePlist parses the dict, array and string elements of an XML
+// property list. Other values are returned as their text.
+func parsePlist(b []byte) (any, error) {
+	dec := xml.NewDecoder(strings.NewReader(string(b)))
+	dec.Strict = false
+	var parse func(start xml.StartElement) (any, error)
+	parse = func(start xml.StartElement) (any, error) {
+		switch start.Name.Local {
+		case "dict", "array", "plist":
+			var (
+				dict  = make(map[string]any)
+				array []any
+				key   string
+			)
+			for {
+				tok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				switch tok := tok.(type) {
+				case xml.StartElement:
+					v, err := parse(tok)
+					if err != nil {
+						return nil, err
+					}
+					switch {
+					case tok.Name.Local == "key":
+						key, _ = v.(string)
+					case start.Name.Local == "dict":
+						dict[key] = v
+					default:
+						array = append(array, v)
+					}
+				case xml.EndElement:
+					switch start.Name.Local {
+					case "dict":
+						return dict, nil
+					case "plist":
+						if len(array) == 0 {
+							return nil, errors.New("empty property list")
+						}
+						return array[0], nil
+					}
+					return array, nil
+				}
+			}
+		default:
+			var s string
+			err := dec.DecodeElement(&s, &start)
+			return s, err
+		}
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, errors.New("no property list found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "plist" {
+			return parse(start)
+		}
+	}
+}
+
+// tkColor returns color in a form understood by Tk. Colours with an
+// alpha channel have it removed, since Tk does not support them.
+func tkColor(color string) (string, bool) {
+	color = strings.TrimSpace(color)
+	if !strings.HasPrefix(color, "#") {
+		return "", false
+	}
+	hex := color[1:]
+	switch len(hex) {
+	case 4, 8:
+		hex = hex[:len(hex)*3/4]
+	case 3, 6:
+	default:
+		return "", false
+	}
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+		return "", false
+	}
+	return "#" + hex, true
+}
+
+// dark reports whether the hex colour color is dark.
+func dark(color string) bool {
+	c, ok := tkColor(color)
+	if !ok {
+		return false
+	}
+	hex := c[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, _ := strconv.ParseUint(hex, 16, 32)
+	r, g, b := v>>16&0xff, v>>8&0xff, v&0xff
+	return 299*r+587*g+114*b < 128*1000
+}
+
+// importThemeFile asks for a VS Code or TextMate theme, converts it to
+// a miko theme file saved where the user chooses, and applies it.
+// Required roles that the theme did not give are reported.
+func (m *miko) importThemeFile() {
+	paths := GetOpenFile(Title("Import Theme"), Filetypes([]FileType{
+		{TypeName: "VS Code Theme", Extensions: []string{".json"}},
+		{TypeName: "TextMate Theme", Extensions: []string{".tmTheme"}},
+	}))
+	if len(paths) == 0 || paths[0] == "" {
+		return
+	}
+	src := paths[0]
+	b, err := os.ReadFile(src)
+	if err != nil {
+		m.printError(fmt.Errorf("importing theme: %w", err))
+		return
+	}
+	f, missing, err := importTheme(src, b)
+	if err != nil {
+		m.printError(fmt.Errorf("importing theme %s: %w", src, err))
+		return
+	}
+	dst := m.getSaveFile(Title("Save Theme"), Defaultextension(".yaml"),
+		Initialfile(strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))+".yaml"))
+	if dst == "" {
+		return
+	}
+	out, err := yaml.Marshal(f)
+	if err == nil {
+		err = os.WriteFile(dst, out, 0o600)
+	}
+	if err != nil {
+		m.printError(fmt.Errorf("saving theme: %w", err))
+		return
+	}
+	m.setTheme(dst)
+	m.sync()
+	if len(missing) != 0 {
+		MessageBox(
+			Parent(App),
+			Icon("info"),
+			Title("Import Theme"),
+			Msg(fmt.Sprintf("The theme did not give colours for %s.", strings.Join(missing, ", "))),
+			Detail(fmt.Sprintf("These are taken from the %s theme. They can be set by editing %s.", f.Base, dst)),
+		)
+	}
+}
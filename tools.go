@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/execabs"
+	"golang.org/x/tools/txtar"
+	. "modernc.org/tk9.0"
+)
+
+// toolButton is a toolbar button defined in the configuration that runs
+// an external command with the current inputs, for example
+//
+//	buttons:
+//	  - label: Lint
+//	    command: [cel-lint, "{src}"]
+//	  - label: Upload
+//	    command: [upload, -name, session]
+//	    stdin: txtar
+type toolButton struct {
+	// Label is the text of the button.
+	Label string `yaml:"label"`
+	// Command is the program and its arguments. The placeholders
+	// {src}, {data}, {cfg}, {stdin}, {params} and {mock} in arguments
+	// are replaced by the paths of files holding the inputs, and
+	// {txtar} by the path of a txtar archive of all the inputs.
+	Command []string `yaml:"command"`
+	// Stdin is the name of an input, without braces, written to the
+	// command's standard input.
+	Stdin string `yaml:"stdin,omitempty"`
+}
+
+// toolTimeout is the time allowed for a toolbar command.
+const toolTimeout = 2 * time.Minute
+
+// toolInputs returns the inputs that can be given to toolbar commands
+// by name, with the file names used for them. Empty inputs are given as
+// empty files.
+func (m *miko) toolInputs() map[string]txtar.File {
+	ar := m.archive(false)
+	inputs := map[string]txtar.File{
+		"txtar": {Name: "session.txtar", Data: txtar.Format(ar)},
+	}
+	for _, p := range m.panes {
+		inputs[p.name] = txtar.File{Name: p.name}
+	}
+	for _, f := range ar.Files {
+		name, _, _ := strings.Cut(f.Name, ".")
+		inputs[name] = f
+	}
+	return inputs
+}
+
+// toolButtons adds the configured toolbar buttons to parent and returns
+// them.
+func (m *miko) toolButtons(parent *FrameWidget) []Widget {
+	var buttons []Widget
+	for _, b := range m.config.Buttons {
+		if b.Label == "" || len(b.Command) == 0 {
+			continue
+		}
+		w := parent.Button(Txt(b.Label), Command(func() { m.runTool(b) }))
+		m.describe(w, b.Label+" button: run "+b.Command[0])
+		buttons = append(buttons, w)
+	}
+	return buttons
+}
+
+// runTool runs the command of the toolbar button b with the current
+// inputs, showing its output in the display: standard output as raw
+// text and standard error as errors, followed by its exit status.
+func (m *miko) runTool(b toolButton) {
+	inputs := m.toolInputs()
+	args := make([]string, len(b.Command))
+	for i, arg := range b.Command {
+		for name, f := range inputs {
+			placeholder := "{" + name + "}"
+			if !strings.Contains(arg, placeholder) {
+				continue
+			}
+			path, err := m.cache.path(f.Name, f.Data)
+			if err != nil {
+				m.printError(err)
+				return
+			}
+			arg = strings.ReplaceAll(arg, placeholder, path)
+		}
+		args[i] = arg
+	}
+	var stdin io.Reader
+	if b.Stdin != "" {
+		f, ok := inputs[b.Stdin]
+		if !ok {
+			m.printError(fmt.Errorf("%s: no input named %q for stdin", b.Label, b.Stdin))
+			return
+		}
+		stdin = bytes.NewReader(f.Data)
+	}
+	m.runCommand(b.Label, args, stdin, m.config.WorkDir, m.tabs.currentID())
+}
+
+// runCommand starts the command args in the directory dir with stdin,
+// which may be nil, as its standard input. A
+// header, its standard output as raw text, its standard error as errors
+// and its exit status are sent to the display of the document with id
+// doc. It is safe to call from any goroutine.
+func (m *miko) runCommand(label string, args []string, stdin io.Reader, dir string, doc int) {
+	ctx, cancel := context.WithTimeout(context.Background(), toolTimeout)
+	cmd := execabs.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = stdin
+	cmd.Dir = dir
+	send := func(t text) {
+		t.doc = doc
+		m.send(t)
+	}
+	send(text{data: fmt.Sprintf("── %s: %s ──", label, strings.Join(args, " ")), tag: "runHeader"})
+	fail := func(err error) {
+		cancel()
+		send(text{data: fmt.Sprintf("%s: %v", label, err), tag: "error"})
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		fail(err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, out := range []struct {
+		r   io.Reader
+		tag string
+	}{
+		{stdout, "raw"},
+		{stderr, "error"},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := readLines(out.r, func(line string) {
+				send(text{data: line, tag: out.tag})
+			})
+			if err != nil {
+				send(text{data: fmt.Sprintf("%s: %v", label, err), tag: "error"})
+				// The command must not block on a full pipe.
+				io.Copy(io.Discard, out.r)
+			}
+		}()
+	}
+	go func() {
+		defer cancel()
+		wg.Wait()
+		status := "exit status 0"
+		if err := cmd.Wait(); err != nil {
+			status = err.Error()
+		}
+		send(text{data: fmt.Sprintf("%s: %s", label, status), tag: "note"})
+	}()
+}
+
+// postRun runs the post-run command args with the output of a run of
+// the document with id doc. A failure of the command is shown but does
+// not affect the run.
+func (m *miko) postRun(args []string, output, dir string, doc int) {
+	args = slices.Clone(args)
+	for i, arg := range args {
+		if !strings.Contains(arg, "{output}") {
+			continue
+		}
+		path, err := m.cache.path("output.json", []byte(output))
+		if err != nil {
+			m.send(text{data: fmt.Sprintf("post-run: %v", err), tag: "error", doc: doc})
+			return
+		}
+		args[i] = strings.ReplaceAll(arg, "{output}", path)
+	}
+	m.runCommand("post-run", args, strings.NewReader(output), dir, doc)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunCommandLong checks that a command's output line longer than
+// any scanner buffer arrives whole, and that the output after it and the
+// exit status are not lost. All of it must go to the document the
+// command was run for.
+func TestRunCommandLong(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skipf("no sh: %v", err)
+	}
+	const n = 2 << 20
+	m := &miko{results: make(chan text, 16)}
+	script := "head -c " + strconv.Itoa(n) + " /dev/zero | tr '\\0' a; echo; echo done"
+	m.runCommand("long", []string{"sh", "-c", script}, nil, "", 1)
+	var got []text
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case r := <-m.results:
+			got = append(got, r)
+			if r.tag != "note" {
+				continue
+			}
+		case <-timeout:
+			t.Fatalf("command did not finish: got %d results", len(got))
+		}
+		break
+	}
+	for _, r := range got {
+		if r.doc != 1 {
+			t.Errorf("result not sent to the running document: %s %.20q for document %d", r.tag, r.data, r.doc)
+		}
+	}
+	if len(got) != 4 {
+		t.Fatalf("unexpected number of results: got:%d want:4", len(got))
+	}
+	if got[1].tag != "raw" || got[1].data != strings.Repeat("a", n) {
+		t.Errorf("long line not received whole: got %s line of length %d", got[1].tag, len(got[1].data))
+	}
+	if got[2].tag != "raw" || got[2].data != "done" {
+		t.Errorf("unexpected line after long line: got:%s %q", got[2].tag, got[2].data)
+	}
+	if want := "long: exit status 0"; got[3].data != want {
+		t.Errorf("unexpected status: got:%q want:%q", got[3].data, want)
+	}
+}
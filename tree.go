@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// Each output event in the display starts at a mark named eventN,
+// set as the event is added. An event may be shown as a tree in place of
+// its text: the text is hidden with the eventAsTree tag and a treeview
+// is embedded at the event's mark.
+
+// maxTreeRows is the most rows an event tree shows before scrolling.
+const maxTreeRows = 20
+
+// eventTrees tracks the output events in the display and those shown
+// as trees.
+type eventTrees struct {
+	marks []string                    // Marks of the events in display order.
+	next  int                         // Number used to name the next mark.
+	trees map[string]*TTreeviewWidget // Trees shown, by event mark.
+
+	first   int        // Index in marks of the current run's first event.
+	checked int        // Number of marks compared with memory.
+	memory  treeMemory // Trees shown in the previous run's output.
+}
+
+// treeMemory records which events of a run's output were shown as
+// trees, so that the same events can be shown as trees when a run gives
+// identical output.
+type treeMemory struct {
+	events int            // Number of events in the output.
+	hashes map[int]uint64 // Hashes of the text of the events shown as trees, by position.
+}
+
+// configureTrees sets up the tag hiding the text of events shown as
+// trees.
+func (m *miko) configureTrees() {
+	m.display.TagConfigure("eventAsTree", Elide(true))
+	m.trees.trees = make(map[string]*TTreeviewWidget)
+}
+
+// markEvents marks the starts of the output events at lines of the
+// display. It must be called after the events are inserted.
+func (m *miko) markEvents(lines []int) {
+	t := &m.trees
+	for _, line := range lines {
+		t.next++
+		mark := fmt.Sprintf("event%d", t.next)
+		m.display.MarkSet(mark, fmt.Sprintf("%d.0", line))
+		t.marks = append(t.marks, mark)
+	}
+}
+
+// pruneEvents forgets the events removed from the top of the display.
+// Their marks are left at the start of the display, where only the first
+// can belong to an event.
+func (m *miko) pruneEvents() {
+	t := &m.trees
+	n := 0
+	for n+1 < len(t.marks) && m.display.Index(t.marks[n+1]) == "1.0" {
+		n++
+	}
+	for _, mark := range t.marks[:n] {
+		delete(t.trees, mark)
+	}
+	if n != 0 {
+		m.display.MarkUnset(t.marks[:n]...)
+		t.marks = t.marks[n:]
+		t.first = max(t.first-n, 0)
+		t.checked = max(t.checked-n, 0)
+	}
+}
+
+// clearEvents forgets all events. The display must have been cleared.
+func (m *miko) clearEvents() {
+	t := &m.trees
+	if len(t.marks) != 0 {
+		m.display.MarkUnset(t.marks...)
+	}
+	t.marks = nil
+	t.first, t.checked = 0, 0
+	clear(t.trees)
+}
+
+// eventHash returns a hash of the text of the event at mark.
+func (m *miko) eventHash(mark string) uint64 {
+	line, _ := position(m.display.Index(mark))
+	first, last := m.eventRange(line)
+	h := fnv.New64a()
+	for _, s := range m.display.Get(fmt.Sprintf("%d.0", first), fmt.Sprintf("%d.0 lineend", last)) {
+		io.WriteString(h, s)
+	}
+	return h.Sum64()
+}
+
+// rememberTrees records which events of the latest run's output are
+// shown as trees and starts tracking the events of a new run. If none
+// are shown the memory is cleared; if the output has been cleared the
+// memory of the run before is kept. Only the events shown as trees are
+// hashed.
+func (m *miko) rememberTrees() {
+	t := &m.trees
+	marks := t.marks[t.first:]
+	switch {
+	case len(marks) == 0:
+	case len(t.trees) == 0:
+		t.memory = treeMemory{}
+	default:
+		mem := treeMemory{events: len(marks), hashes: make(map[int]uint64)}
+		for i, mark := range marks {
+			if _, ok := t.trees[mark]; ok {
+				mem.hashes[i] = m.eventHash(mark)
+			}
+		}
+		if len(mem.hashes) == 0 {
+			mem = treeMemory{}
+		}
+		t.memory = mem
+	}
+	t.first = len(t.marks)
+	t.checked = t.first
+}
+
+// restoreTrees shows as trees the events added since the last call that
+// are at the position of an event of the previous run shown as a tree,
+// if their text is the same. Once such an event differs, or there are
+// more events than before, the output is taken to differ and the memory
+// is cleared.
+func (m *miko) restoreTrees() {
+	t := &m.trees
+	for ; t.checked < len(t.marks); t.checked++ {
+		mem := &t.memory
+		if mem.events == 0 {
+			t.checked = len(t.marks)
+			return
+		}
+		i := t.checked - t.first
+		if i < 0 || i >= mem.events {
+			*mem = treeMemory{}
+			continue
+		}
+		hash, ok := mem.hashes[i]
+		if !ok {
+			continue
+		}
+		mark := t.marks[t.checked]
+		if m.eventHash(mark) != hash {
+			*mem = treeMemory{}
+			continue
+		}
+		m.showTree(mark)
+	}
+}
+
+// eventAt returns the mark of the output event holding the display
+// index.
+func (m *miko) eventAt(index string) (string, bool) {
+	line, _ := position(m.display.Index(index))
+	first, _ := m.eventRange(line)
+	for _, mark := range m.trees.marks {
+		if l, _ := position(m.display.Index(mark)); l == first {
+			return mark, true
+		}
+	}
+	return "", false
+}
+
+// toggleTree shows the event at mark as a tree, or as text if it is
+// shown as a tree.
+func (m *miko) toggleTree(mark string) {
+	if _, ok := m.trees.trees[mark]; ok {
+		m.hideTree(mark)
+	} else if !m.showTree(mark) {
+		Bell()
+	}
+}
+
+// showTree shows the event at mark as a tree and reports whether it
+// is shown. Events that are not JSON, such as raw output, are left as
+// text.
+func (m *miko) showTree(mark string) bool {
+	t := &m.trees
+	d := m.display
+	if _, ok := t.trees[mark]; ok {
+		return true
+	}
+	line, _ := position(d.Index(mark))
+	first, last := m.eventRange(line)
+	start, end := fmt.Sprintf("%d.0", first), fmt.Sprintf("%d.0 lineend", last)
+	tree := d.TTreeview(Columns("value"), Show("tree"), Selectmode("browse"))
+	rows, err := fillTree(tree, strings.Join(d.Get(start, end), ""))
+	if err != nil {
+		Destroy(tree)
+		return false
+	}
+	tree.Configure(Height(min(rows, maxTreeRows)))
+	width, _ := strconv.Atoi(EvalErr(fmt.Sprintf("winfo width %s", d)))
+	tree.Column("#0", Width(max(width/3, 100)))
+	tree.Column("value", Width(max(width*2/3-30, 100)))
+	Bind(tree, "<Double-Button-1>", Command(func(e *Event) {
+		if tree.IdentifyItem(e.X, e.Y) == "" {
+			m.hideTree(mark)
+		}
+	}))
+	d.Configure(State("normal"))
+	d.TagAdd("eventAsTree", start, end)
+	d.WindowCreate(mark, Win(tree), Padx(2), Pady(2))
+	m.lockDisplay()
+	t.trees[mark] = tree
+	return true
+}
+
+// hideTree shows the event at mark as text.
+func (m *miko) hideTree(mark string) {
+	t := &m.trees
+	d := m.display
+	tree, ok := t.trees[mark]
+	if !ok {
+		return
+	}
+	delete(t.trees, mark)
+	d.Configure(State("normal"))
+	// The embedded tree is the character before the mark, which has
+	// right gravity. Deleting it destroys the tree.
+	if at := EvalErr(fmt.Sprintf("%s index %s", d, tree)); at == d.Index(mark+" -1c") {
+		d.Delete(at)
+	} else {
+		Destroy(tree)
+	}
+	line, _ := position(d.Index(mark))
+	first, last := m.eventRange(line)
+	d.TagRemove("eventAsTree", fmt.Sprintf("%d.0", first), fmt.Sprintf("%d.0 lineend", last))
+	m.lockDisplay()
+}
+
+// showAllTrees shows every output event in the display as a tree.
+func (m *miko) showAllTrees() {
+	seen := make(map[string]bool)
+	for _, mark := range m.trees.marks {
+		// Marks of trimmed events share the start of the display.
+		if index := m.display.Index(mark); !seen[index] {
+			seen[index] = true
+			m.showTree(mark)
+		}
+	}
+}
+
+// hideAllTrees shows every output event in the display as text.
+func (m *miko) hideAllTrees() {
+	for _, mark := range m.trees.marks {
+		m.hideTree(mark)
+	}
+}
+
+// fillTree adds the JSON value in src to tree, keeping the order of
+// object members, and returns the number of rows shown with the top
+// level open.
+func fillTree(tree *TTreeviewWidget, src string) (rows int, err error) {
+	dec := json.NewDecoder(strings.NewReader(src))
+	dec.UseNumber()
+	var add func(parent, label string, depth int) error
+	add = func(parent, label string, depth int) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if depth <= 1 {
+			rows++
+		}
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			value := fmt.Sprint(tok)
+			switch tok := tok.(type) {
+			case nil:
+				value = "null"
+			case string:
+				value = strconv.Quote(tok)
+			}
+			tree.Insert(parent, "end", Txt(label), Values([]string{value}))
+			return nil
+		}
+		item := tree.Insert(parent, "end", Txt(label), Open(depth == 0))
+		n := 0
+		for dec.More() {
+			child := strconv.Itoa(n)
+			if delim == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = fmt.Sprint(key)
+			}
+			err := add(item, child, depth+1)
+			if err != nil {
+				return err
+			}
+			n++
+		}
+		_, err = dec.Token() // The closing delimiter.
+		if err != nil {
+			return err
+		}
+		summary := fmt.Sprintf("[%d]", n)
+		if delim == '{' {
+			summary = fmt.Sprintf("{%d}", n)
+		}
+		tree.Item(item, Values([]string{summary}))
+		return nil
+	}
+	err = add("", "event", 0)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return 0, errors.New("more than one value in event")
+	}
+	return rows, nil
+}
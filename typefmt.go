@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// formatDelay is how long the data pane must be left unedited before it
+// is formatted as typed.
+const formatDelay = 1500 * time.Millisecond
+
+// typeFormat reformats the data pane as JSON once editing pauses.
+type typeFormat struct {
+	edited time.Time // When the data pane was last edited, or zero.
+	self   bool      // The current edit is miko's own reformatting.
+}
+
+// trackTypeFormat follows edits of the data pane for formatting as
+// typed.
+func (m *miko) trackTypeFormat() {
+	m.onChange(m.data, func() {
+		if m.typeFormat.self {
+			return
+		}
+		m.typeFormat.edited = time.Now()
+	})
+}
+
+// formatAsTyped indents the data pane if formatting as typed is on,
+// editing has paused and the content is valid JSON. The insertion
+// cursor is kept on the same character of the document.
+func (m *miko) formatAsTyped() {
+	tf := &m.typeFormat
+	if tf.edited.IsZero() || time.Since(tf.edited) < formatDelay {
+		return
+	}
+	tf.edited = time.Time{}
+	if !m.config.FormatData || len(m.data.TagRanges("sel")) != 0 {
+		return
+	}
+	text := m.data.Text()
+	formatted, err := indentJSON(text)
+	if err != nil || formatted == "" || formatted == text {
+		return
+	}
+	at := offset(m.data, "insert")
+	cursor := significant(text, at)
+	tf.self = true
+	m.formatted(m.data, formatted)
+	tf.self = false
+	offset := insignificant(formatted, cursor)
+	// A cursor before a token stays before it, rather than moving to
+	// the end of the previous line.
+	if at < len(text) && !strings.ContainsRune(" \t\r\n", rune(text[at])) {
+		offset += len(formatted[offset:]) - len(strings.TrimLeft(formatted[offset:], " \t\r\n"))
+	}
+	ix := newIndexer(formatted)
+	m.data.MarkSet("insert", ix.index(offset))
+	m.data.See("insert")
+	m.status.dirty = true
+}
+
+// significant returns the number of characters of the JSON document s
+// before the byte offset n that are not white space between tokens.
+// Indenting the document does not change this count for any character.
+func significant(s string, n int) int {
+	count, _ := scanSignificant(s, n, -1)
+	return count
+}
+
+// insignificant returns the byte offset in the JSON document s of the
+// character after count significant characters, the inverse of
+// significant.
+func insignificant(s string, count int) int {
+	_, n := scanSignificant(s, len(s), count)
+	return n
+}
+
+// scanSignificant counts the significant characters of s before the
+// byte offset n, stopping early at the offset where the count reaches
+// stop if stop is not negative. It returns the count and the offset
+// reached.
+func scanSignificant(s string, n, stop int) (count, end int) {
+	inString, escaped := false, false
+	for i, c := range s[:min(n, len(s))] {
+		if count == stop {
+			return count, i
+		}
+		switch {
+		case inString:
+			count++
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == ' ', c == '\t', c == '\n', c == '\r':
+		default:
+			count++
+			inString = c == '"'
+		}
+	}
+	return count, min(n, len(s))
+}
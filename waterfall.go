@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/autoscroll"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// requestTiming is the span of an HTTP request taken from a record in
+// mito's request log.
+type requestTiming struct {
+	label      string // Method, URL and status of the request.
+	start, end time.Time
+}
+
+// Log fields holding the parts of a request's timing, in order of
+// preference. Both ECS field names and common short names are accepted.
+var (
+	urlKeys      = []string{"url.original", "url.full", "url"}
+	methodKeys   = []string{"http.request.method", "method"}
+	statusKeys   = []string{"http.response.status_code", "status_code", "status"}
+	startKeys    = []string{"event.start", "start"}
+	endKeys      = []string{"event.end", "end", "@timestamp", "time", "ts"}
+	durationKeys = []string{"event.duration", "duration", "elapsed"}
+)
+
+// parseTiming returns the timing of the request logged by fields. The
+// span is taken from its start and end, or from either one and the
+// duration. Records without a URL or a span are not requests with
+// timing.
+func parseTiming(fields []logField) (requestTiming, bool) {
+	get := func(keys []string) string {
+		for _, k := range keys {
+			for _, f := range fields {
+				if f.key == k {
+					return f.value
+				}
+			}
+		}
+		return ""
+	}
+	url := get(urlKeys)
+	if url == "" {
+		return requestTiming{}, false
+	}
+	start, hasStart := parseLogTime(get(startKeys))
+	end, hasEnd := parseLogTime(get(endKeys))
+	d, hasDuration := parseLogDuration(get(durationKeys))
+	switch {
+	case hasStart && hasEnd:
+	case hasEnd && hasDuration:
+		start = end.Add(-d)
+	case hasStart && hasDuration:
+		end = start.Add(d)
+	default:
+		return requestTiming{}, false
+	}
+	label := strings.TrimSpace(strings.Join([]string{get(methodKeys), url, get(statusKeys)}, " "))
+	return requestTiming{label: label, start: start, end: end}, true
+}
+
+// parseLogTime parses a log timestamp, either RFC 3339 or seconds since
+// the epoch.
+func parseLogTime(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+// parseLogDuration parses a logged duration, either a Go duration or a
+// number of nanoseconds as used by ECS.
+func parseLogDuration(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, true
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n), true
+}
+
+// Dimensions of the waterfall chart in pixels.
+const (
+	waterfallRow   = 20
+	waterfallLabel = 320
+	waterfallWidth = 480
+)
+
+// waterfall collects the request timings of the latest run and shows
+// them as a chart of bars against time. Timings are added from the
+// goroutine reading mito's stderr.
+type waterfall struct {
+	mu      sync.Mutex
+	reqs    []requestTiming
+	version int // Incremented on each change to reqs.
+
+	top    *ToplevelWidget // The chart window, or nil if not shown.
+	canvas *CanvasWidget
+	drawn  int // Value of version when the chart was drawn.
+}
+
+// reset removes the timings of the previous run.
+func (wf *waterfall) reset() {
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	wf.reqs = nil
+	wf.version++
+}
+
+// add records the timing of a request.
+func (wf *waterfall) add(r requestTiming) {
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	wf.reqs = append(wf.reqs, r)
+	wf.version++
+}
+
+// moveWindow hands the waterfall window, if it is shown, to the
+// waterfall to, which is drawn in it.
+func (wf *waterfall) moveWindow(to *waterfall) {
+	if wf == to {
+		return
+	}
+	to.top, to.canvas, to.drawn = wf.top, wf.canvas, -1
+	wf.top, wf.canvas = nil, nil
+}
+
+// showWaterfall opens the request waterfall window, or raises it if it
+// is already open.
+func (m *miko) showWaterfall() {
+	wf := m.waterfall
+	if wf.top != nil {
+		EvalErr("raise " + wf.top.String())
+		return
+	}
+	top := App.Toplevel()
+	top.WmTitle("Request Waterfall")
+	GridRowConfigure(top.Window, 0, Weight(1))
+	GridColumnConfigure(top.Window, 0, Weight(1))
+	scrollY := Autoscroll(top.TScrollbar(Command(func(e *Event) { e.Yview(m.waterfall.canvas) }), Orient("vertical")).Window)
+	wf.canvas = top.Canvas(
+		Width(waterfallLabel+waterfallWidth+16),
+		Height(12*waterfallRow),
+		Background(m.theme["background"]),
+		Yscrollcommand(func(e *Event) { e.ScrollSet(scrollY) }),
+	)
+	Grid(wf.canvas, Row(0), Column(0), Sticky("news"))
+	Grid(scrollY, Row(0), Column(1), Sticky("ns"))
+	wf.top = top
+	wf.drawn = -1
+	// The window is forgotten once it is destroyed, rather than when
+	// the Destroy events for its children arrive.
+	remove := m.pushOverlay(overlayDialog, func() { Destroy(top) })
+	Bind(top, "<Destroy>", Command(func(e *Event) {
+		remove()
+		if e.EventWindow != nil && e.EventWindow.String() == top.String() {
+			// The window may have moved to another tab's
+			// waterfall since it was opened.
+			m.waterfall.top, m.waterfall.canvas = nil, nil
+		}
+	}))
+	m.updateWaterfall()
+}
+
+// updateWaterfall redraws the waterfall if it is shown and the timings
+// have changed.
+func (m *miko) updateWaterfall() {
+	wf := m.waterfall
+	if wf.top == nil {
+		return
+	}
+	wf.mu.Lock()
+	version := wf.version
+	reqs := append([]requestTiming(nil), wf.reqs...)
+	wf.mu.Unlock()
+	if version == wf.drawn {
+		return
+	}
+	wf.drawn = version
+	c := wf.canvas
+	c.Delete("all")
+	fg := m.theme["foreground"]
+	if len(reqs) == 0 {
+		c.CreateText(8, 8, Anchor("nw"), Fill(fg),
+			Txt("No request timing in the log of the latest run.\nTurn on Log Requests and run again to see each request."))
+		c.Configure(Scrollregion("0 0 0 0"))
+		return
+	}
+	first, last := reqs[0].start, reqs[0].end
+	for _, r := range reqs {
+		first = minTime(first, r.start)
+		last = maxTime(last, r.end)
+	}
+	total := last.Sub(first)
+	scale := 0.0
+	if total > 0 {
+		scale = waterfallWidth / float64(total)
+	}
+	for i, r := range reqs {
+		y := i*waterfallRow + 4
+		label := r.label
+		if l := []rune(label); len(l) > 48 {
+			label = string(l[:47]) + "…"
+		}
+		c.CreateText(4, y+waterfallRow/2, Anchor("w"), Fill(fg), Txt(label))
+		x0 := waterfallLabel + scale*float64(r.start.Sub(first))
+		x1 := max(x0+2, waterfallLabel+scale*float64(r.end.Sub(first)))
+		c.CreateRectangle(x0, y+3, x1, y+waterfallRow-3, Fill(m.theme["link"]), Outline(""))
+		c.CreateText(x1+4, y+waterfallRow/2, Anchor("w"), Fill(m.theme["note"]), Txt(r.end.Sub(r.start).Round(time.Millisecond).String()))
+	}
+	height := len(reqs)*waterfallRow + 8
+	c.Configure(Scrollregion(fmt.Sprintf("0 0 %d %d", waterfallLabel+waterfallWidth+16, height)))
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	. "modernc.org/tk9.0"
+	. "modernc.org/tk9.0/extensions/eval"
+)
+
+// Mouse wheel scrolling. A notch of a wheel reports a delta of
+// wheelNotch and scrolls wheelLines lines, or wheelColumns average
+// characters with Shift held.
+const (
+	wheelNotch   = 120
+	wheelLines   = 3
+	wheelColumns = 6
+)
+
+// bindWheel makes the mouse wheel over text and the widgets in ws, such
+// as its scrollbars, scroll text by a fixed distance for each notch.
+// The distance is scrolled in pixels, with the remainders of the small
+// deltas reported by touchpads and high resolution wheels carried to
+// the next event, so that scrolling is smooth on long documents and the
+// scrollbars, which follow the text's view, stay in step. With Shift
+// held, or for horizontal wheels, the text scrolls sideways.
+func bindWheel(text *TextWidget, ws ...Widget) {
+	var carry [2]int // Unscrolled remainders for x and y, in delta units times pixels.
+	scroll := func(axis int, delta int) {
+		// The font is looked up each time since it may be changed.
+		var step int
+		if axis == 0 {
+			width, _ := strconv.Atoi(EvalErr(fmt.Sprintf("font measure [%s cget -font] 0", text)))
+			step = max(width, 1) * wheelColumns
+		} else {
+			height, _ := strconv.Atoi(EvalErr(fmt.Sprintf("font metrics [%s cget -font] -linespace", text)))
+			step = max(height, 1) * wheelLines
+		}
+		carry[axis] -= delta * step
+		pixels := carry[axis] / wheelNotch
+		carry[axis] -= pixels * wheelNotch
+		if pixels == 0 {
+			return
+		}
+		view := "yview"
+		if axis == 0 {
+			view = "xview"
+		}
+		EvalErr(fmt.Sprintf("%s %s scroll %d pixels", text, view, pixels))
+	}
+	for _, w := range append([]Widget{text}, ws...) {
+		Bind(w, "<MouseWheel>", Command(func(e *Event) {
+			scroll(1, e.Delta)
+			e.SetReturnCodeBreak()
+		}))
+		Bind(w, "<Shift-MouseWheel>", Command(func(e *Event) {
+			scroll(0, e.Delta)
+			e.SetReturnCodeBreak()
+		}))
+	}
+}